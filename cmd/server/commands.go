@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"merch-shop/internal/services"
+)
+
+const commandsUsage = `usage:
+  server                             start HTTP server
+  server set-role <username> <role>  assign role (user, admin, auditor) to an existing user
+  server migrate up|down [n]|status  manage database schema migrations`
+
+// runCommand - выполняет служебную команду сервера
+func runCommand(args []string, userService *services.UserService) error {
+	switch args[0] {
+	case "set-role":
+		if len(args) != 3 {
+			return fmt.Errorf("set-role expects <username> <role>\n%s", commandsUsage)
+		}
+		if err := userService.SetRole(context.Background(), args[1], args[2]); err != nil {
+			return err
+		}
+		log.Printf("role of %s set to %s", args[1], args[2])
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], commandsUsage)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"merch-shop/internal/config"
+	"merch-shop/internal/services"
+	"os"
+	"strings"
+)
+
+// loadJWTConfig - собирает настройки подписи токенов, читая ключи из указанных файлов
+func loadJWTConfig(cfg config.JWTConfig) (services.JWTConfig, error) {
+	jwtConfig := services.JWTConfig{
+		Algorithm: cfg.Algorithm,
+		KeyID:     cfg.KeyID,
+		Secret:    []byte(cfg.Secret),
+	}
+
+	var err error
+	if cfg.SecretFile != "" {
+		if jwtConfig.Secret, err = readKeyFile(cfg.SecretFile); err != nil {
+			return jwtConfig, err
+		}
+	}
+	if cfg.PrivateKeyFile != "" {
+		if jwtConfig.PrivateKeyPEM, err = readKeyFile(cfg.PrivateKeyFile); err != nil {
+			return jwtConfig, err
+		}
+	}
+
+	jwtConfig.PreviousSecrets = make(map[string][]byte, len(cfg.PreviousSecrets))
+	for kid, secret := range cfg.PreviousSecrets {
+		jwtConfig.PreviousSecrets[kid] = []byte(secret)
+	}
+
+	jwtConfig.PreviousPublicKeysPEM = make(map[string][]byte, len(cfg.PreviousPublicKeyFiles))
+	for kid, path := range cfg.PreviousPublicKeyFiles {
+		if jwtConfig.PreviousPublicKeysPEM[kid], err = readKeyFile(path); err != nil {
+			return jwtConfig, err
+		}
+	}
+
+	return jwtConfig, nil
+}
+
+// readKeyFile - читает ключ из файла, отбрасывая завершающий перевод строки
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"merch-shop/internal/repositories"
+)
+
+// newLoginAttemptRepo - хранилище счётчиков неудачных входов: postgres (по умолчанию,
+// общее для всех экземпляров) или memory (только для одного экземпляра)
+func newLoginAttemptRepo(db *gorm.DB, store string) (repositories.LoginAttemptRepository, error) {
+	switch store {
+	case "", "postgres":
+		return repositories.NewLoginAttemptRepo(db), nil
+	case "memory":
+		return repositories.NewMemoryLoginAttemptRepo(), nil
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_ATTEMPT_STORE %q", store)
+	}
+}
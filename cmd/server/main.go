@@ -3,17 +3,18 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 	"log"
+	"merch-shop/internal/config"
+	"merch-shop/internal/database"
 	"merch-shop/internal/handlers"
 	"merch-shop/internal/middleware"
+	"merch-shop/internal/migrate"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repositories"
 	"merch-shop/internal/services"
+	"merch-shop/migrations"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -21,58 +22,195 @@ import (
 	"time"
 )
 
+// readinessTimeout - сколько /readyz ждёт ответа базы
+const readinessTimeout = 2 * time.Second
+
 func main() {
-	// Загрузить переменные окружения из .env файла
-	err := godotenv.Load(".env")
+	// Настройки: переменные окружения, необязательный .env и YAML-файл из CONFIG_FILE
+	// Команде migrate ключи подписи токенов не нужны, поэтому их настройки для неё не проверяются
+	cfg, err := config.Load(config.Options{EnvFile: ".env", WithoutJWT: len(os.Args) > 1 && os.Args[1] == "migrate"})
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal(err)
 	}
 
-	// Читаем переменные окружения
-	host := os.Getenv("DATABASE_HOST")
-	user := os.Getenv("DATABASE_USER")
-	password := os.Getenv("DATABASE_PASSWORD")
-	dbname := os.Getenv("DATABASE_NAME")
-	dbPort := os.Getenv("DATABASE_PORT")
-	serverPort := os.Getenv("SERVER_PORT")
+	// Подключение к базе: при запуске ждём, пока Postgres станет доступен
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	// Нарушения ограничений схемы возвращаются клиенту как ошибки errs, а не INTERNAL_ERROR
+	if err = db.Use(repositories.ConstraintErrors{}); err != nil {
+		log.Fatalf("failed to register constraint error translation: %v", err)
+	}
 
-	// Формируем DSN
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		host, user, password, dbname, dbPort)
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Управление миграциями: server migrate up|down [n]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrateCommand(os.Args[2:], migrator); err != nil {
+			log.Fatalf("migrate failed: %v", err)
+		}
+		return
+	}
+
+	// Ключи подписи токенов; команде migrate они не нужны, поэтому читаются после неё
+	jwtConfig, err := loadJWTConfig(cfg.JWT)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		log.Fatalf("invalid JWT configuration: %v", err)
+	}
+	jwtKeys, err := services.NewJWTKeys(jwtConfig)
+	if err != nil {
+		log.Fatalf("invalid JWT configuration: %v", err)
 	}
 
-	// Автоматическая миграция
-	if err = db.AutoMigrate(&models.User{}, &models.Merch{}, &models.Purchase{}, models.Transaction{}); err != nil {
-		log.Println("failed to auto migrate: ", err)
+	// Применяем новые миграции при запуске; при MIGRATE_ON_START=false сервер не запускается на устаревшей схеме
+	if err = migrateOnStart(migrator, cfg.Migrations.OnStart); err != nil {
+		log.Fatalf("database schema is not ready: %v", err)
 	}
 
 	userRepo := repositories.NewUserRepo(db)
 	merchRepo := repositories.NewMerchRepo(db)
-	userService := services.NewUserService(userRepo)
+	idempotencyRepo := repositories.NewIdempotencyRepo(db)
+	tokenRepo := repositories.NewTokenRepo(db)
+	ledgerRepo := repositories.NewLedgerRepo(db)
+	economyRepo := repositories.NewEconomyRepo(db)
+	scheduledTransferRepo := repositories.NewScheduledTransferRepo(db)
+	loginAttemptRepo, err := newLoginAttemptRepo(db, cfg.LoginGuard.Store)
+	if err != nil {
+		log.Fatalf("invalid login guard configuration: %v", err)
+	}
+	// Настройки экономики перечитываются из базы раз в 30 секунд, чтобы изменения доходили до всех экземпляров
+	economyService := services.NewEconomyService(economyRepo, 30*time.Second)
+	if err = economyService.Init(context.Background(), cfg.Economy.Defaults()); err != nil {
+		log.Fatalf("failed to load economy settings: %v", err)
+	}
+	userService := services.NewUserService(userRepo, tokenRepo, jwtKeys, cfg.Auth.Settings(), cfg.Shop.RefundGracePeriod, economyService,
+		cfg.Shop.CoinRequestTTL, cfg.Holds.Settings())
 	merchService := services.NewMerchService(merchRepo)
-	userHandler := handlers.NewUserHandler(userService)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.Shop.IdempotencyTTL, cfg.Shop.IdempotencyLease)
+	ledgerService := services.NewLedgerService(ledgerRepo)
+	loginGuard := services.NewLoginGuard(loginAttemptRepo, cfg.LoginGuard.Settings())
+	healthService := services.NewHealthService(repositories.NewHealthRepo(db, migrator), readinessTimeout)
+	schedulerService := services.NewSchedulerService(scheduledTransferRepo, userRepo, userService, cfg.Scheduler.Settings())
+	userHandler := handlers.NewUserHandler(userService, loginGuard)
 	shopHandler := handlers.NewShopHandler(userService, merchService)
+	merchHandler := handlers.NewMerchHandler(merchService)
+	adminHandler := handlers.NewAdminHandler(ledgerService, loginGuard, userService, economyService)
+	schedulerHandler := handlers.NewSchedulerHandler(schedulerService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+
+	// Служебные команды, например: server set-role <username> admin
+	if len(os.Args) > 1 {
+		if err = runCommand(os.Args[1:], userService); err != nil {
+			log.Fatalf("command failed: %v", err)
+		}
+		return
+	}
+
+	// Открываем счета в журнале пользователям, созданным до его появления
+	if opened, err := ledgerService.OpenMissingAccounts(context.Background()); err != nil {
+		log.Println("failed to open ledger accounts: ", err)
+	} else if opened > 0 {
+		log.Printf("opened %d ledger accounts with opening balances", opened)
+	}
+
+	// Время обработки запросов к API ограничено: по его истечении отменяются и запросы к базе
+	requestTimeout := middleware.Timeout(cfg.Server.RequestTimeout)
+	reportTimeout := middleware.Timeout(cfg.Server.ReportTimeout)
 
 	// Инициализация роутеров
 	r := mux.NewRouter()
-	r.HandleFunc("/api/auth", userHandler.Authenticate).Methods("POST")
+	r.HandleFunc("/healthz", healthHandler.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readiness).Methods("GET")
+	r.Handle("/api/auth", requestTimeout(http.HandlerFunc(userHandler.Authenticate))).Methods("POST")
+	r.Handle("/api/register", requestTimeout(http.HandlerFunc(userHandler.Register))).Methods("POST")
+	r.Handle("/api/auth/refresh", requestTimeout(http.HandlerFunc(userHandler.Refresh))).Methods("POST")
+	r.HandleFunc("/.well-known/jwks.json", userHandler.GetJWKS).Methods("GET")
+
+	// Повторы запросов, меняющих баланс, защищены заголовком Idempotency-Key
+	idempotent := middleware.IdempotencyMiddleware(idempotencyService)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+
+	// Отчёты и массовые начисления обрабатывают много строк, поэтому им отведено больше времени.
+	// Подроутер стоит раньше protectedRoutes: вложенный Timeout не смог бы продлить срок обычных запросов.
+	longRoutes := r.PathPrefix("/api").Subrouter()
+	longRoutes.Use(reportTimeout, middleware.AuthMiddleware(userService))
+
+	longRoutes.Handle("/admin/grants", adminOnly(idempotent(http.HandlerFunc(adminHandler.GrantCoins)))).Methods("POST")
+
+	// Отчёты доступны администраторам и аудиторам
+	reportRoutes := longRoutes.PathPrefix("/reports").Subrouter()
+	reportRoutes.Use(middleware.RequireRole(models.RoleAdmin, models.RoleAuditor))
+
+	reportRoutes.HandleFunc("/ledger/reconciliation", adminHandler.GetLedgerReconciliation).Methods("GET")
 
 	protectedRoutes := r.PathPrefix("/api").Subrouter()
-	protectedRoutes.Use(middleware.AuthMiddleware(userService))
+	protectedRoutes.Use(requestTimeout, middleware.AuthMiddleware(userService))
 
-	protectedRoutes.HandleFunc("/buy/{item}", shopHandler.BuyItem).Methods("GET")
-	protectedRoutes.HandleFunc("/sendCoin", shopHandler.SendCoin).Methods("POST")
+	protectedRoutes.HandleFunc("/auth/logout", userHandler.Logout).Methods("POST")
+
+	protectedRoutes.Handle("/buy/{item}", idempotent(http.HandlerFunc(shopHandler.BuyItem))).Methods("GET")
+	protectedRoutes.Handle("/sendCoin", idempotent(http.HandlerFunc(shopHandler.SendCoin))).Methods("POST")
+	protectedRoutes.Handle("/orders", idempotent(http.HandlerFunc(shopHandler.CreateOrder))).Methods("POST")
+	protectedRoutes.Handle("/purchases/{id}/refund", idempotent(http.HandlerFunc(shopHandler.RefundPurchase))).Methods("POST")
 	protectedRoutes.HandleFunc("/info", shopHandler.GetUserInfo).Methods("GET")
+	protectedRoutes.HandleFunc("/history", shopHandler.GetHistory).Methods("GET")
+
+	// Запросы монет: пользователь просит перевод, плательщик принимает или отклоняет запрос
+	protectedRoutes.Handle("/coin-requests", idempotent(http.HandlerFunc(shopHandler.CreateCoinRequest))).Methods("POST")
+	protectedRoutes.HandleFunc("/coin-requests", shopHandler.ListCoinRequests).Methods("GET")
+	protectedRoutes.Handle("/coin-requests/{id}/accept", idempotent(http.HandlerFunc(shopHandler.AcceptCoinRequest))).Methods("POST")
+	protectedRoutes.HandleFunc("/coin-requests/{id}/decline", shopHandler.DeclineCoinRequest).Methods("POST")
+
+	// Удержания монет: передача получателю или возврат владельцу
+	protectedRoutes.Handle("/holds", idempotent(http.HandlerFunc(shopHandler.CreateHold))).Methods("POST")
+	protectedRoutes.Handle("/holds/{id}/release", idempotent(http.HandlerFunc(shopHandler.ReleaseHold))).Methods("POST")
+	protectedRoutes.HandleFunc("/holds/{id}/return", shopHandler.ReturnHold).Methods("POST")
+
+	// Запланированные переводы
+	protectedRoutes.Handle("/scheduled-transfers", idempotent(http.HandlerFunc(schedulerHandler.CreateScheduledTransfer))).Methods("POST")
+	protectedRoutes.HandleFunc("/scheduled-transfers", schedulerHandler.ListScheduledTransfers).Methods("GET")
+	protectedRoutes.HandleFunc("/scheduled-transfers/{id}/runs", schedulerHandler.GetScheduledRuns).Methods("GET")
+	protectedRoutes.HandleFunc("/scheduled-transfers/{id}", schedulerHandler.CancelScheduledTransfer).Methods("DELETE")
+
+	// Каталог мерча: просмотр доступен всем, изменение — только администраторам
+	protectedRoutes.HandleFunc("/merch", merchHandler.ListMerch).Methods("GET")
+	protectedRoutes.HandleFunc("/merch/{name}", merchHandler.GetMerch).Methods("GET")
+	protectedRoutes.Handle("/merch", adminOnly(http.HandlerFunc(merchHandler.CreateMerch))).Methods("POST")
+	protectedRoutes.Handle("/merch/{name}", adminOnly(http.HandlerFunc(merchHandler.UpdateMerch))).Methods("PATCH")
+	protectedRoutes.Handle("/merch/{name}", adminOnly(http.HandlerFunc(merchHandler.RetireMerch))).Methods("DELETE")
+	protectedRoutes.Handle("/merch/{name}/stock", adminOnly(http.HandlerFunc(merchHandler.ChangeStock))).Methods("POST")
+
+	// Управление пользователями доступно только администраторам
+	adminRoutes := protectedRoutes.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(adminOnly)
+
+	adminRoutes.HandleFunc("/users/{username}/lockout", adminHandler.UnlockUser).Methods("DELETE")
+	adminRoutes.Handle("/users/{username}/balance", idempotent(http.HandlerFunc(adminHandler.AdjustBalance))).Methods("POST")
+	adminRoutes.HandleFunc("/economy", adminHandler.GetEconomy).Methods("GET")
+	adminRoutes.HandleFunc("/economy", adminHandler.UpdateEconomy).Methods("PUT")
+
+	// Базовый контекст всех запросов: отменяется, если при остановке они не успели завершиться
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
 	// Создаём сервер
 	srv := &http.Server{
-		Addr:    serverPort,
-		Handler: r,
+		Addr:        cfg.Server.Port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+
+	// Фоновые задачи: запланированные переводы и возврат истёкших удержаний; останавливаются вместе с сервером
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	if cfg.Scheduler.Enabled {
+		go schedulerService.Start(workersCtx)
 	}
+	go userService.StartHoldExpiry(workersCtx)
 
 	// Канал для сигналов завершения
 	stop := make(chan os.Signal, 1)
@@ -80,7 +218,7 @@ func main() {
 
 	// Запуск сервера в отдельной горутине
 	go func() {
-		log.Println("Starting server on " + serverPort)
+		log.Println("Starting server on " + cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Server failed: %v", err)
 		}
@@ -89,12 +227,16 @@ func main() {
 	// Ожидание сигнала завершения
 	<-stop
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	// Создаём контекст с таймаутом для graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
+		// Незавершённые запросы отменяются вместе с их запросами к базе, транзакции откатываются
+		cancelRequests()
+		_ = srv.Close()
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
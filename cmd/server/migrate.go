@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"merch-shop/internal/migrate"
+	"strconv"
+)
+
+const migrateUsage = `usage:
+  server migrate up         apply all pending migrations
+  server migrate down [n]   revert the last n applied migrations (default 1)
+  server migrate status     list migrations and when they were applied`
+
+// runMigrateCommand - выполняет команду управления миграциями
+func runMigrateCommand(args []string, migrator *migrate.Migrator) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("down expects a positive number of migrations\n%s", migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			log.Printf("reverted %04d_%s", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, applied)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// migrateOnStart - применяет новые миграции при запуске сервера. Если это выключено, миграции
+// применяются отдельно командой migrate up, а сервер отказывается запускаться, пока они не применены.
+func migrateOnStart(migrator *migrate.Migrator, enabled bool) error {
+	if enabled {
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("applied migration %04d_%s", migration.Version, migration.Name)
+		}
+		return err
+	}
+
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d pending migrations, run \"server migrate up\"", pending)
+	}
+	return nil
+}
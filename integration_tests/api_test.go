@@ -2,22 +2,29 @@ package integration_tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"io"
 	"log"
+	"merch-shop/internal/config"
+	"merch-shop/internal/database"
+	"merch-shop/internal/errs"
 	"merch-shop/internal/handlers"
 	"merch-shop/internal/middleware"
+	"merch-shop/internal/migrate"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repositories"
 	"merch-shop/internal/services"
+	"merch-shop/migrations"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -25,11 +32,34 @@ import (
 var db *gorm.DB
 var srv *http.Server
 
+// testConfig - настройки тестового окружения: база и порт сервера
+var testConfig *config.Config
+
+// schedulerService - планировщик переводов; в тестах наступившие переводы выполняются вызовом RunDue
+var schedulerService *services.SchedulerService
+
+// userService - сервис пользователей; в тестах истёкшие удержания возвращаются вызовом ExpireHolds
+var userService *services.UserService
+
+// testAdminUsername - пользователь с ролью администратора в тестах
+const testAdminUsername = "test_admin"
+
+// testLoginGuardSettings - пороги защиты от перебора в тестах: блокировка после трёх неудачных попыток
+var testLoginGuardSettings = models.LoginGuardSettings{
+	User:            models.LoginLimit{FreeAttempts: 2, MaxAttempts: 3},
+	IP:              models.LoginLimit{FreeAttempts: 1000, MaxAttempts: 0},
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: time.Hour,
+	Window:          time.Hour,
+}
+
 func TestMain(m *testing.M) {
-	// Загрузить переменные окружения из .env файла
-	err := godotenv.Load("../.env")
+	// Настройки тестового окружения: переменные TEST_* из окружения или ../.env; ключи подписи тесты создают сами
+	var err error
+	testConfig, err = config.Load(config.Options{EnvFile: "../.env", EnvPrefix: "TEST_", WithoutJWT: true})
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal(err)
 	}
 
 	var cleanup func()
@@ -58,20 +88,26 @@ func TestMain(m *testing.M) {
 }
 
 func setupTestDatabase() (*gorm.DB, func()) {
-	dsn := GetTestDSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := database.Open(testConfig.Database)
 	if err != nil {
-		panic("failed to connect to database")
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	if err = db.Use(repositories.ConstraintErrors{}); err != nil {
+		log.Fatalf("failed to register constraint error translation: %v", err)
 	}
 
-	// Автомиграция
-	if err = db.AutoMigrate(&models.User{}, &models.Merch{}, &models.Purchase{}, &models.Transaction{}); err != nil {
-		log.Printf("Error during DB migration: %v", err)
+	// Миграции схемы
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		log.Fatalf("Error during DB migration: %v", err)
 	}
 
 	// Функция очистки данных после тестов
 	cleanup := func() {
-		db.Exec("TRUNCATE users, merches, purchases, transactions RESTART IDENTITY CASCADE")
+		db.Exec("TRUNCATE users, merches, purchases, orders, transactions, idempotency_keys, ledger_entries, refresh_tokens, login_attempts, grants, grant_batches, scheduled_transfers, scheduled_runs, coin_requests, holds RESTART IDENTITY CASCADE")
 	}
 
 	return db, cleanup
@@ -80,23 +116,101 @@ func setupTestDatabase() (*gorm.DB, func()) {
 func setupServer(db *gorm.DB) *http.Server {
 	userRepo := repositories.NewUserRepo(db)
 	merchRepo := repositories.NewMerchRepo(db)
-	userService := services.NewUserService(userRepo)
+	idempotencyRepo := repositories.NewIdempotencyRepo(db)
+	tokenRepo := repositories.NewTokenRepo(db)
+	ledgerRepo := repositories.NewLedgerRepo(db)
+	jwtKeys, err := services.NewJWTKeys(services.JWTConfig{Secret: []byte("integration-test-secret")})
+	if err != nil {
+		log.Fatalf("failed to load JWT keys: %v", err)
+	}
+	economyService := services.NewEconomyService(repositories.NewEconomyRepo(db), 0)
+	if err = economyService.Init(context.Background(), models.DefaultEconomySettings()); err != nil {
+		log.Fatalf("failed to load economy settings: %v", err)
+	}
+	userService = services.NewUserService(userRepo, tokenRepo, jwtKeys, models.AuthSettings{AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour, AutoRegister: true}, 15*time.Minute, economyService, time.Hour,
+		models.HoldSettings{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour, ExpiryInterval: time.Minute})
 	merchService := services.NewMerchService(merchRepo)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, 24*time.Hour, 2*time.Minute)
+	ledgerService := services.NewLedgerService(ledgerRepo)
+	loginGuard := services.NewLoginGuard(repositories.NewLoginAttemptRepo(db), testLoginGuardSettings)
 	shopHandler := handlers.NewShopHandler(userService, merchService)
-	userHandler := handlers.NewUserHandler(userService)
+	merchHandler := handlers.NewMerchHandler(merchService)
+	userHandler := handlers.NewUserHandler(userService, loginGuard)
+	adminHandler := handlers.NewAdminHandler(ledgerService, loginGuard, userService, economyService)
+	schedulerService = services.NewSchedulerService(repositories.NewScheduledTransferRepo(db), userRepo, userService,
+		models.SchedulerSettings{Interval: time.Minute, BatchSize: 100, Lease: time.Minute, MaxActivePerUser: 5})
+	schedulerHandler := handlers.NewSchedulerHandler(schedulerService)
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	healthHandler := handlers.NewHealthHandler(services.NewHealthService(repositories.NewHealthRepo(db, migrator), 2*time.Second))
+
+	requestTimeout := middleware.Timeout(testConfig.Server.RequestTimeout)
+	reportTimeout := middleware.Timeout(testConfig.Server.ReportTimeout)
 
 	r := mux.NewRouter()
-	r.HandleFunc("/api/auth", userHandler.Authenticate).Methods("POST")
+	r.HandleFunc("/healthz", healthHandler.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readiness).Methods("GET")
+	r.Handle("/api/auth", requestTimeout(http.HandlerFunc(userHandler.Authenticate))).Methods("POST")
+	r.Handle("/api/register", requestTimeout(http.HandlerFunc(userHandler.Register))).Methods("POST")
+	r.Handle("/api/auth/refresh", requestTimeout(http.HandlerFunc(userHandler.Refresh))).Methods("POST")
+	r.HandleFunc("/.well-known/jwks.json", userHandler.GetJWKS).Methods("GET")
+
+	idempotent := middleware.IdempotencyMiddleware(idempotencyService)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+
+	longRoutes := r.PathPrefix("/api").Subrouter()
+	longRoutes.Use(reportTimeout, middleware.AuthMiddleware(userService))
+
+	longRoutes.Handle("/admin/grants", adminOnly(idempotent(http.HandlerFunc(adminHandler.GrantCoins)))).Methods("POST")
+
+	reportRoutes := longRoutes.PathPrefix("/reports").Subrouter()
+	reportRoutes.Use(middleware.RequireRole(models.RoleAdmin, models.RoleAuditor))
+
+	reportRoutes.HandleFunc("/ledger/reconciliation", adminHandler.GetLedgerReconciliation).Methods("GET")
 
 	protectedRoutes := r.PathPrefix("/api").Subrouter()
-	protectedRoutes.Use(middleware.AuthMiddleware(userService))
+	protectedRoutes.Use(requestTimeout, middleware.AuthMiddleware(userService))
 
-	protectedRoutes.HandleFunc("/buy/{item}", shopHandler.BuyItem).Methods("GET")
-	protectedRoutes.HandleFunc("/sendCoin", shopHandler.SendCoin).Methods("POST")
+	protectedRoutes.HandleFunc("/auth/logout", userHandler.Logout).Methods("POST")
+
+	protectedRoutes.Handle("/buy/{item}", idempotent(http.HandlerFunc(shopHandler.BuyItem))).Methods("GET")
+	protectedRoutes.Handle("/sendCoin", idempotent(http.HandlerFunc(shopHandler.SendCoin))).Methods("POST")
+	protectedRoutes.Handle("/orders", idempotent(http.HandlerFunc(shopHandler.CreateOrder))).Methods("POST")
+	protectedRoutes.Handle("/purchases/{id}/refund", idempotent(http.HandlerFunc(shopHandler.RefundPurchase))).Methods("POST")
 	protectedRoutes.HandleFunc("/info", shopHandler.GetUserInfo).Methods("GET")
+	protectedRoutes.HandleFunc("/history", shopHandler.GetHistory).Methods("GET")
+	protectedRoutes.Handle("/coin-requests", idempotent(http.HandlerFunc(shopHandler.CreateCoinRequest))).Methods("POST")
+	protectedRoutes.HandleFunc("/coin-requests", shopHandler.ListCoinRequests).Methods("GET")
+	protectedRoutes.Handle("/coin-requests/{id}/accept", idempotent(http.HandlerFunc(shopHandler.AcceptCoinRequest))).Methods("POST")
+	protectedRoutes.HandleFunc("/coin-requests/{id}/decline", shopHandler.DeclineCoinRequest).Methods("POST")
+	protectedRoutes.Handle("/holds", idempotent(http.HandlerFunc(shopHandler.CreateHold))).Methods("POST")
+	protectedRoutes.Handle("/holds/{id}/release", idempotent(http.HandlerFunc(shopHandler.ReleaseHold))).Methods("POST")
+	protectedRoutes.HandleFunc("/holds/{id}/return", shopHandler.ReturnHold).Methods("POST")
+	protectedRoutes.Handle("/scheduled-transfers", idempotent(http.HandlerFunc(schedulerHandler.CreateScheduledTransfer))).Methods("POST")
+	protectedRoutes.HandleFunc("/scheduled-transfers", schedulerHandler.ListScheduledTransfers).Methods("GET")
+	protectedRoutes.HandleFunc("/scheduled-transfers/{id}/runs", schedulerHandler.GetScheduledRuns).Methods("GET")
+	protectedRoutes.HandleFunc("/scheduled-transfers/{id}", schedulerHandler.CancelScheduledTransfer).Methods("DELETE")
+
+	// Каталог мерча: просмотр доступен всем, изменение — только администраторам
+	protectedRoutes.HandleFunc("/merch", merchHandler.ListMerch).Methods("GET")
+	protectedRoutes.HandleFunc("/merch/{name}", merchHandler.GetMerch).Methods("GET")
+	protectedRoutes.Handle("/merch", adminOnly(http.HandlerFunc(merchHandler.CreateMerch))).Methods("POST")
+	protectedRoutes.Handle("/merch/{name}", adminOnly(http.HandlerFunc(merchHandler.UpdateMerch))).Methods("PATCH")
+	protectedRoutes.Handle("/merch/{name}", adminOnly(http.HandlerFunc(merchHandler.RetireMerch))).Methods("DELETE")
+	protectedRoutes.Handle("/merch/{name}/stock", adminOnly(http.HandlerFunc(merchHandler.ChangeStock))).Methods("POST")
+
+	adminRoutes := protectedRoutes.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(adminOnly)
+
+	adminRoutes.HandleFunc("/users/{username}/lockout", adminHandler.UnlockUser).Methods("DELETE")
+	adminRoutes.Handle("/users/{username}/balance", idempotent(http.HandlerFunc(adminHandler.AdjustBalance))).Methods("POST")
+	adminRoutes.HandleFunc("/economy", adminHandler.GetEconomy).Methods("GET")
+	adminRoutes.HandleFunc("/economy", adminHandler.UpdateEconomy).Methods("PUT")
 
 	return &http.Server{
-		Addr:    GetTestServerPort(),
+		Addr:    testConfig.Server.Port,
 		Handler: r,
 	}
 }
@@ -141,6 +255,17 @@ func authenticateUser(username, password string) (string, error) {
 	return authResp.Token, nil
 }
 
+// authenticateWithRole - создаёт пользователя, назначает ему роль и возвращает токен с этой ролью
+func authenticateWithRole(username, password, role string) (string, error) {
+	if _, err := authenticateUser(username, password); err != nil {
+		return "", err
+	}
+	if err := db.Model(&models.User{}).Where("username = ?", username).Update("role", role).Error; err != nil {
+		return "", fmt.Errorf("could not set role: %v", err)
+	}
+	return authenticateUser(username, password)
+}
+
 func TestAuthenticationIntegration(t *testing.T) {
 	// Очищаем данные перед тестом
 	db.Exec("TRUNCATE users RESTART IDENTITY CASCADE")
@@ -148,7 +273,7 @@ func TestAuthenticationIntegration(t *testing.T) {
 	// Создаём тестового пользователя
 	user := &models.User{
 		Username: "test_user",
-		Password: "test_pass",
+		Password: "test_pass1",
 	}
 
 	dbUser := user
@@ -165,14 +290,14 @@ func TestAuthenticationIntegration(t *testing.T) {
 		{
 			name:           "Successful authentication",
 			username:       "test_user",
-			password:       "test_pass",
+			password:       "test_pass1",
 			expectedStatus: http.StatusOK,
 			expectToken:    true,
 		},
 		{
 			name:           "Incorrect password",
 			username:       "test_user",
-			password:       "wrong_pass",
+			password:       "wrong_pass1",
 			expectedStatus: http.StatusBadRequest,
 			expectToken:    false,
 		},
@@ -229,7 +354,7 @@ func TestBuyMerchIntegration(t *testing.T) {
 	// Создаём тестовые данные
 	user := &models.User{
 		Username: "test_user",
-		Password: "test_pass",
+		Password: "test_pass1",
 		Coins:    1000,
 	}
 
@@ -326,12 +451,12 @@ func TestTransferCoinsIntegration(t *testing.T) {
 	// Создаём тестовые данные
 	sender := &models.User{
 		Username: "sender_user",
-		Password: "sender_pass",
+		Password: "sender_pass1",
 		Coins:    1000,
 	}
 	receiver := &models.User{
 		Username: "receiver_user",
-		Password: "receiver_pass",
+		Password: "receiver_pass1",
 		Coins:    500,
 	}
 
@@ -486,7 +611,7 @@ func TestGetUserInfoIntegration(t *testing.T) {
 	// Создаём тестового пользователя
 	user1 := &models.User{
 		Username: "test_user",
-		Password: "test_pass",
+		Password: "test_pass1",
 		Coins:    1000,
 	}
 	user1.ID = 1
@@ -549,7 +674,7 @@ func TestGetUserInfoIntegration(t *testing.T) {
 		{
 			name:           "Unauthorized request",
 			username:       "unknown_user",
-			userpass:       "wrong_pass",
+			userpass:       "wrong_pass1",
 			expectedCoins:  0,
 			expectedItems:  nil,
 			expectedStatus: http.StatusUnauthorized,
@@ -602,3 +727,1670 @@ func TestGetUserInfoIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestConcurrentBalanceIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, purchases, merches RESTART IDENTITY CASCADE")
+
+	const (
+		usersCount    = 5
+		startCoins    = 1000
+		requestsCount = 400
+	)
+
+	merch := &models.Merch{
+		Name:  "Sticker",
+		Price: 30,
+	}
+	db.Create(merch)
+
+	// Создаём пользователей и получаем для них токены
+	usernames := make([]string, usersCount)
+	tokens := make([]string, usersCount)
+	for i := range usernames {
+		usernames[i] = fmt.Sprintf("concurrent_user_%d", i)
+		token, err := authenticateUser(usernames[i], "concurrent_pass1")
+		if err != nil {
+			t.Fatalf("authentication failed: %v", err)
+		}
+		tokens[i] = token
+	}
+	db.Model(&models.User{}).Where("username IN ?", usernames).Update("coins", startCoins)
+
+	client := &http.Client{}
+	var wg sync.WaitGroup
+	for i := 0; i < requestsCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			from := i % usersCount
+			var req *http.Request
+			if i%2 == 0 {
+				// Покупка предмета
+				req, _ = http.NewRequest(
+					"GET",
+					fmt.Sprintf("http://localhost%s/api/buy/%s", srv.Addr, merch.Name),
+					nil,
+				)
+			} else {
+				// Перевод монет следующему пользователю
+				transferBody, _ := json.Marshal(models.SendCoinRequest{
+					ToUser: usernames[(from+1)%usersCount],
+					Amount: 70,
+				})
+				req, _ = http.NewRequest(
+					"POST",
+					fmt.Sprintf("http://localhost%s/api/sendCoin", srv.Addr),
+					bytes.NewBuffer(transferBody),
+				)
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("Authorization", "Bearer "+tokens[from])
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Errorf("could not send request: %v", err)
+				return
+			}
+			defer func() {
+				if err = resp.Body.Close(); err != nil {
+					log.Printf("Error closing response body: %v", err)
+				}
+			}()
+
+			// Допустимы только успех или нехватка монет
+			assert.Contains(t, []int{http.StatusOK, http.StatusBadRequest}, resp.StatusCode)
+		}(i)
+	}
+	wg.Wait()
+
+	// Ни один баланс не должен уйти в минус
+	var users []models.User
+	db.Where("username IN ?", usernames).Find(&users)
+	totalCoins := 0
+	for _, u := range users {
+		assert.GreaterOrEqual(t, u.Coins, 0, "negative balance for %s", u.Username)
+		totalCoins += u.Coins
+	}
+
+	// Монеты сохраняются: остаток + потраченное на покупки равно начальной сумме
+	var purchasesCount int64
+	db.Model(&models.Purchase{}).Count(&purchasesCount)
+	assert.Equal(t, usersCount*startCoins, totalCoins+int(purchasesCount)*merch.Price)
+}
+
+func TestIdempotentSendCoinIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, idempotency_keys, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("idempotent_sender", "sender_pass1")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("idempotent_receiver", "receiver_pass1"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	sendCoin := func(key string, amount int) *http.Response {
+		transferBody, _ := json.Marshal(models.SendCoinRequest{
+			ToUser: "idempotent_receiver",
+			Amount: amount,
+		})
+		req, _ := http.NewRequest(
+			"POST",
+			fmt.Sprintf("http://localhost%s/api/sendCoin", srv.Addr),
+			bytes.NewBuffer(transferBody),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", key)
+
+		resp, err := (&http.Client{}).Do(req)
+		if err != nil {
+			t.Fatalf("could not send transfer request: %v", err)
+		}
+		if err = resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name           string
+		key            string
+		amount         int
+		expectedStatus int
+		replayed       bool
+		coinsAfter     int
+	}{
+		{
+			name:           "First request",
+			key:            "transfer-1",
+			amount:         100,
+			expectedStatus: http.StatusOK,
+			coinsAfter:     900,
+		},
+		{
+			name:           "Retry is replayed",
+			key:            "transfer-1",
+			amount:         100,
+			expectedStatus: http.StatusOK,
+			replayed:       true,
+			coinsAfter:     900,
+		},
+		{
+			name:           "Key reused with different body",
+			key:            "transfer-1",
+			amount:         200,
+			expectedStatus: http.StatusUnprocessableEntity,
+			coinsAfter:     900,
+		},
+		{
+			name:           "New key",
+			key:            "transfer-2",
+			amount:         100,
+			expectedStatus: http.StatusOK,
+			coinsAfter:     800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendCoin(tt.key, tt.amount)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.replayed, resp.Header.Get("Idempotent-Replayed") == "true")
+
+			var sender models.User
+			db.First(&sender, "username = ?", "idempotent_sender")
+			assert.Equal(t, tt.coinsAfter, sender.Coins)
+		})
+	}
+
+	// Запрос, занявший ключ, прервался, не сохранив ответ: после окончания аренды повтор выполняет перевод
+	body, _ := json.Marshal(models.SendCoinRequest{ToUser: "idempotent_receiver", Amount: 100})
+	db.Create(&models.IdempotencyKey{
+		Username:    "idempotent_sender",
+		Key:         "transfer-3",
+		RequestHash: services.RequestFingerprint("POST", "/api/sendCoin", body),
+		LockedUntil: time.Now().Add(-time.Minute),
+	})
+	resp := sendCoin("transfer-3", 100)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+
+	// Ответ сохранён вместе с переводом: следующий повтор его воспроизводит
+	resp = sendCoin("transfer-3", 100)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+
+	var sender models.User
+	db.First(&sender, "username = ?", "idempotent_sender")
+	assert.Equal(t, 700, sender.Coins)
+}
+
+func TestLedgerReconciliationIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, purchases, merches, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass1", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	userToken, err := authenticateUser("ledger_user", "ledger_pass1")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	merch := &models.Merch{
+		Name:  "Cup",
+		Price: 20,
+	}
+	db.Create(merch)
+
+	// Покупка и перевод должны записать сбалансированные проводки
+	reqBuy, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost%s/api/buy/%s", srv.Addr, merch.Name), nil)
+	reqBuy.Header.Set("Authorization", "Bearer "+userToken)
+	transferBody, _ := json.Marshal(models.SendCoinRequest{ToUser: testAdminUsername, Amount: 100})
+	reqTransfer, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost%s/api/sendCoin", srv.Addr), bytes.NewBuffer(transferBody))
+	reqTransfer.Header.Set("Authorization", "Bearer "+userToken)
+
+	client := &http.Client{}
+	for _, req := range []*http.Request{reqBuy, reqTransfer} {
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("could not send request: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		if err = resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}
+
+	getReport := func(token string) (*http.Response, models.ReconciliationReport) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost%s/api/reports/ledger/reconciliation", srv.Addr), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("could not send request: %v", err)
+		}
+		defer func() {
+			if err = resp.Body.Close(); err != nil {
+				log.Printf("Error closing response body: %v", err)
+			}
+		}()
+
+		var report models.ReconciliationReport
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&report); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+		}
+		return resp, report
+	}
+
+	t.Run("Ledger matches balances", func(t *testing.T) {
+		resp, report := getReport(adminToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.True(t, report.Balanced)
+		assert.Empty(t, report.Discrepancies)
+	})
+
+	t.Run("Direct balance change is reported", func(t *testing.T) {
+		db.Model(&models.User{}).Where("username = ?", "ledger_user").Update("coins", 5000)
+
+		resp, report := getReport(adminToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		if assert.Len(t, report.Discrepancies, 1) {
+			assert.Equal(t, "ledger_user", report.Discrepancies[0].Username)
+			assert.Equal(t, 5000, report.Discrepancies[0].CachedCoins)
+			assert.Equal(t, 880, report.Discrepancies[0].LedgerCoins)
+		}
+	})
+
+	t.Run("Auditor can read report", func(t *testing.T) {
+		auditorToken, err := authenticateWithRole("ledger_auditor", "auditor_pass1", models.RoleAuditor)
+		if err != nil {
+			t.Fatalf("authentication failed: %v", err)
+		}
+
+		resp, _ := getReport(auditorToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Regular user is forbidden", func(t *testing.T) {
+		resp, _ := getReport(userToken)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+// sendRequest - отправляет запрос к тестовому серверу и возвращает код ответа и тело
+func sendRequest(t *testing.T, method, path, token string, body interface{}) (int, []byte) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			t.Fatalf("could not encode request body: %v", err)
+		}
+	}
+
+	req, _ := http.NewRequest(method, fmt.Sprintf("http://localhost%s%s", srv.Addr, path), &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+	defer func() {
+		if err = resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+	return resp.StatusCode, respBody
+}
+
+func TestMerchCatalogIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass1", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	userToken, err := authenticateUser("catalog_user", "catalog_pass1")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	t.Run("Non-admin cannot create merch", func(t *testing.T) {
+		status, _ := sendRequest(t, "POST", "/api/merch", userToken, models.CreateMerchRequest{Name: "hat", Price: 100})
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("Admin creates merch", func(t *testing.T) {
+		for _, req := range []models.CreateMerchRequest{{Name: "hat", Price: 100}, {Name: "pen", Price: 10}} {
+			status, _ := sendRequest(t, "POST", "/api/merch", adminToken, req)
+			assert.Equal(t, http.StatusCreated, status)
+		}
+
+		status, _ := sendRequest(t, "POST", "/api/merch", adminToken, models.CreateMerchRequest{Name: "hat", Price: 1})
+		assert.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("Catalog is sorted by price", func(t *testing.T) {
+		status, body := sendRequest(t, "GET", "/api/merch?sort=price_desc&limit=1", userToken, nil)
+		assert.Equal(t, http.StatusOK, status)
+
+		var page models.MerchListResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		assert.Equal(t, int64(2), page.Total)
+		assert.Equal(t, []models.MerchItem{{Name: "hat", Price: 100}}, page.Items)
+	})
+
+	t.Run("Admin updates price", func(t *testing.T) {
+		price := 150
+		status, body := sendRequest(t, "PATCH", "/api/merch/hat", adminToken, models.UpdateMerchRequest{Price: &price})
+		assert.Equal(t, http.StatusOK, status)
+
+		var item models.MerchItem
+		if err := json.Unmarshal(body, &item); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		assert.Equal(t, models.MerchItem{Name: "hat", Price: 150}, item)
+	})
+
+	t.Run("Retired merch stays in inventory", func(t *testing.T) {
+		status, _ := sendRequest(t, "GET", "/api/buy/hat", userToken, nil)
+		assert.Equal(t, http.StatusOK, status)
+
+		status, _ = sendRequest(t, "DELETE", "/api/merch/hat", adminToken, nil)
+		assert.Equal(t, http.StatusNoContent, status)
+
+		status, _ = sendRequest(t, "GET", "/api/merch/hat", userToken, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = sendRequest(t, "GET", "/api/buy/hat", userToken, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, body := sendRequest(t, "GET", "/api/info", userToken, nil)
+		assert.Equal(t, http.StatusOK, status)
+
+		var info models.InfoResponse
+		if err := json.Unmarshal(body, &info); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		assert.Equal(t, []models.Item{{Type: "hat", Quantity: 1}}, info.Inventory)
+	})
+}
+
+func TestRoleChangeIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole("demoted_admin", "admin_pass1", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	status, _ := sendRequest(t, "POST", "/api/merch", adminToken, models.CreateMerchRequest{Name: "badge", Price: 15})
+	assert.Equal(t, http.StatusCreated, status)
+
+	// После смены роли токен со старой ролью перестаёт действовать
+	db.Model(&models.User{}).Where("username = ?", "demoted_admin").Update("role", models.RoleUser)
+
+	status, _ = sendRequest(t, "GET", "/api/merch", adminToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	userToken, err := authenticateUser("demoted_admin", "admin_pass1")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	status, _ = sendRequest(t, "POST", "/api/merch", userToken, models.CreateMerchRequest{Name: "pin", Price: 15})
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestJWKSIntegration(t *testing.T) {
+	status, body := sendRequest(t, "GET", "/.well-known/jwks.json", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	// HMAC-секрет не публикуется
+	var jwks models.JWKS
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Empty(t, jwks.Keys)
+}
+
+func TestRefreshTokenIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, ledger_entries, refresh_tokens RESTART IDENTITY CASCADE")
+
+	status, body := sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "session_user", Password: "session_pass1"})
+	assert.Equal(t, http.StatusOK, status)
+
+	var login models.AuthResponse
+	if err := json.Unmarshal(body, &login); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.NotEmpty(t, login.RefreshToken)
+
+	// Ротация выдаёт новую пару токенов
+	status, body = sendRequest(t, "POST", "/api/auth/refresh", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusOK, status)
+
+	var rotated models.AuthResponse
+	if err := json.Unmarshal(body, &rotated); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+
+	status, _ = sendRequest(t, "GET", "/api/info", rotated.Token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Повторное использование старого токена отзывает всю цепочку
+	status, _ = sendRequest(t, "POST", "/api/auth/refresh", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = sendRequest(t, "POST", "/api/auth/refresh", "", models.RefreshRequest{RefreshToken: rotated.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = sendRequest(t, "GET", "/api/info", rotated.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// После выхода access-токен перестаёт действовать
+	token, err := authenticateUser("session_user", "session_pass1")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	status, _ = sendRequest(t, "POST", "/api/auth/logout", token, models.LogoutRequest{AllSessions: true})
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = sendRequest(t, "GET", "/api/info", token, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestRegisterIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, ledger_entries, refresh_tokens RESTART IDENTITY CASCADE")
+
+	status, body := sendRequest(t, "POST", "/api/register", "", models.RegisterRequest{Username: "registered", Password: "password123"})
+	assert.Equal(t, http.StatusCreated, status)
+
+	var resp models.AuthResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.NotEmpty(t, resp.Token)
+
+	status, _ = sendRequest(t, "POST", "/api/register", "", models.RegisterRequest{Username: "registered", Password: "password123"})
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, _ = sendRequest(t, "POST", "/api/register", "", models.RegisterRequest{Username: "weak", Password: "password"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Автоматическая регистрация при входе подчиняется тем же правилам
+	status, body = sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "weak", Password: "password"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), errs.ErrInvalidCredentials.Error())
+	var count int64
+	db.Model(&models.User{}).Where("username = ?", "weak").Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Неверный пароль, неизвестный пользователь и отклонённая автоматическая регистрация неотличимы для клиента
+	_, wrongPassword := sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "registered", Password: "wrong_pass1"})
+	assert.Equal(t, string(body), string(wrongPassword))
+	_, weakPassword := sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "registered", Password: "password"})
+	assert.Equal(t, string(body), string(weakPassword))
+}
+
+func TestLoginLockoutIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, ledger_entries, refresh_tokens, login_attempts, grants, grant_batches RESTART IDENTITY CASCADE")
+
+	if _, err := authenticateUser("locked_user", "locked_pass1"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	for i := 0; i < testLoginGuardSettings.User.MaxAttempts; i++ {
+		status, _ := sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "locked_user", Password: "wrong_pass1"})
+		assert.Equal(t, http.StatusBadRequest, status)
+	}
+
+	// Заблокированный аккаунт не пускает даже с верным паролем
+	status, _ := sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "locked_user", Password: "locked_pass1"})
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	status, _ = sendRequest(t, "DELETE", "/api/admin/users/locked_user/lockout", adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = sendRequest(t, "POST", "/api/auth", "", models.AuthRequest{Username: "locked_user", Password: "locked_pass1"})
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestHistoryIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, purchases, merches, ledger_entries, idempotency_keys RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("history_user", "history_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("history_peer", "history_pass"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	db.Create(&models.Merch{Name: "cup", Price: 20})
+
+	for _, amount := range []int{100, 50} {
+		status, _ := sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "history_peer", Amount: amount})
+		assert.Equal(t, http.StatusOK, status)
+	}
+	status, _ := sendRequest(t, "GET", "/api/buy/cup", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Проходим всю историю страницами по две записи
+	var entries []models.HistoryEntry
+	path := "/api/history?limit=2"
+	for page := 0; page < 3; page++ {
+		status, body := sendRequest(t, "GET", path, token, nil)
+		assert.Equal(t, http.StatusOK, status)
+
+		var resp models.HistoryResponse
+		if err = json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		entries = append(entries, resp.Entries...)
+		if resp.NextCursor == "" {
+			break
+		}
+		path = "/api/history?limit=2&cursor=" + resp.NextCursor
+	}
+
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, models.HistoryTypePurchase, entries[0].Type)
+		assert.Equal(t, "cup", entries[0].Item)
+		assert.Equal(t, 20, entries[0].Amount)
+		for _, entry := range entries {
+			assert.False(t, entry.CreatedAt.IsZero())
+		}
+	}
+
+	// Фильтры по направлению, контрагенту и сумме
+	status, body := sendRequest(t, "GET", "/api/history?direction=sent&counterparty=history_peer&minAmount=60", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var filtered models.HistoryResponse
+	if err = json.Unmarshal(body, &filtered); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, filtered.Entries, 1) {
+		assert.Equal(t, 100, filtered.Entries[0].Amount)
+	}
+
+	status, _ = sendRequest(t, "GET", "/api/history?direction=deposits", token, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+// readHistory - проходит всю историю пользователя страницами по limit записей, следуя nextCursor
+func readHistory(t *testing.T, token string, limit int) []models.HistoryEntry {
+	t.Helper()
+
+	var entries []models.HistoryEntry
+	path := fmt.Sprintf("/api/history?limit=%d", limit)
+	for {
+		status, body := sendRequest(t, "GET", path, token, nil)
+		if !assert.Equal(t, http.StatusOK, status, "page %q: %s", path, body) {
+			return entries
+		}
+
+		var resp models.HistoryResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		entries = append(entries, resp.Entries...)
+		if resp.NextCursor == "" {
+			return entries
+		}
+		path = fmt.Sprintf("/api/history?limit=%d&cursor=%s", limit, resp.NextCursor)
+	}
+}
+
+func TestErrorResponseIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("poor_user", "poor_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	db.Create(&models.Merch{Name: "yacht", Price: 1_000_000})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		body        interface{}
+		expectedErr *errs.Error
+	}{
+		{
+			name:        "Not enough coins",
+			method:      "GET",
+			path:        "/api/buy/yacht",
+			token:       token,
+			expectedErr: errs.ErrNotEnoughCoins,
+		},
+		{
+			name:        "Unknown merch",
+			method:      "GET",
+			path:        "/api/buy/unknown",
+			token:       token,
+			expectedErr: errs.ErrMerchNotFound,
+		},
+		{
+			name:        "Transfer to unknown user",
+			method:      "POST",
+			path:        "/api/sendCoin",
+			token:       token,
+			body:        models.SendCoinRequest{ToUser: "nobody", Amount: 10},
+			expectedErr: errs.ErrUserNotFound,
+		},
+		{
+			name:        "Missing token",
+			method:      "GET",
+			path:        "/api/info",
+			expectedErr: errs.ErrUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := sendRequest(t, tt.method, tt.path, tt.token, tt.body)
+			assert.Equal(t, tt.expectedErr.Status, status)
+
+			var problem models.ErrorResponse
+			if err := json.Unmarshal(body, &problem); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+			assert.Equal(t, tt.expectedErr.Code, problem.Code)
+			assert.Equal(t, tt.expectedErr.Status, problem.Status)
+			assert.Equal(t, tt.path, problem.Instance)
+			// Старое поле errors сохраняется для совместимости
+			assert.Equal(t, tt.expectedErr.Message, problem.Errors)
+		})
+	}
+}
+
+func TestCreateOrderIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, orders, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("bulk_buyer", "bulk_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	db.Create(&models.Merch{Name: "pen", Price: 10})
+	db.Create(&models.Merch{Name: "cup", Price: 20})
+
+	// Неизвестный товар отменяет весь заказ
+	status, _ := sendRequest(t, "POST", "/api/orders", token, models.CreateOrderRequest{Items: []models.OrderItemRequest{
+		{Item: "pen", Quantity: 1},
+		{Item: "yacht", Quantity: 1},
+	}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Сумма заказа больше баланса
+	status, _ = sendRequest(t, "POST", "/api/orders", token, models.CreateOrderRequest{Items: []models.OrderItemRequest{
+		{Item: "pen", Quantity: 50},
+		{Item: "cup", Quantity: 30},
+	}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body := sendRequest(t, "POST", "/api/orders", token, models.CreateOrderRequest{Items: []models.OrderItemRequest{
+		{Item: "pen", Quantity: 5},
+		{Item: "cup", Quantity: 2},
+	}})
+	assert.Equal(t, http.StatusCreated, status)
+
+	var order models.OrderResponse
+	if err = json.Unmarshal(body, &order); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 90, order.Total)
+	assert.Len(t, order.Items, 2)
+
+	// Одиночная покупка добавляется к тем же строкам инвентаря
+	status, _ = sendRequest(t, "GET", "/api/buy/pen", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = sendRequest(t, "GET", "/api/info", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var info models.InfoResponse
+	if err = json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 1000-90-10, info.Coins)
+	assert.ElementsMatch(t, []models.Item{{Type: "pen", Quantity: 6}, {Type: "cup", Quantity: 2}}, info.Inventory)
+}
+
+func TestMerchStockIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, orders, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	stock, limit := 3, 2
+	status, _ := sendRequest(t, "POST", "/api/merch", adminToken, models.CreateMerchRequest{Name: "pink-hoody", Price: 100, Stock: &stock, PerUserLimit: &limit})
+	assert.Equal(t, http.StatusCreated, status)
+
+	// Параллельные покупки не продают больше остатка
+	const buyers = 6
+	tokens := make([]string, buyers)
+	for i := range tokens {
+		if tokens[i], err = authenticateUser(fmt.Sprintf("stock_buyer_%d", i), "stock_pass"); err != nil {
+			t.Fatalf("authentication failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	statuses := make([]int, buyers)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], _ = sendRequest(t, "GET", "/api/buy/pink-hoody", tokens[i], nil)
+		}(i)
+	}
+	wg.Wait()
+
+	sold := 0
+	for _, s := range statuses {
+		if s == http.StatusOK {
+			sold++
+		} else {
+			assert.Equal(t, http.StatusConflict, s)
+		}
+	}
+	assert.Equal(t, stock, sold)
+
+	var merch models.Merch
+	db.Where("name = ?", "pink-hoody").First(&merch)
+	if assert.NotNil(t, merch.Stock) {
+		assert.Equal(t, 0, *merch.Stock)
+	}
+
+	// Пополнение склада доступно администратору
+	add := 10
+	status, _ = sendRequest(t, "POST", "/api/merch/pink-hoody/stock", tokens[0], models.StockRequest{Add: &add})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = sendRequest(t, "POST", "/api/merch/pink-hoody/stock", adminToken, models.StockRequest{Add: &add})
+	assert.Equal(t, http.StatusOK, status)
+
+	// Лимит на пользователя учитывает прежние покупки
+	status, body := sendRequest(t, "POST", "/api/orders", adminToken, models.CreateOrderRequest{Items: []models.OrderItemRequest{{Item: "pink-hoody", Quantity: 3}}})
+	assert.Equal(t, http.StatusConflict, status)
+
+	var problem models.ErrorResponse
+	if err = json.Unmarshal(body, &problem); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, errs.ErrPurchaseLimit.Code, problem.Code)
+}
+
+func TestRefundIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, orders, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("refund_buyer", "refund_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	otherToken, err := authenticateUser("refund_other", "refund_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	stock := 5
+	db.Create(&models.Merch{Name: "pen", Price: 10, Stock: &stock})
+	db.Create(&models.Merch{Name: "cup", Price: 20})
+
+	status, body := sendRequest(t, "POST", "/api/orders", token, models.CreateOrderRequest{Items: []models.OrderItemRequest{
+		{Item: "pen", Quantity: 2},
+		{Item: "cup", Quantity: 1},
+	}})
+	assert.Equal(t, http.StatusCreated, status)
+
+	var order models.OrderResponse
+	if err = json.Unmarshal(body, &order); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	penPurchase, cupPurchase := order.Items[0].PurchaseID, order.Items[1].PurchaseID
+
+	// Чужую покупку вернуть нельзя
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/purchases/%d/refund", penPurchase), otherToken, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = sendRequest(t, "POST", fmt.Sprintf("/api/purchases/%d/refund", penPurchase), token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var refund models.RefundResponse
+	if err = json.Unmarshal(body, &refund); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 20, refund.Amount)
+	assert.Equal(t, 1000-20, refund.Balance)
+
+	// Повторный возврат отклоняется
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/purchases/%d/refund", penPurchase), token, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	// Предметы вернулись на склад
+	var merch models.Merch
+	db.Where("name = ?", "pen").First(&merch)
+	if assert.NotNil(t, merch.Stock) {
+		assert.Equal(t, stock, *merch.Stock)
+	}
+
+	// По истечении срока пользователь вернуть покупку не может, администратор — может
+	db.Model(&models.Purchase{}).Where("id = ?", cupPurchase).Update("created_at", time.Now().Add(-time.Hour))
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/purchases/%d/refund", cupPurchase), token, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/purchases/%d/refund", cupPurchase), adminToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = sendRequest(t, "GET", "/api/info", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var info models.InfoResponse
+	if err = json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 1000, info.Coins)
+	assert.Empty(t, info.Inventory)
+
+	// Возвраты отображаются в истории, а покупки остаются в ней
+	status, body = sendRequest(t, "GET", "/api/history?direction=refunds", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var history models.HistoryResponse
+	if err = json.Unmarshal(body, &history); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, history.Entries, 2) {
+		assert.Equal(t, models.HistoryTypeRefund, history.Entries[0].Type)
+		assert.Equal(t, cupPurchase, history.Entries[0].ID)
+		assert.Equal(t, 20, history.Entries[0].Amount)
+	}
+
+	status, body = sendRequest(t, "GET", "/api/history?direction=purchases", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+	if err = json.Unmarshal(body, &history); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Len(t, history.Entries, 2)
+
+	// Страницы по одной записи: курсор страницы, заканчивающейся возвратом, принимается
+	entries := readHistory(t, token, 1)
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, models.HistoryTypeRefund, entries[0].Type)
+	}
+
+	// Журнал остаётся сбалансированным
+	var imbalance int
+	db.Raw("SELECT COALESCE(SUM(delta), 0) FROM ledger_entries").Scan(&imbalance)
+	assert.Equal(t, 0, imbalance)
+}
+
+func TestGrantCoinsIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, grants, grant_batches, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	token, err := authenticateUser("grant_alice", "grant_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("grant_bob", "grant_pass"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	// Корректировка доступна только администратору и требует обоснования
+	status, _ := sendRequest(t, "POST", "/api/admin/users/grant_bob/balance", token, models.AdjustBalanceRequest{Amount: 100, Reason: "self"})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = sendRequest(t, "POST", "/api/admin/users/grant_bob/balance", adminToken, models.AdjustBalanceRequest{Amount: 100})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body := sendRequest(t, "POST", "/api/admin/users/grant_alice/balance", adminToken, models.AdjustBalanceRequest{Amount: -300, Reason: "ошибочное начисление"})
+	assert.Equal(t, http.StatusOK, status)
+
+	var result models.GrantResult
+	if err = json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 700, result.Balance)
+
+	// Списание не уводит баланс в минус
+	status, _ = sendRequest(t, "POST", "/api/admin/users/grant_alice/balance", adminToken, models.AdjustBalanceRequest{Amount: -1000, Reason: "штраф"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Пробный запуск ничего не меняет
+	grants := models.GrantRequest{Reason: "квартальное пополнение", Grants: []models.GrantLine{
+		{Username: "grant_alice", Amount: 50},
+		{Username: "grant_bob", Amount: 150},
+	}}
+	status, body = sendRequest(t, "POST", "/api/admin/grants?dryRun=true", adminToken, grants)
+	assert.Equal(t, http.StatusOK, status)
+
+	var batch models.GrantBatchResponse
+	if err = json.Unmarshal(body, &batch); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.True(t, batch.DryRun)
+	assert.Equal(t, 200, batch.Total)
+	assert.Equal(t, 750, batch.Grants[0].Balance)
+
+	var count int64
+	db.Model(&models.Grant{}).Where("batch_id IS NOT NULL").Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Неизвестный получатель отменяет весь пакет
+	status, _ = sendRequest(t, "POST", "/api/admin/grants", adminToken, models.GrantRequest{Reason: "пополнение", Grants: []models.GrantLine{
+		{Username: "grant_bob", Amount: 150},
+		{Username: "grant_nobody", Amount: 150},
+	}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Зачисление из CSV
+	req, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost%s/api/admin/grants?reason=%s", srv.Addr, "q3"),
+		bytes.NewBufferString("username,amount\ngrant_alice,50\ngrant_bob,150\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	if err = resp.Body.Close(); err != nil {
+		log.Printf("Error closing response body: %v", err)
+	}
+
+	var bob models.User
+	db.Where("username = ?", "grant_bob").First(&bob)
+	assert.Equal(t, 1150, bob.Coins)
+
+	// Начисления отображаются в истории отдельным видом операций
+	status, body = sendRequest(t, "GET", "/api/history?direction=grants", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var history models.HistoryResponse
+	if err = json.Unmarshal(body, &history); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, history.Entries, 2) {
+		assert.Equal(t, models.HistoryTypeGrant, history.Entries[0].Type)
+		assert.Equal(t, 50, history.Entries[0].Amount)
+		assert.Equal(t, "q3", history.Entries[0].Reason)
+		assert.Equal(t, -300, history.Entries[1].Amount)
+	}
+
+	// Страницы по одной записи: курсор страницы, заканчивающейся начислением, принимается
+	entries := readHistory(t, token, 1)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.HistoryTypeGrant, entries[1].Type)
+	}
+
+	// Журнал остаётся сбалансированным
+	var imbalance int
+	db.Raw("SELECT COALESCE(SUM(delta), 0) FROM ledger_entries").Scan(&imbalance)
+	assert.Equal(t, 0, imbalance)
+}
+
+func TestEconomySettingsIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, ledger_entries RESTART IDENTITY CASCADE")
+
+	adminToken, err := authenticateWithRole(testAdminUsername, "admin_pass", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	// Возвращаем настройки по умолчанию, чтобы не влиять на другие тесты
+	defer sendRequest(t, "PUT", "/api/admin/economy", adminToken, models.DefaultEconomySettings())
+
+	token, err := authenticateUser("economy_sender", "economy_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	// Настройки меняет только администратор, несогласованные значения отклоняются
+	maxTransfer, dailyCap, maxBalance := 100, 150, 1500
+	settings := models.EconomySettings{WelcomeBonus: 200, MinTransfer: 10, MaxTransfer: &maxTransfer, DailyTransferCap: &dailyCap, MaxBalance: &maxBalance}
+	status, _ := sendRequest(t, "PUT", "/api/admin/economy", token, settings)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = sendRequest(t, "PUT", "/api/admin/economy", adminToken, models.EconomySettings{WelcomeBonus: 200})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = sendRequest(t, "PUT", "/api/admin/economy", adminToken, settings)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Новый пользователь получает приветственный бонус из настроек
+	receiverToken, err := authenticateUser("economy_receiver", "economy_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	status, body := sendRequest(t, "GET", "/api/info", receiverToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var info models.InfoResponse
+	if err = json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 200, info.Coins)
+
+	codeOf := func(body []byte) string {
+		var problem models.ErrorResponse
+		if err := json.Unmarshal(body, &problem); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return problem.Code
+	}
+
+	status, body = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "economy_receiver", Amount: 5})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errs.ErrTransferTooSmall.Code, codeOf(body))
+
+	status, body = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "economy_receiver", Amount: 101})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errs.ErrTransferTooLarge.Code, codeOf(body))
+
+	status, _ = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "economy_receiver", Amount: 100})
+	assert.Equal(t, http.StatusOK, status)
+
+	// Суточный лимит учитывает уже отправленные сегодня монеты
+	status, body = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "economy_receiver", Amount: 60})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, errs.ErrDailyTransferLimit.Code, codeOf(body))
+
+	// Получатель не может превысить максимальный баланс
+	db.Model(&models.User{}).Where("username = ?", "economy_sender").Update("coins", 1450)
+	status, body = sendRequest(t, "POST", "/api/sendCoin", receiverToken, models.SendCoinRequest{ToUser: "economy_sender", Amount: 100})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, errs.ErrBalanceLimit.Code, codeOf(body))
+
+	// Начисления администратора тоже не поднимают баланс выше максимального
+	status, body = sendRequest(t, "POST", "/api/admin/users/economy_sender/balance", adminToken, models.AdjustBalanceRequest{Amount: 100, Reason: "премия"})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, errs.ErrBalanceLimit.Code, codeOf(body))
+
+	grants := models.GrantRequest{Reason: "премия", Grants: []models.GrantLine{{Username: "economy_receiver", Amount: 10}, {Username: "economy_sender", Amount: 100}}}
+	status, body = sendRequest(t, "POST", "/api/admin/grants", adminToken, grants)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, errs.ErrBalanceLimit.Code, codeOf(body))
+
+	// Пакет отменён целиком: получатель первой строки тоже не получил монет
+	var receiver models.User
+	db.First(&receiver, "username = ?", "economy_receiver")
+	assert.Equal(t, 300, receiver.Coins)
+}
+
+func TestTransferMessageIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("message_sender", "message_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	receiverToken, err := authenticateUser("message_receiver", "message_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	status, _ := sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "message_receiver", Amount: 10, Category: "bribe"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{
+		ToUser:   "message_receiver",
+		Amount:   10,
+		Message:  "  Спасибо\nза ревью!  ",
+		Category: models.TransferCategoryThanks,
+	})
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "message_receiver", Amount: 20})
+	assert.Equal(t, http.StatusOK, status)
+
+	// Сообщение и категория возвращаются в истории /api/info
+	status, body := sendRequest(t, "GET", "/api/info", receiverToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var info models.InfoResponse
+	if err = json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, info.CoinHistory.Received, 2) {
+		assert.Equal(t, "Спасибо за ревью!", info.CoinHistory.Received[1].Message)
+		assert.Equal(t, models.TransferCategoryThanks, info.CoinHistory.Received[1].Category)
+	}
+
+	// и в постраничной истории с фильтром по категории
+	status, body = sendRequest(t, "GET", "/api/history?category=thanks", receiverToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var history models.HistoryResponse
+	if err = json.Unmarshal(body, &history); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, history.Entries, 1) {
+		assert.Equal(t, models.HistoryTypeReceived, history.Entries[0].Type)
+		assert.Equal(t, 10, history.Entries[0].Amount)
+		assert.Equal(t, "Спасибо за ревью!", history.Entries[0].Message)
+	}
+}
+
+func TestScheduledTransferIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, ledger_entries, scheduled_transfers, scheduled_runs RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("schedule_lead", "schedule_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("schedule_member", "schedule_pass"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	// Ежемесячный перевод и разовый перевод на сумму больше баланса
+	status, body := sendRequest(t, "POST", "/api/scheduled-transfers", token, models.ScheduleTransferRequest{
+		ToUser: "schedule_member", Amount: 100, Cron: "0 9 1 * *", Category: models.TransferCategoryThanks,
+	})
+	assert.Equal(t, http.StatusCreated, status)
+	var monthly models.ScheduledTransferResponse
+	if err = json.Unmarshal(body, &monthly); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, models.ScheduleStatusActive, monthly.Status)
+
+	runAt := time.Now().Add(time.Hour)
+	status, body = sendRequest(t, "POST", "/api/scheduled-transfers", token, models.ScheduleTransferRequest{
+		ToUser: "schedule_member", Amount: 5000, RunAt: &runAt,
+	})
+	assert.Equal(t, http.StatusCreated, status)
+	var oneOff models.ScheduledTransferResponse
+	if err = json.Unmarshal(body, &oneOff); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	status, _ = sendRequest(t, "POST", "/api/scheduled-transfers", token, models.ScheduleTransferRequest{ToUser: "schedule_member", Amount: 10, Cron: "not a cron"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Переносим оба перевода в прошлое и запускаем обработчик с двух «экземпляров» одновременно
+	db.Model(&models.ScheduledTransfer{}).Where("id IN ?", []uint{monthly.ID, oneOff.ID}).Update("next_run_at", time.Now().Add(-time.Minute))
+
+	var wg sync.WaitGroup
+	executed := make([]int, 2)
+	for i := range executed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			executed[i], _ = schedulerService.RunDue(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 2, executed[0]+executed[1])
+
+	// Каждый перевод выполнен ровно один раз
+	var transfers int64
+	db.Model(&models.Transaction{}).Count(&transfers)
+	assert.EqualValues(t, 1, transfers)
+
+	var member models.User
+	db.Where("username = ?", "schedule_member").First(&member)
+	assert.Equal(t, 1100, member.Coins)
+
+	// Повторный запуск ничего не выполняет: следующий ежемесячный перевод ещё не наступил
+	again, err := schedulerService.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, again)
+
+	// Итоги запусков видны владельцу
+	status, body = sendRequest(t, "GET", "/api/scheduled-transfers", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+	var list []models.ScheduledTransferResponse
+	if err = json.Unmarshal(body, &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, models.ScheduleStatusFailed, list[0].Status)
+		assert.Equal(t, "NOT_ENOUGH_COINS", list[0].LastError)
+		assert.Equal(t, models.ScheduleStatusActive, list[1].Status)
+		assert.Equal(t, models.RunStatusSucceeded, list[1].LastStatus)
+		assert.Equal(t, 1, list[1].RunCount)
+		assert.True(t, list[1].NextRunAt.After(time.Now()))
+	}
+
+	status, body = sendRequest(t, "GET", fmt.Sprintf("/api/scheduled-transfers/%d/runs", oneOff.ID), token, nil)
+	assert.Equal(t, http.StatusOK, status)
+	var runs []models.ScheduledRunResponse
+	if err = json.Unmarshal(body, &runs); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, models.RunStatusFailed, runs[0].Status)
+		assert.Equal(t, "NOT_ENOUGH_COINS", runs[0].ErrorCode)
+	}
+
+	// Отмена: повторная отмена и отмена завершённого перевода отклоняются
+	status, _ = sendRequest(t, "DELETE", fmt.Sprintf("/api/scheduled-transfers/%d", monthly.ID), token, nil)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = sendRequest(t, "DELETE", fmt.Sprintf("/api/scheduled-transfers/%d", monthly.ID), token, nil)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = sendRequest(t, "DELETE", fmt.Sprintf("/api/scheduled-transfers/%d", oneOff.ID), token, nil)
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestCoinRequestIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, transactions, ledger_entries, coin_requests RESTART IDENTITY CASCADE")
+
+	requesterToken, err := authenticateUser("request_author", "request_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	payerToken, err := authenticateUser("request_payer", "request_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	createRequest := func(amount int) models.CoinRequestResponse {
+		status, body := sendRequest(t, "POST", "/api/coin-requests", requesterToken, models.CreateCoinRequestRequest{FromUser: "request_payer", Amount: amount, Note: "За обед"})
+		assert.Equal(t, http.StatusCreated, status)
+
+		var request models.CoinRequestResponse
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return request
+	}
+	accepted, declined, expired := createRequest(150), createRequest(50), createRequest(20)
+
+	// Срок третьего запроса истёк
+	db.Model(&models.CoinRequest{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+	// Плательщик видит два ожидающих запроса
+	status, body := sendRequest(t, "GET", "/api/coin-requests?status=pending", payerToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+	var incoming []models.CoinRequestResponse
+	if err = json.Unmarshal(body, &incoming); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Len(t, incoming, 2)
+
+	// Запросившему нельзя принять собственный запрос
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/coin-requests/%d/accept", accepted.ID), requesterToken, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Параллельные попытки оплатить запрос переводят монеты один раз
+	var wg sync.WaitGroup
+	statuses := make([]int, 3)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], _ = sendRequest(t, "POST", fmt.Sprintf("/api/coin-requests/%d/accept", accepted.ID), payerToken, nil)
+		}(i)
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, []int{http.StatusOK, http.StatusConflict, http.StatusConflict}, statuses)
+
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/coin-requests/%d/decline", declined.ID), payerToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/coin-requests/%d/accept", declined.ID), payerToken, nil)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/coin-requests/%d/accept", expired.ID), payerToken, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	var payer, requester models.User
+	db.Where("username = ?", "request_payer").First(&payer)
+	db.Where("username = ?", "request_author").First(&requester)
+	assert.Equal(t, 850, payer.Coins)
+	assert.Equal(t, 1150, requester.Coins)
+
+	// Запросивший видит итог каждого запроса
+	status, body = sendRequest(t, "GET", "/api/coin-requests?direction=outgoing", requesterToken, nil)
+	assert.Equal(t, http.StatusOK, status)
+	var outgoing []models.CoinRequestResponse
+	if err = json.Unmarshal(body, &outgoing); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if assert.Len(t, outgoing, 3) {
+		assert.Equal(t, models.CoinRequestExpired, outgoing[0].Status)
+		assert.Equal(t, models.CoinRequestDeclined, outgoing[1].Status)
+		assert.Equal(t, models.CoinRequestAccepted, outgoing[2].Status)
+	}
+
+	status, _ = sendRequest(t, "GET", "/api/coin-requests?direction=sideways", requesterToken, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHoldIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, orders, transactions, ledger_entries, holds RESTART IDENTITY CASCADE")
+	db.Create(&models.Merch{Name: "hold-hoody", Price: 300})
+
+	token, err := authenticateUser("bounty_owner", "bounty_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("bounty_hunter", "bounty_pass"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	createHold := func(amount int) models.HoldResponse {
+		status, body := sendRequest(t, "POST", "/api/holds", token, models.CreateHoldRequest{Amount: amount, Note: "Починить сборку"})
+		assert.Equal(t, http.StatusCreated, status)
+
+		var hold models.HoldResponse
+		if err := json.Unmarshal(body, &hold); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return hold
+	}
+	released, returned, expired := createHold(400), createHold(200), createHold(150)
+
+	// Удержанные монеты недоступны для переводов и покупок
+	status, body := sendRequest(t, "GET", "/api/info", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+	var info models.InfoResponse
+	if err = json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	assert.Equal(t, 250, info.Coins)
+	assert.Equal(t, 750, info.HeldCoins)
+	assert.Len(t, info.Holds, 3)
+
+	status, _ = sendRequest(t, "POST", "/api/sendCoin", token, models.SendCoinRequest{ToUser: "bounty_hunter", Amount: 300})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = sendRequest(t, "GET", "/api/buy/hold-hoody", token, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Передача получателю, возврат владельцу и автоматический возврат по истечении срока
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/holds/%d/release", released.ID), token, models.ReleaseHoldRequest{ToUser: "bounty_hunter"})
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/holds/%d/release", released.ID), token, models.ReleaseHoldRequest{ToUser: "bounty_hunter"})
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = sendRequest(t, "POST", fmt.Sprintf("/api/holds/%d/return", returned.ID), token, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	db.Model(&models.Hold{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	count, err := userService.ExpireHolds(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var owner, hunter models.User
+	db.Where("username = ?", "bounty_owner").First(&owner)
+	db.Where("username = ?", "bounty_hunter").First(&hunter)
+	assert.Equal(t, 600, owner.Coins)
+	assert.Equal(t, 0, owner.HeldCoins)
+	assert.Equal(t, 1400, hunter.Coins)
+
+	var hold models.Hold
+	db.First(&hold, expired.ID)
+	assert.Equal(t, models.HoldExpired, hold.Status)
+
+	// Счёт удержаний после всех операций пуст
+	var escrow int
+	db.Model(&models.LedgerEntry{}).Where("account = ?", models.AccountEscrow).Select("COALESCE(SUM(delta), 0)").Scan(&escrow)
+	assert.Equal(t, 0, escrow)
+}
+
+func TestEconomyConstraintsIntegration(t *testing.T) {
+	// Очищаем данные перед тестом
+	db.Exec("TRUNCATE users, merches, purchases, orders, transactions, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("constraint_user", "constraint_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	var user models.User
+	db.Where("username = ?", "constraint_user").First(&user)
+
+	// Запись в обход сервисов всё равно не нарушает инварианты: ошибки схемы переводятся в ошибки errs
+	tests := []struct {
+		name    string
+		write   func() error
+		wantErr error
+	}{
+		{
+			name: "отрицательный баланс",
+			write: func() error {
+				return db.Model(&models.User{}).Where("id = ?", user.ID).Update("coins", -1).Error
+			},
+			wantErr: errs.ErrNotEnoughCoins,
+		},
+		{
+			name: "нулевая цена товара",
+			write: func() error {
+				return db.Create(&models.Merch{Name: "free-sticker", Price: 0}).Error
+			},
+			wantErr: errs.ErrInvalidPrice,
+		},
+		{
+			name: "перевод самому себе",
+			write: func() error {
+				return db.Create(&models.Transaction{SenderId: user.ID, ReceiverId: user.ID, Amount: 10}).Error
+			},
+			wantErr: errs.ErrSendCoinsToYourself,
+		},
+		{
+			name: "перевод на неположительную сумму",
+			write: func() error {
+				return db.Exec("INSERT INTO transactions (sender_id, receiver_id, amount) VALUES (?, ?, 0)", user.ID, user.ID+1).Error
+			},
+			wantErr: errs.ErrNegativeCoins,
+		},
+		{
+			name: "перевод несуществующему пользователю",
+			write: func() error {
+				return db.Create(&models.Transaction{SenderId: user.ID, ReceiverId: user.ID + 100, Amount: 10}).Error
+			},
+			wantErr: errs.ErrUserNotFound,
+		},
+		{
+			name: "повторное имя пользователя",
+			write: func() error {
+				return db.Create(&models.User{Username: "constraint_user", Password: "x"}).Error
+			},
+			wantErr: errs.ErrUserAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.write(), tt.wantErr)
+		})
+	}
+
+	// Жёсткое удаление пользователя с денежной историей запрещено
+	db.Create(&models.Merch{Name: "constraint-cup", Price: 20})
+	status, _ := sendRequest(t, "GET", "/api/buy/constraint-cup", token, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Error(t, db.Unscoped().Delete(&models.User{}, user.ID).Error)
+
+	// Индексы для выборок истории по участнику
+	for _, index := range []string{"idx_transactions_sender_id", "idx_transactions_receiver_id", "idx_purchases_user_id"} {
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM pg_indexes WHERE indexname = ?", index).Scan(&count)
+		assert.Equal(t, int64(1), count, index)
+	}
+}
+
+func TestHealthIntegration(t *testing.T) {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	readiness := func() (int, models.HealthResponse) {
+		status, body := sendRequest(t, "GET", "/readyz", "", nil)
+		var resp models.HealthResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return status, resp
+	}
+
+	status, _ := sendRequest(t, "GET", "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, resp := readiness()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"database": "ok", "migrations": "ok"}, resp.Checks)
+
+	// Пока не применена последняя миграция, экземпляр не готов принимать запросы, но остаётся живым
+	_, err = migrator.Down(1)
+	assert.NoError(t, err)
+	status, resp = readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "failed", resp.Checks["migrations"])
+	status, _ = sendRequest(t, "GET", "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	status, _ = readiness()
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestRequestTimeoutIntegration(t *testing.T) {
+	db.Exec("TRUNCATE users, transactions, idempotency_keys, ledger_entries RESTART IDENTITY CASCADE")
+
+	token, err := authenticateUser("timeout_sender", "sender_pass")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if _, err = authenticateUser("timeout_receiver", "receiver_pass"); err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+
+	// Тот же перевод, что и /api/sendCoin, но с коротким сроком обработки
+	shopHandler := handlers.NewShopHandler(userService, services.NewMerchService(repositories.NewMerchRepo(db)))
+	idempotent := middleware.IdempotencyMiddleware(services.NewIdempotencyService(repositories.NewIdempotencyRepo(db), time.Hour, time.Minute))
+	handler := middleware.Timeout(300 * time.Millisecond)(middleware.AuthMiddleware(userService)(idempotent(http.HandlerFunc(shopHandler.SendCoin))))
+
+	sendCoin := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SendCoinRequest{ToUser: "timeout_receiver", Amount: 100})
+		req := httptest.NewRequest("POST", "/api/sendCoin", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "timeout-transfer")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Другая транзакция держит блокировку отправителя: перевод ждёт её и отменяется по истечении срока
+	tx := db.Begin()
+	tx.Exec("SELECT id FROM users WHERE username = ? FOR UPDATE", "timeout_sender")
+
+	started := time.Now()
+	rec := sendCoin()
+	tx.Rollback()
+
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var errResp models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Equal(t, errs.ErrRequestTimeout.Code, errResp.Code)
+
+	var sender models.User
+	db.First(&sender, "username = ?", "timeout_sender")
+	assert.Equal(t, 1000, sender.Coins)
+
+	// Ключ идемпотентности освобождён, поэтому повтор выполняет перевод
+	rec = sendCoin()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	db.First(&sender, "username = ?", "timeout_sender")
+	assert.Equal(t, 900, sender.Coins)
+}
+
+func TestMigrationsIntegration(t *testing.T) {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	// Одновременный запуск с нескольких экземпляров не приводит к ошибкам: миграции применяются по очереди
+	var wg sync.WaitGroup
+	results := make([]error, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, results[i] = migrator.Up()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range results {
+		assert.NoError(t, err)
+	}
+
+	pending, err := migrator.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pending)
+
+	// Откат и повторное применение последней миграции
+	reverted, err := migrator.Down(1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	pending, err = migrator.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pending)
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, reverted, applied)
+
+	// Схема после миграций содержит все таблицы и колонки моделей
+	for _, model := range []interface{}{&models.User{}, &models.Merch{}, &models.Purchase{}, &models.Order{}, &models.Transaction{},
+		&models.IdempotencyKey{}, &models.LedgerEntry{}, &models.RefreshToken{}, &models.LoginAttempt{}, &models.Grant{},
+		&models.GrantBatch{}, &models.EconomySettings{}, &models.ScheduledTransfer{}, &models.ScheduledRun{},
+		&models.CoinRequest{}, &models.Hold{}} {
+		stmt := &gorm.Statement{DB: db}
+		if err = stmt.Parse(model); err != nil {
+			t.Fatalf("could not parse model: %v", err)
+		}
+		if !assert.True(t, db.Migrator().HasTable(stmt.Schema.Table), "table %s", stmt.Schema.Table) {
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			assert.True(t, db.Migrator().HasColumn(model, field.DBName), "column %s.%s", stmt.Schema.Table, field.DBName)
+		}
+	}
+}
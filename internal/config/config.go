@@ -0,0 +1,306 @@
+// Package config - настройки сервера из переменных окружения, необязательного .env и YAML-файла
+package config
+
+import (
+	"fmt"
+	"merch-shop/internal/models"
+	"os"
+	"sort"
+	"time"
+)
+
+// minJWTSecretLength - минимальная длина HMAC-секрета, как в services.NewJWTKeys
+const minJWTSecretLength = 16
+
+// Config - все настройки сервера. Для каждого значения указаны ключ YAML-файла и переменная окружения.
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Auth       AuthConfig       `yaml:"auth"`
+	JWT        JWTConfig        `yaml:"jwt"`
+	LoginGuard LoginGuardConfig `yaml:"loginGuard"`
+	Shop       ShopConfig       `yaml:"shop"`
+	Economy    EconomyConfig    `yaml:"economy"`
+	Holds      HoldsConfig      `yaml:"holds"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler"`
+	Migrations MigrationsConfig `yaml:"migrations"`
+}
+
+// ServerConfig - HTTP-сервер
+type ServerConfig struct {
+	Port            string        `yaml:"port" env:"SERVER_PORT"`                        // Адрес прослушивания, например :8080
+	RequestTimeout  time.Duration `yaml:"requestTimeout" env:"SERVER_REQUEST_TIMEOUT"`   // Сколько обрабатывается обычный запрос к API
+	ReportTimeout   time.Duration `yaml:"reportTimeout" env:"SERVER_REPORT_TIMEOUT"`     // То же для отчётов и массовых начислений
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT"` // Сколько при остановке ждать завершения текущих запросов
+}
+
+// DatabaseConfig - подключение к Postgres, пул соединений и повторные попытки подключения при запуске
+type DatabaseConfig struct {
+	Host     string `yaml:"host" env:"DATABASE_HOST"`
+	Port     int    `yaml:"port" env:"DATABASE_PORT"`
+	User     string `yaml:"user" env:"DATABASE_USER"`
+	Password string `yaml:"password" env:"DATABASE_PASSWORD"`
+	Name     string `yaml:"name" env:"DATABASE_NAME"`
+	SSLMode  string `yaml:"sslMode" env:"DATABASE_SSLMODE"`
+
+	MaxOpenConns     int           `yaml:"maxOpenConns" env:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns     int           `yaml:"maxIdleConns" env:"DATABASE_MAX_IDLE_CONNS"`
+	ConnMaxLifetime  time.Duration `yaml:"connMaxLifetime" env:"DATABASE_CONN_MAX_LIFETIME"`  // 0 - соединения не пересоздаются по возрасту
+	ConnMaxIdleTime  time.Duration `yaml:"connMaxIdleTime" env:"DATABASE_CONN_MAX_IDLE_TIME"` // 0 - простаивающие соединения не закрываются
+	StatementTimeout time.Duration `yaml:"statementTimeout" env:"DATABASE_STATEMENT_TIMEOUT"` // 0 - без ограничения времени запроса
+
+	ConnectAttempts   int           `yaml:"connectAttempts" env:"DATABASE_CONNECT_ATTEMPTS"`      // Попыток подключения при запуске
+	ConnectBackoff    time.Duration `yaml:"connectBackoff" env:"DATABASE_CONNECT_BACKOFF"`        // Пауза после первой неудачной попытки; далее удваивается
+	ConnectMaxBackoff time.Duration `yaml:"connectMaxBackoff" env:"DATABASE_CONNECT_MAX_BACKOFF"` // Верхняя граница паузы
+}
+
+// DSN - строка подключения для драйвера postgres. Ограничение времени запроса передаётся
+// параметром сессии statement_timeout и действует на каждом соединении пула.
+func (c DatabaseConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode)
+	if c.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", c.StatementTimeout.Milliseconds())
+	}
+	return dsn
+}
+
+// AuthConfig - время жизни токенов и автоматическая регистрация при входе
+type AuthConfig struct {
+	AccessTTL    time.Duration `yaml:"accessTTL" env:"ACCESS_TOKEN_TTL"`
+	RefreshTTL   time.Duration `yaml:"refreshTTL" env:"REFRESH_TOKEN_TTL"`
+	AutoRegister bool          `yaml:"autoRegister" env:"AUTH_AUTO_REGISTER"`
+}
+
+// Settings - настройки аутентификации для UserService
+func (c AuthConfig) Settings() models.AuthSettings {
+	return models.AuthSettings{AccessTTL: c.AccessTTL, RefreshTTL: c.RefreshTTL, AutoRegister: c.AutoRegister}
+}
+
+// JWTConfig - подпись токенов. Ключи из файлов читаются при запуске сервера.
+type JWTConfig struct {
+	Algorithm              string            `yaml:"algorithm" env:"JWT_ALGORITHM"` // HS256, RS256 или EdDSA
+	KeyID                  string            `yaml:"keyID" env:"JWT_KEY_ID"`        // kid текущего ключа; по умолчанию вычисляется из ключа
+	Secret                 string            `yaml:"secret" env:"JWT_SECRET"`
+	SecretFile             string            `yaml:"secretFile" env:"JWT_SECRET_FILE"`
+	PrivateKeyFile         string            `yaml:"privateKeyFile" env:"JWT_PRIVATE_KEY_FILE"`
+	PreviousSecrets        map[string]string `yaml:"previousSecrets" env:"JWT_PREVIOUS_SECRETS"`                 // kid → прежний HMAC-секрет
+	PreviousPublicKeyFiles map[string]string `yaml:"previousPublicKeyFiles" env:"JWT_PREVIOUS_PUBLIC_KEY_FILES"` // kid → файл прежнего открытого ключа
+}
+
+// LoginGuardConfig - защита от перебора паролей
+type LoginGuardConfig struct {
+	MaxAttempts     int           `yaml:"maxAttempts" env:"LOGIN_MAX_ATTEMPTS"`      // Неудачных попыток на имя пользователя до блокировки
+	IPMaxAttempts   int           `yaml:"ipMaxAttempts" env:"LOGIN_IP_MAX_ATTEMPTS"` // Неудачных попыток с одного IP-адреса до блокировки
+	LockoutDuration time.Duration `yaml:"lockoutDuration" env:"LOGIN_LOCKOUT_DURATION"`
+	Store           string        `yaml:"store" env:"LOGIN_ATTEMPT_STORE"` // postgres (общее для всех экземпляров) или memory
+}
+
+// Settings - пороги защиты от перебора для LoginGuard
+func (c LoginGuardConfig) Settings() models.LoginGuardSettings {
+	return models.LoginGuardSettings{
+		User:            models.LoginLimit{FreeAttempts: 3, MaxAttempts: c.MaxAttempts},
+		IP:              models.LoginLimit{FreeAttempts: 20, MaxAttempts: c.IPMaxAttempts},
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: c.LockoutDuration,
+		Window:          15 * time.Minute,
+	}
+}
+
+// ShopConfig - сроки, связанные с операциями пользователей
+type ShopConfig struct {
+	IdempotencyTTL    time.Duration `yaml:"idempotencyTTL" env:"IDEMPOTENCY_TTL"`        // Сколько хранится ответ на запрос с Idempotency-Key
+	IdempotencyLease  time.Duration `yaml:"idempotencyLease" env:"IDEMPOTENCY_LEASE"`    // Сколько ключ занят запросом, который прервался, не сохранив ответ
+	RefundGracePeriod time.Duration `yaml:"refundGracePeriod" env:"REFUND_GRACE_PERIOD"` // Сколько времени пользователь может сам вернуть покупку
+	CoinRequestTTL    time.Duration `yaml:"coinRequestTTL" env:"COIN_REQUEST_TTL"`       // Сколько запрос монет ждёт ответа плательщика
+}
+
+// EconomyConfig - начальные настройки экономики. Они записываются в базу только при первом запуске;
+// дальше настройки меняются администратором через /api/admin/economy.
+type EconomyConfig struct {
+	WelcomeBonus     int  `yaml:"welcomeBonus" env:"WELCOME_BONUS"`
+	MinTransfer      int  `yaml:"minTransfer" env:"TRANSFER_MIN"`
+	MaxTransfer      *int `yaml:"maxTransfer" env:"TRANSFER_MAX"`
+	DailyTransferCap *int `yaml:"dailyTransferCap" env:"TRANSFER_DAILY_CAP"`
+	MaxBalance       *int `yaml:"maxBalance" env:"MAX_BALANCE"`
+}
+
+// Defaults - начальные настройки экономики для EconomyService.Init
+func (c EconomyConfig) Defaults() models.EconomySettings {
+	settings := models.DefaultEconomySettings()
+	settings.WelcomeBonus = c.WelcomeBonus
+	settings.MinTransfer = c.MinTransfer
+	settings.MaxTransfer = c.MaxTransfer
+	settings.DailyTransferCap = c.DailyTransferCap
+	settings.MaxBalance = c.MaxBalance
+	return settings
+}
+
+// HoldsConfig - удержания монет
+type HoldsConfig struct {
+	DefaultTTL     time.Duration `yaml:"defaultTTL" env:"HOLD_TTL"`
+	MaxTTL         time.Duration `yaml:"maxTTL" env:"HOLD_MAX_TTL"`
+	ExpiryInterval time.Duration `yaml:"expiryInterval" env:"HOLD_EXPIRY_INTERVAL"` // Как часто возвращать монеты истёкших удержаний
+}
+
+// Settings - параметры удержаний для UserService
+func (c HoldsConfig) Settings() models.HoldSettings {
+	return models.HoldSettings{DefaultTTL: c.DefaultTTL, MaxTTL: c.MaxTTL, ExpiryInterval: c.ExpiryInterval}
+}
+
+// SchedulerConfig - выполнение запланированных переводов
+type SchedulerConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"SCHEDULER_ENABLED"` // Выполнять ли наступившие переводы на этом экземпляре
+	Interval         time.Duration `yaml:"interval" env:"SCHEDULER_INTERVAL"`
+	BatchSize        int           `yaml:"batchSize" env:"SCHEDULER_BATCH_SIZE"`
+	Lease            time.Duration `yaml:"lease" env:"SCHEDULER_LEASE"`                    // Через сколько незавершённый запуск выполняется повторно
+	MaxActivePerUser int           `yaml:"maxActivePerUser" env:"SCHEDULED_TRANSFERS_MAX"` // Действующих запланированных переводов на пользователя
+}
+
+// Settings - параметры планировщика для SchedulerService
+func (c SchedulerConfig) Settings() models.SchedulerSettings {
+	return models.SchedulerSettings{Interval: c.Interval, BatchSize: c.BatchSize, Lease: c.Lease, MaxActivePerUser: c.MaxActivePerUser}
+}
+
+// MigrationsConfig - применение миграций схемы
+type MigrationsConfig struct {
+	// При false миграции применяются командой migrate up, а сервер не запускается на устаревшей схеме
+	OnStart bool `yaml:"onStart" env:"MIGRATE_ON_START"`
+}
+
+// Default - значения по умолчанию для всего, что не задано явно
+func Default() Config {
+	economy := models.DefaultEconomySettings()
+	return Config{
+		Server: ServerConfig{
+			Port:            ":8080",
+			RequestTimeout:  10 * time.Second,
+			ReportTimeout:   time.Minute,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Port:              5432,
+			SSLMode:           "disable",
+			MaxOpenConns:      25,
+			MaxIdleConns:      10,
+			ConnMaxLifetime:   30 * time.Minute,
+			ConnMaxIdleTime:   5 * time.Minute,
+			StatementTimeout:  30 * time.Second,
+			ConnectAttempts:   10,
+			ConnectBackoff:    500 * time.Millisecond,
+			ConnectMaxBackoff: 10 * time.Second,
+		},
+		Auth: AuthConfig{AccessTTL: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour, AutoRegister: true},
+		LoginGuard: LoginGuardConfig{
+			MaxAttempts:     10,
+			IPMaxAttempts:   100,
+			LockoutDuration: 15 * time.Minute,
+			Store:           "postgres",
+		},
+		Shop:       ShopConfig{IdempotencyTTL: 24 * time.Hour, IdempotencyLease: 2 * time.Minute, RefundGracePeriod: 15 * time.Minute, CoinRequestTTL: 72 * time.Hour},
+		Economy:    EconomyConfig{WelcomeBonus: economy.WelcomeBonus, MinTransfer: economy.MinTransfer},
+		Holds:      HoldsConfig{DefaultTTL: 7 * 24 * time.Hour, MaxTTL: 30 * 24 * time.Hour, ExpiryInterval: time.Minute},
+		Scheduler:  SchedulerConfig{Enabled: true, Interval: 30 * time.Second, BatchSize: 100, Lease: 5 * time.Minute, MaxActivePerUser: 20},
+		Migrations: MigrationsConfig{OnStart: true},
+	}
+}
+
+// validate - проверяет значения после загрузки; все найденные проблемы добавляются в problems
+func (c *Config) validate(p *problems) {
+	p.require(c.Server.Port != "", "SERVER_PORT", "is required")
+	p.require(c.Server.RequestTimeout > 0, "SERVER_REQUEST_TIMEOUT", "must be positive")
+	p.require(c.Server.ReportTimeout > 0, "SERVER_REPORT_TIMEOUT", "must be positive")
+	p.require(c.Server.ShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT", "must be positive")
+
+	p.require(c.Database.Host != "", "DATABASE_HOST", "is required")
+	p.require(c.Database.User != "", "DATABASE_USER", "is required")
+	p.require(c.Database.Name != "", "DATABASE_NAME", "is required")
+	p.require(c.Database.Port > 0 && c.Database.Port <= 65535, "DATABASE_PORT", "must be a port number")
+	p.require(c.Database.MaxOpenConns > 0, "DATABASE_MAX_OPEN_CONNS", "must be positive")
+	p.require(c.Database.MaxIdleConns >= 0, "DATABASE_MAX_IDLE_CONNS", "must not be negative")
+	p.require(c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "DATABASE_MAX_IDLE_CONNS", "must not exceed DATABASE_MAX_OPEN_CONNS")
+	p.require(c.Database.ConnMaxLifetime >= 0, "DATABASE_CONN_MAX_LIFETIME", "must not be negative")
+	p.require(c.Database.ConnMaxIdleTime >= 0, "DATABASE_CONN_MAX_IDLE_TIME", "must not be negative")
+	p.require(c.Database.StatementTimeout >= 0, "DATABASE_STATEMENT_TIMEOUT", "must not be negative")
+	p.require(c.Database.ConnectAttempts >= 1, "DATABASE_CONNECT_ATTEMPTS", "must be at least 1")
+	p.require(c.Database.ConnectBackoff > 0, "DATABASE_CONNECT_BACKOFF", "must be positive")
+	p.require(c.Database.ConnectMaxBackoff >= c.Database.ConnectBackoff, "DATABASE_CONNECT_MAX_BACKOFF", "must not be less than DATABASE_CONNECT_BACKOFF")
+
+	p.require(c.Auth.AccessTTL > 0, "ACCESS_TOKEN_TTL", "must be positive")
+	p.require(c.Auth.RefreshTTL > 0, "REFRESH_TOKEN_TTL", "must be positive")
+	p.require(c.Auth.RefreshTTL >= c.Auth.AccessTTL, "REFRESH_TOKEN_TTL", "must not be shorter than ACCESS_TOKEN_TTL")
+
+	p.require(c.LoginGuard.MaxAttempts >= 0, "LOGIN_MAX_ATTEMPTS", "must not be negative")
+	p.require(c.LoginGuard.IPMaxAttempts >= 0, "LOGIN_IP_MAX_ATTEMPTS", "must not be negative")
+	p.require(c.LoginGuard.LockoutDuration > 0, "LOGIN_LOCKOUT_DURATION", "must be positive")
+	p.require(c.LoginGuard.Store == "postgres" || c.LoginGuard.Store == "memory", "LOGIN_ATTEMPT_STORE", "must be postgres or memory")
+
+	p.require(c.Shop.IdempotencyTTL > 0, "IDEMPOTENCY_TTL", "must be positive")
+	p.require(c.Shop.IdempotencyLease > 0, "IDEMPOTENCY_LEASE", "must be positive")
+	p.require(c.Shop.IdempotencyLease < c.Shop.IdempotencyTTL, "IDEMPOTENCY_LEASE", "must be shorter than IDEMPOTENCY_TTL")
+	p.require(c.Shop.RefundGracePeriod >= 0, "REFUND_GRACE_PERIOD", "must not be negative")
+	p.require(c.Shop.CoinRequestTTL > 0, "COIN_REQUEST_TTL", "must be positive")
+
+	p.require(c.Economy.WelcomeBonus >= 0, "WELCOME_BONUS", "must not be negative")
+	p.require(c.Economy.MinTransfer >= 1, "TRANSFER_MIN", "must be at least 1")
+	p.require(c.Economy.MaxTransfer == nil || *c.Economy.MaxTransfer >= c.Economy.MinTransfer, "TRANSFER_MAX", "must not be less than TRANSFER_MIN")
+
+	p.require(c.Holds.DefaultTTL > 0, "HOLD_TTL", "must be positive")
+	p.require(c.Holds.MaxTTL > 0, "HOLD_MAX_TTL", "must be positive")
+	p.require(c.Holds.DefaultTTL <= c.Holds.MaxTTL, "HOLD_TTL", "must not exceed HOLD_MAX_TTL")
+	p.require(c.Holds.ExpiryInterval > 0, "HOLD_EXPIRY_INTERVAL", "must be positive")
+
+	p.require(c.Scheduler.Interval > 0, "SCHEDULER_INTERVAL", "must be positive")
+	p.require(c.Scheduler.BatchSize > 0, "SCHEDULER_BATCH_SIZE", "must be positive")
+	p.require(c.Scheduler.Lease > 0, "SCHEDULER_LEASE", "must be positive")
+	p.require(c.Scheduler.MaxActivePerUser >= 0, "SCHEDULED_TRANSFERS_MAX", "must not be negative")
+}
+
+// validate - проверяет алгоритм подписи и наличие ключей для него; сами ключи разбираются при запуске сервера
+func (c JWTConfig) validate(p *problems) {
+	switch c.Algorithm {
+	case "", "HS256":
+		// Секрет не имеет значения по умолчанию: общий для всех установок ключ позволял бы подделывать токены
+		p.require(c.Secret != "" || c.SecretFile != "", "JWT_SECRET", "is required for HS256 unless JWT_SECRET_FILE is set")
+	case "RS256", "EdDSA":
+		p.require(c.PrivateKeyFile != "", "JWT_PRIVATE_KEY_FILE", "is required for "+c.Algorithm)
+	default:
+		p.add("JWT_ALGORITHM", "must be HS256, RS256 or EdDSA")
+	}
+
+	p.require(c.Secret == "" || len(c.Secret) >= minJWTSecretLength, "JWT_SECRET", fmt.Sprintf("must be at least %d bytes", minJWTSecretLength))
+	for _, kid := range sortedKeys(c.PreviousSecrets) {
+		p.require(len(c.PreviousSecrets[kid]) >= minJWTSecretLength, "JWT_PREVIOUS_SECRETS",
+			fmt.Sprintf("secret %q must be at least %d bytes", kid, minJWTSecretLength))
+	}
+
+	p.requireReadable(c.SecretFile, "JWT_SECRET_FILE")
+	p.requireReadable(c.PrivateKeyFile, "JWT_PRIVATE_KEY_FILE")
+	for _, kid := range sortedKeys(c.PreviousPublicKeyFiles) {
+		p.requireReadable(c.PreviousPublicKeyFiles[kid], "JWT_PREVIOUS_PUBLIC_KEY_FILES")
+	}
+}
+
+// requireReadable - добавляет проблему, если файл из настройки задан, но не читается
+func (p *problems) requireReadable(path, env string) {
+	if path == "" {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		p.add(env, err.Error())
+		return
+	}
+	file.Close()
+}
+
+// sortedKeys - ключи в порядке сортировки, чтобы проблемы перечислялись в одном и том же порядке
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// env - переменные окружения процесса в тестах
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+// writeFile - создаёт файл во временном каталоге теста
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testSecret = "config-test-secret-0123456789"
+
+var requiredEnv = map[string]string{"DATABASE_HOST": "db", "DATABASE_USER": "postgres", "DATABASE_NAME": "shop", "JWT_SECRET": testSecret}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(Options{EnvFile: filepath.Join(t.TempDir(), ".env"), LookupEnv: env(requiredEnv)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	want := Default()
+	want.Database.Host, want.Database.User, want.Database.Name = "db", "postgres", "shop"
+	want.JWT.Secret = testSecret
+	assert.Equal(t, want, *cfg)
+	assert.Equal(t, "host=db user=postgres password= dbname=shop port=5432 sslmode=disable statement_timeout=30000", cfg.Database.DSN())
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: ":9000"
+database:
+  host: file-host
+  user: file-user
+  name: file-db
+  port: 6432
+holds:
+  defaultTTL: 48h
+economy:
+  maxBalance: 5000
+jwt:
+  previousSecrets:
+    old: old-secret-0123456789
+`)
+	dotenv := writeFile(t, ".env", "DATABASE_HOST=dotenv-host\nDATABASE_USER=dotenv-user\nSCHEDULER_ENABLED=false\n")
+
+	cfg, err := Load(Options{
+		File:    file,
+		EnvFile: dotenv,
+		LookupEnv: env(map[string]string{"DATABASE_HOST": "env-host", "TRANSFER_MAX": "300",
+			"JWT_SECRET": testSecret, "JWT_PREVIOUS_SECRETS": "a=secret-a-0123456789, b=secret-b-0123456789"}),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Окружение важнее .env, .env важнее файла, файл важнее значений по умолчанию
+	assert.Equal(t, "env-host", cfg.Database.Host)
+	assert.Equal(t, "dotenv-user", cfg.Database.User)
+	assert.Equal(t, "file-db", cfg.Database.Name)
+	assert.Equal(t, 6432, cfg.Database.Port)
+	assert.Equal(t, ":9000", cfg.Server.Port)
+	assert.Equal(t, 48*time.Hour, cfg.Holds.DefaultTTL)
+	assert.Equal(t, 30*24*time.Hour, cfg.Holds.MaxTTL)
+	assert.False(t, cfg.Scheduler.Enabled)
+	if assert.NotNil(t, cfg.Economy.MaxTransfer) && assert.NotNil(t, cfg.Economy.MaxBalance) {
+		assert.Equal(t, 300, *cfg.Economy.MaxTransfer)
+		assert.Equal(t, 5000, *cfg.Economy.MaxBalance)
+	}
+	assert.Equal(t, map[string]string{"a": "secret-a-0123456789", "b": "secret-b-0123456789"}, cfg.JWT.PreviousSecrets)
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	file := writeFile(t, "config.yaml", "database:\n  host: file-host\n  user: u\n  name: n\n")
+
+	cfg, err := Load(Options{LookupEnv: env(map[string]string{"TEST_CONFIG_FILE": file, "TEST_JWT_SECRET": testSecret}), EnvPrefix: "TEST_"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "file-host", cfg.Database.Host)
+	}
+}
+
+func TestLoadEnvPrefix(t *testing.T) {
+	cfg, err := Load(Options{
+		EnvPrefix: "TEST_",
+		LookupEnv: env(map[string]string{
+			"DATABASE_HOST": "prod-host", "TEST_DATABASE_HOST": "test-host",
+			"TEST_DATABASE_USER": "postgres", "TEST_DATABASE_NAME": "shop_test", "TEST_SERVER_PORT": ":8081",
+			"TEST_JWT_SECRET": testSecret,
+		}),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test-host", cfg.Database.Host)
+		assert.Equal(t, ":8081", cfg.Server.Port)
+	}
+}
+
+func TestLoadWithoutJWT(t *testing.T) {
+	// Команда migrate запускается без ключей подписи токенов
+	cfg, err := Load(Options{LookupEnv: env(map[string]string{"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n"}), WithoutJWT: true})
+	if assert.NoError(t, err) {
+		assert.Empty(t, cfg.JWT.Secret)
+	}
+}
+
+func TestLoadProblems(t *testing.T) {
+	file := writeFile(t, "config.yaml", "database:\n  hots: typo\nholds:\n  maxTTL: 1h\n")
+	missingKey := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name  string
+		opts  Options
+		wants []string
+	}{
+		{
+			name: "все проблемы перечислены в одной ошибке",
+			opts: Options{
+				File: file,
+				LookupEnv: env(map[string]string{
+					"DATABASE_HOST": "db", "DATABASE_PORT": "abc", "ACCESS_TOKEN_TTL": "soon",
+					"AUTH_AUTO_REGISTER": "maybe", "LOGIN_ATTEMPT_STORE": "redis", "JWT_PREVIOUS_SECRETS": "broken",
+					"DATABASE_MAX_OPEN_CONNS": "5", "DATABASE_MAX_IDLE_CONNS": "6", "SERVER_REQUEST_TIMEOUT": "0s",
+				}),
+			},
+			wants: []string{
+				file + ": line 2: field hots not found in type config.DatabaseConfig",
+				`DATABASE_PORT (database.port): invalid integer "abc"`,
+				`ACCESS_TOKEN_TTL (auth.accessTTL): invalid duration "soon"`,
+				`AUTH_AUTO_REGISTER (auth.autoRegister): invalid boolean "maybe"`,
+				`JWT_PREVIOUS_SECRETS (jwt.previousSecrets): expected kid=value, got "broken"`,
+				"DATABASE_USER (database.user): is required",
+				"DATABASE_NAME (database.name): is required",
+				"LOGIN_ATTEMPT_STORE (loginGuard.store): must be postgres or memory",
+				"DATABASE_MAX_IDLE_CONNS (database.maxIdleConns): must not exceed DATABASE_MAX_OPEN_CONNS",
+				"HOLD_TTL (holds.defaultTTL): must not exceed HOLD_MAX_TTL",
+			},
+		},
+		{
+			name: "подпись токенов и лимиты экономики проверяются вместе с остальными настройками",
+			opts: Options{
+				LookupEnv: env(map[string]string{
+					"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n",
+					"JWT_SECRET": "short", "JWT_PREVIOUS_SECRETS": "old=tiny", "JWT_PREVIOUS_PUBLIC_KEY_FILES": "old=" + missingKey,
+					"TRANSFER_MIN": "10", "TRANSFER_MAX": "5", "IDEMPOTENCY_LEASE": "48h",
+				}),
+			},
+			wants: []string{
+				"JWT_SECRET (jwt.secret): must be at least 16 bytes",
+				`JWT_PREVIOUS_SECRETS (jwt.previousSecrets): secret "old" must be at least 16 bytes`,
+				"JWT_PREVIOUS_PUBLIC_KEY_FILES (jwt.previousPublicKeyFiles): open " + missingKey,
+				"TRANSFER_MAX (economy.maxTransfer): must not be less than TRANSFER_MIN",
+				"IDEMPOTENCY_LEASE (shop.idempotencyLease): must be shorter than IDEMPOTENCY_TTL",
+			},
+		},
+		{
+			name:  "секрет HS256 не задан",
+			opts:  Options{LookupEnv: env(map[string]string{"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n"})},
+			wants: []string{"JWT_SECRET (jwt.secret): is required for HS256 unless JWT_SECRET_FILE is set"},
+		},
+		{
+			name: "ключ RS256 не задан, файл секрета не читается",
+			opts: Options{LookupEnv: env(map[string]string{
+				"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n", "JWT_ALGORITHM": "RS256", "JWT_SECRET_FILE": missingKey,
+			})},
+			wants: []string{
+				"JWT_PRIVATE_KEY_FILE (jwt.privateKeyFile): is required for RS256",
+				"JWT_SECRET_FILE (jwt.secretFile): open " + missingKey,
+			},
+		},
+		{
+			name:  "неизвестный алгоритм подписи",
+			opts:  Options{LookupEnv: env(map[string]string{"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n", "JWT_ALGORITHM": "none"})},
+			wants: []string{"JWT_ALGORITHM (jwt.algorithm): must be HS256, RS256 or EdDSA"},
+		},
+		{
+			name:  "имена переменных с префиксом",
+			opts:  Options{EnvPrefix: "TEST_", LookupEnv: env(map[string]string{"DATABASE_HOST": "db", "DATABASE_USER": "u", "DATABASE_NAME": "n"})},
+			wants: []string{"TEST_DATABASE_HOST (database.host): is required"},
+		},
+		{
+			name:  "файл настроек не найден",
+			opts:  Options{File: filepath.Join(t.TempDir(), "missing.yaml"), LookupEnv: env(requiredEnv)},
+			wants: []string{"config file: open"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := Load(tt.opts)
+			assert.Nil(t, cfg)
+
+			var validationErr *ValidationError
+			if !assert.True(t, errors.As(err, &validationErr)) {
+				return
+			}
+			for _, want := range tt.wants {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestExampleConfigFile(t *testing.T) {
+	// Пример в корне репозитория должен оставаться рабочим файлом настроек; файл секрета подменяется временным
+	secretFile := writeFile(t, "jwt_secret", testSecret)
+	cfg, err := Load(Options{File: "../../config.example.yaml", LookupEnv: env(map[string]string{"JWT_SECRET_FILE": secretFile})})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "db", cfg.Database.Host)
+		assert.Equal(t, 30*time.Second, cfg.Database.StatementTimeout)
+		assert.Equal(t, time.Minute, cfg.Server.ReportTimeout)
+	}
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options - откуда читать настройки. Источники в порядке убывания приоритета: переменные окружения процесса,
+// файл .env (EnvFile), YAML-файл (File), значения по умолчанию.
+type Options struct {
+	File      string                           // YAML-файл; если не задан, берётся из переменной CONFIG_FILE. Необязателен
+	EnvFile   string                           // Файл .env; отсутствие файла не ошибка
+	EnvPrefix string                           // Префикс всех переменных окружения, например TEST_ для интеграционных тестов
+	LookupEnv func(name string) (string, bool) // Чтение переменных окружения; по умолчанию os.LookupEnv
+	// Не проверять настройки подписи токенов: они не нужны команде migrate и интеграционным тестам
+	WithoutJWT bool
+}
+
+// ValidationError - все проблемы конфигурации, найденные при загрузке
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load - собирает настройки из всех источников и проверяет их. Ошибка содержит сразу все найденные проблемы.
+func Load(opts Options) (*Config, error) {
+	cfg := Default()
+	leaves := fields(reflect.ValueOf(&cfg).Elem(), "")
+	p := &problems{prefix: opts.EnvPrefix, paths: make(map[string]string, len(leaves))}
+	for _, f := range leaves {
+		p.paths[f.env] = f.path
+	}
+
+	lookup := opts.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	dotenv := map[string]string{}
+	if opts.EnvFile != "" {
+		values, err := godotenv.Read(opts.EnvFile)
+		switch {
+		case err == nil:
+			dotenv = values
+		case !errors.Is(err, fs.ErrNotExist):
+			p.list = append(p.list, fmt.Sprintf("%s: %v", opts.EnvFile, err))
+		}
+	}
+	getenv := func(name string) (string, bool) {
+		name = opts.EnvPrefix + name
+		if value, ok := lookup(name); ok && value != "" {
+			return value, true
+		}
+		value, ok := dotenv[name]
+		return value, ok && value != ""
+	}
+
+	file := opts.File
+	if file == "" {
+		file, _ = getenv("CONFIG_FILE")
+	}
+	if file != "" {
+		loadFile(file, &cfg, p)
+	}
+
+	for _, f := range leaves {
+		raw, ok := getenv(f.env)
+		if !ok {
+			continue
+		}
+		if err := setFromString(f.value, raw); err != nil {
+			p.add(f.env, err.Error())
+		}
+	}
+
+	cfg.validate(p)
+	if !opts.WithoutJWT {
+		cfg.JWT.validate(p)
+	}
+	if len(p.list) > 0 {
+		return nil, &ValidationError{Problems: p.list}
+	}
+	return &cfg, nil
+}
+
+// loadFile - накладывает значения из YAML-файла поверх значений по умолчанию. Неизвестные ключи считаются ошибкой.
+func loadFile(path string, cfg *Config, p *problems) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		p.list = append(p.list, fmt.Sprintf("config file: %v", err))
+		return
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr):
+		for _, message := range typeErr.Errors {
+			p.list = append(p.list, fmt.Sprintf("%s: %s", path, message))
+		}
+	default:
+		p.list = append(p.list, fmt.Sprintf("%s: %v", path, err))
+	}
+}
+
+// field - настройка, которую можно задать переменной окружения
+type field struct {
+	env   string        // Имя переменной окружения без префикса
+	path  string        // Ключ в YAML-файле, например database.host
+	value reflect.Value // Поле в Config
+}
+
+// fields - все настройки структуры с тегом env, включая вложенные секции
+func fields(v reflect.Value, path string) []field {
+	var result []field
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		key := strings.Split(structField.Tag.Get("yaml"), ",")[0]
+		if path != "" {
+			key = path + "." + key
+		}
+
+		if env := structField.Tag.Get("env"); env != "" {
+			result = append(result, field{env: env, path: key, value: v.Field(i)})
+		} else if structField.Type.Kind() == reflect.Struct {
+			result = append(result, fields(v.Field(i), key)...)
+		}
+	}
+	return result
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setFromString - записывает в поле значение переменной окружения с разбором по типу поля
+func setFromString(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.Set(reflect.ValueOf(&n))
+	case v.Kind() == reflect.Map:
+		pairs, err := parseKeyPairs(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(pairs))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// parseKeyPairs - разбирает значение вида kid=value,kid=value
+func parseKeyPairs(raw string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kid, value, ok := strings.Cut(item, "=")
+		if !ok || kid == "" || value == "" {
+			return nil, fmt.Errorf("expected kid=value, got %q", item)
+		}
+		pairs[kid] = value
+	}
+	return pairs, nil
+}
+
+// problems - проблемы конфигурации, накопленные при загрузке
+type problems struct {
+	prefix string
+	paths  map[string]string // Ключ YAML по имени переменной окружения
+	list   []string
+}
+
+// add - добавляет проблему настройки; в сообщении указываются переменная окружения и ключ YAML
+func (p *problems) add(env, message string) {
+	p.list = append(p.list, fmt.Sprintf("%s%s (%s): %s", p.prefix, env, p.paths[env], message))
+}
+
+// require - добавляет проблему, если условие не выполнено
+func (p *problems) require(ok bool, env, message string) {
+	if !ok {
+		p.add(env, message)
+	}
+}
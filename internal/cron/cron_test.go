@@ -0,0 +1,95 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2025, time.January, 31, 10, 30, 0, 0, time.UTC) // пятница
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{
+			name: "каждую минуту",
+			expr: "* * * * *",
+			want: time.Date(2025, time.January, 31, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			name: "каждые 15 минут",
+			expr: "*/15 * * * *",
+			want: time.Date(2025, time.January, 31, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "первое число месяца в 9:00",
+			expr: "0 9 1 * *",
+			want: time.Date(2025, time.February, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "сокращение @monthly",
+			expr: "@monthly",
+			want: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "по будням в 18:00",
+			expr: "0 18 * * 1-5",
+			want: time.Date(2025, time.January, 31, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "воскресенье как 7",
+			expr: "0 0 * * 7",
+			want: time.Date(2025, time.February, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "31 число пропускает короткие месяцы",
+			expr: "0 12 31 * *",
+			want: time.Date(2025, time.January, 31, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "день месяца или день недели",
+			expr: "0 0 15 * 1",
+			want: time.Date(2025, time.February, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "29 февраля",
+			expr: "0 0 29 2 *",
+			want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := Parse(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			next, err := schedule.Next(from)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, next)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestNeverRuns(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = schedule.Next(time.Now())
+	assert.ErrorIs(t, err, ErrNeverRuns)
+}
@@ -0,0 +1,57 @@
+// Package database - подключение к Postgres с повторными попытками и настройкой пула соединений
+package database
+
+import (
+	"fmt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"log"
+	"merch-shop/internal/config"
+	"time"
+)
+
+// Open - подключается к Postgres и настраивает пул соединений. Пока база недоступна (например, контейнер
+// с Postgres ещё запускается), подключение повторяется до cfg.ConnectAttempts раз с удваивающейся паузой.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var db *gorm.DB
+	err := retry(cfg.ConnectAttempts, cfg.ConnectBackoff, cfg.ConnectMaxBackoff, time.Sleep, func() error {
+		var err error
+		db, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// retry - вызывает connect, пока он не выполнится успешно или не закончатся попытки.
+// Пауза между попытками начинается с backoff и удваивается, но не превышает maxBackoff.
+func retry(attempts int, backoff, maxBackoff time.Duration, sleep func(time.Duration), connect func() error) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = connect(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("database is not ready (attempt %d of %d): %v; retrying in %s", attempt, attempts, err, backoff)
+		sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return fmt.Errorf("could not connect to database after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	errNotReady := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		attempts   int
+		failures   int // Сколько первых попыток завершаются ошибкой
+		wantCalls  int
+		wantSleeps []time.Duration
+		wantErr    bool
+	}{
+		{
+			name:      "база доступна сразу",
+			attempts:  5,
+			failures:  0,
+			wantCalls: 1,
+		},
+		{
+			name:       "база становится доступна не сразу, пауза удваивается до верхней границы",
+			attempts:   5,
+			failures:   4,
+			wantCalls:  5,
+			wantSleeps: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:       "попытки закончились",
+			attempts:   3,
+			failures:   10,
+			wantCalls:  3,
+			wantSleeps: []time.Duration{time.Second, 2 * time.Second},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			var sleeps []time.Duration
+			err := retry(tt.attempts, time.Second, 3*time.Second, func(d time.Duration) { sleeps = append(sleeps, d) }, func() error {
+				calls++
+				if calls <= tt.failures {
+					return errNotReady
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.Equal(t, tt.wantSleeps, sleeps)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errNotReady)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
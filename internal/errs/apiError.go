@@ -0,0 +1,72 @@
+package errs
+
+import (
+	"context"
+	"errors"
+)
+
+// Error - ошибка API: стабильный код для клиентов, HTTP-статус и описание для человека.
+// Значения создаются один раз как переменные пакета и сравниваются через errors.Is.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+}
+
+func New(code string, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// detailed - ошибка с дополнительными данными для клиента
+type detailed interface {
+	Details() map[string]interface{}
+}
+
+// detailedError - ошибка API, дополненная подробностями конкретного случая
+type detailedError struct {
+	err     error
+	details map[string]interface{}
+}
+
+func (e *detailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+func (e *detailedError) Details() map[string]interface{} {
+	return e.details
+}
+
+// WithDetails - добавляет к ошибке подробности, которые попадут в ответ клиенту
+func WithDetails(err error, details map[string]interface{}) error {
+	return &detailedError{err: err, details: details}
+}
+
+// Resolve - единое соответствие ошибки ответу клиенту: ошибка API из цепочки err и её подробности.
+// Истёкший или отменённый контекст запроса отдаётся как REQUEST_TIMEOUT или REQUEST_CANCELED,
+// остальные ошибки, не входящие в таксономию, считаются внутренними.
+func Resolve(err error) (*Error, map[string]interface{}) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return ErrRequestTimeout, nil
+		case errors.Is(err, context.Canceled):
+			return ErrRequestCanceled, nil
+		}
+		return ErrInternalServer, nil
+	}
+
+	var d detailed
+	if errors.As(err, &d) {
+		return apiErr, d.Details()
+	}
+	return apiErr, nil
+}
@@ -1,21 +1,93 @@
 package errs
 
-import "errors"
+import "net/http"
 
-var ErrInvalidPassword = errors.New("invalid password")
+// Ошибки запроса и авторизации
+var (
+	ErrInvalidRequestBody = New("INVALID_REQUEST_BODY", http.StatusBadRequest, "invalid request body")
+	ErrUnauthorized       = New("UNAUTHORIZED", http.StatusUnauthorized, "unauthorized")
+	ErrForbidden          = New("FORBIDDEN", http.StatusForbidden, "forbidden")
+	ErrInternalServer     = New("INTERNAL_ERROR", http.StatusInternalServerError, "internal server error")
+	ErrRequestTimeout     = New("REQUEST_TIMEOUT", http.StatusServiceUnavailable, "request took too long and was cancelled")
+	ErrRequestCanceled    = New("REQUEST_CANCELED", http.StatusServiceUnavailable, "request was cancelled")
+)
 
-var ErrUserNotFound = errors.New("user not found")
+// Ошибки входа, регистрации и токенов
+var (
+	ErrInvalidPassword      = New("INVALID_PASSWORD", http.StatusBadRequest, "invalid password")
+	ErrUnknownUser          = New("UNKNOWN_USER", http.StatusBadRequest, "unknown user")
+	ErrInvalidCredentials   = New("INVALID_CREDENTIALS", http.StatusBadRequest, "invalid username or password")
+	ErrCreateUser           = New("CREATE_USER_FAILED", http.StatusInternalServerError, "could not create user")
+	ErrUserAlreadyExists    = New("USER_ALREADY_EXISTS", http.StatusConflict, "user already exists")
+	ErrInvalidUsername      = New("INVALID_USERNAME", http.StatusBadRequest, "username must be 3-32 characters: latin letters, digits, '_', '-' or '.'")
+	ErrWeakPassword         = New("WEAK_PASSWORD", http.StatusBadRequest, "password must be 8-72 bytes long and contain both letters and digits")
+	ErrTooManyLoginAttempts = New("TOO_MANY_LOGIN_ATTEMPTS", http.StatusTooManyRequests, "too many failed login attempts, try again later")
+	ErrInvalidToken         = New("INVALID_TOKEN", http.StatusUnauthorized, "invalid token")
+	ErrInvalidRefreshToken  = New("INVALID_REFRESH_TOKEN", http.StatusUnauthorized, "invalid refresh token")
+	ErrRefreshTokenReused   = New("REFRESH_TOKEN_REUSED", http.StatusUnauthorized, "refresh token reuse detected")
+	ErrInvalidRole          = New("INVALID_ROLE", http.StatusBadRequest, "invalid role")
+)
 
-var ErrMerchNotFound = errors.New("merch not found")
+// Ошибки операций с монетами и мерчем
+var (
+	ErrUserNotFound        = New("USER_NOT_FOUND", http.StatusBadRequest, "user not found")
+	ErrMerchNotFound       = New("MERCH_NOT_FOUND", http.StatusBadRequest, "merch not found")
+	ErrNotEnoughCoins      = New("NOT_ENOUGH_COINS", http.StatusBadRequest, "not enough coins")
+	ErrNegativeCoins       = New("INVALID_AMOUNT", http.StatusBadRequest, "negative number of coins")
+	ErrSendCoinsToYourself = New("SELF_TRANSFER", http.StatusBadRequest, "you can't send coins to yourself")
+	ErrTransferTooSmall    = New("TRANSFER_BELOW_MINIMUM", http.StatusBadRequest, "transfer amount is below the minimum")
+	ErrTransferTooLarge    = New("TRANSFER_ABOVE_MAXIMUM", http.StatusBadRequest, "transfer amount is above the maximum")
+	ErrDailyTransferLimit  = New("DAILY_TRANSFER_LIMIT_EXCEEDED", http.StatusConflict, "daily transfer limit exceeded")
+	ErrBalanceLimit        = New("BALANCE_LIMIT_EXCEEDED", http.StatusConflict, "receiver balance would exceed the maximum")
+	ErrMessageTooLong      = New("TRANSFER_MESSAGE_TOO_LONG", http.StatusBadRequest, "transfer message is too long")
+	ErrInvalidCategory     = New("INVALID_TRANSFER_CATEGORY", http.StatusBadRequest, "unknown transfer category")
+	ErrOutOfStock          = New("OUT_OF_STOCK", http.StatusConflict, "merch is out of stock")
+	ErrPurchaseLimit       = New("PURCHASE_LIMIT_EXCEEDED", http.StatusConflict, "per-user purchase limit exceeded")
+	ErrInvalidOrder        = New("INVALID_ORDER", http.StatusBadRequest, "order must contain items with positive quantities")
+	ErrPurchaseNotFound    = New("PURCHASE_NOT_FOUND", http.StatusNotFound, "purchase not found")
+	ErrAlreadyRefunded     = New("PURCHASE_ALREADY_REFUNDED", http.StatusConflict, "purchase is already refunded")
+	ErrRefundPeriodExpired = New("REFUND_PERIOD_EXPIRED", http.StatusForbidden, "refund period for this purchase has expired")
+	ErrInvalidGrant        = New("INVALID_GRANT", http.StatusBadRequest, "grant must list distinct users with non-zero amounts")
+	ErrGrantReasonRequired = New("GRANT_REASON_REQUIRED", http.StatusBadRequest, "reason is required")
+)
 
-var ErrNotEnoughCoins = errors.New("not enough coins")
+// Ошибки каталога, истории, пагинации и настроек экономики
+var (
+	ErrMerchAlreadyExists   = New("MERCH_ALREADY_EXISTS", http.StatusConflict, "merch already exists")
+	ErrInvalidMerchName     = New("INVALID_MERCH_NAME", http.StatusBadRequest, "invalid merch name")
+	ErrInvalidPrice         = New("INVALID_PRICE", http.StatusBadRequest, "price must be positive")
+	ErrInvalidStock         = New("INVALID_STOCK", http.StatusBadRequest, "invalid stock change")
+	ErrInvalidPagination    = New("INVALID_PAGINATION", http.StatusBadRequest, "invalid pagination parameters")
+	ErrInvalidHistoryFilter = New("INVALID_HISTORY_FILTER", http.StatusBadRequest, "invalid history filter")
+	ErrInvalidEconomy       = New("INVALID_ECONOMY_SETTINGS", http.StatusBadRequest, "invalid economy settings")
+)
 
-var ErrNegativeCoins = errors.New("negative number of coins")
+// Ошибки идемпотентных запросов
+var (
+	ErrInvalidIdempotencyKey    = New("INVALID_IDEMPOTENCY_KEY", http.StatusBadRequest, "invalid Idempotency-Key header")
+	ErrIdempotencyKeyMismatch   = New("IDEMPOTENCY_KEY_MISMATCH", http.StatusUnprocessableEntity, "idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = New("IDEMPOTENCY_KEY_IN_PROGRESS", http.StatusConflict, "request with this idempotency key is still in progress")
+)
 
-var ErrSendCoinsToYourself = errors.New("you can't send coins to yourself")
+// Ошибки запланированных переводов
+var (
+	ErrInvalidSchedule           = New("INVALID_SCHEDULE", http.StatusBadRequest, "exactly one of runAt (in the future) or a valid cron expression is required")
+	ErrScheduleNotFound          = New("SCHEDULED_TRANSFER_NOT_FOUND", http.StatusNotFound, "scheduled transfer not found")
+	ErrScheduleNotActive         = New("SCHEDULED_TRANSFER_NOT_ACTIVE", http.StatusConflict, "scheduled transfer is not active")
+	ErrTooManyScheduledTransfers = New("TOO_MANY_SCHEDULED_TRANSFERS", http.StatusConflict, "too many active scheduled transfers")
+)
 
-var ErrInternalServer = errors.New("internal server error")
+// Ошибки запросов монет
+var (
+	ErrCoinRequestNotFound      = New("COIN_REQUEST_NOT_FOUND", http.StatusNotFound, "coin request not found")
+	ErrCoinRequestNotPending    = New("COIN_REQUEST_NOT_PENDING", http.StatusConflict, "coin request was already answered")
+	ErrCoinRequestExpired       = New("COIN_REQUEST_EXPIRED", http.StatusConflict, "coin request has expired")
+	ErrInvalidCoinRequestFilter = New("INVALID_COIN_REQUEST_FILTER", http.StatusBadRequest, "invalid coin request filter")
+)
 
-var ErrCreateUser = errors.New("could not create user")
-
-var ErrInvalidToken = errors.New("invalid token")
+// Ошибки удержаний
+var (
+	ErrHoldNotFound      = New("HOLD_NOT_FOUND", http.StatusNotFound, "hold not found")
+	ErrHoldNotActive     = New("HOLD_NOT_ACTIVE", http.StatusConflict, "hold was already released, returned or expired")
+	ErrInvalidHoldExpiry = New("INVALID_HOLD_EXPIRY", http.StatusBadRequest, "hold expiry must be in the future and within the maximum hold period")
+)
@@ -0,0 +1,29 @@
+package errs
+
+import (
+	"math"
+	"time"
+)
+
+// LoginBlockedError - вход временно запрещён; RetryAfter - через сколько можно повторить попытку
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return ErrTooManyLoginAttempts.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// Details - через сколько секунд можно повторить попытку
+func (e *LoginBlockedError) Details() map[string]interface{} {
+	return map[string]interface{}{"retryAfter": e.RetryAfterSeconds()}
+}
+
+// RetryAfterSeconds - время до следующей попытки, округлённое вверх до секунд
+func (e *LoginBlockedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
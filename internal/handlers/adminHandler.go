@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"io"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
+	"merch-shop/internal/models"
+	"merch-shop/internal/services"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type AdminHandler struct {
+	ledgerService  *services.LedgerService
+	loginGuard     *services.LoginGuard
+	userService    *services.UserService
+	economyService *services.EconomyService
+}
+
+func NewAdminHandler(ledgerService *services.LedgerService, loginGuard *services.LoginGuard, userService *services.UserService, economyService *services.EconomyService) *AdminHandler {
+	return &AdminHandler{ledgerService: ledgerService, loginGuard: loginGuard, userService: userService, economyService: economyService}
+}
+
+// GetLedgerReconciliation - обработчик отчёта о расхождениях балансов с журналом
+func (h *AdminHandler) GetLedgerReconciliation(w http.ResponseWriter, r *http.Request) {
+	report, err := h.ledgerService.Reconcile(r.Context())
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// UnlockUser - обработчик снятия блокировки входа с аккаунта
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if err := h.loginGuard.Unlock(r.Context(), username); err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdjustBalance - обработчик начисления или списания монет пользователю
+func (h *AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	var req models.AdjustBalanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	adminUsername, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	result, err := h.userService.AdjustBalance(r.Context(), adminUsername, mux.Vars(r)["username"], req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GrantCoins - обработчик массового зачисления монет. Принимает JSON (GrantRequest) или CSV со строками
+// "username,amount" и обоснованием в параметре reason. Параметр dryRun=true только проверяет пакет.
+func (h *AdminHandler) GrantCoins(w http.ResponseWriter, r *http.Request) {
+	adminUsername, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			httperr.Write(w, r, errs.ErrInvalidGrant)
+			return
+		}
+	}
+
+	var req models.GrantRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		lines, err := parseGrantCSV(r.Body)
+		if err != nil {
+			httperr.Write(w, r, err)
+			return
+		}
+		req = models.GrantRequest{Reason: r.URL.Query().Get("reason"), Grants: lines}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	resp, err := h.userService.GrantCoins(r.Context(), adminUsername, req, dryRun)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if resp.DryRun {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, resp)
+}
+
+// GetEconomy - обработчик получения настроек экономики
+func (h *AdminHandler) GetEconomy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.economyService.Settings(r.Context()))
+}
+
+// UpdateEconomy - обработчик замены настроек экономики
+func (h *AdminHandler) UpdateEconomy(w http.ResponseWriter, r *http.Request) {
+	var settings models.EconomySettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	adminUsername, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	updated, err := h.economyService.Update(r.Context(), adminUsername, settings)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// parseGrantCSV - разбирает строки "username,amount"; первая строка может быть заголовком
+func parseGrantCSV(body io.Reader) ([]models.GrantLine, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var lines []models.GrantLine
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, errs.WithDetails(errs.ErrInvalidGrant, map[string]interface{}{"line": row})
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			if row == 1 {
+				continue
+			}
+			return nil, errs.WithDetails(errs.ErrInvalidGrant, map[string]interface{}{"line": row})
+		}
+		lines = append(lines, models.GrantLine{Username: strings.TrimSpace(record[0]), Amount: amount})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"merch-shop/internal/models"
+	"merch-shop/internal/services"
+	"net/http"
+)
+
+// HealthHandler - проверки живости и готовности для оркестратора
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Liveness - обработчик /healthz: процесс запущен и обслуживает HTTP; зависимости не проверяются,
+// чтобы недоступность базы не приводила к перезапуску экземпляра
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.HealthResponse{Status: services.HealthOK})
+}
+
+// Readiness - обработчик /readyz: база отвечает и все миграции применены; иначе 503,
+// и балансировщик не направляет запросы на этот экземпляр
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	resp, ready := h.healthService.Readiness(r.Context())
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
+	"merch-shop/internal/models"
+	"merch-shop/internal/services"
+	"net/http"
+	"strconv"
+)
+
+type MerchHandler struct {
+	merchService *services.MerchService
+}
+
+func NewMerchHandler(merchService *services.MerchService) *MerchHandler {
+	return &MerchHandler{merchService: merchService}
+}
+
+// ListMerch - обработчик получения страницы каталога
+func (h *MerchHandler) ListMerch(w http.ResponseWriter, r *http.Request) {
+	query := models.MerchListQuery{Sort: r.URL.Query().Get("sort")}
+
+	var err error
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil {
+			httperr.Write(w, r, errs.ErrInvalidPagination)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil {
+			httperr.Write(w, r, errs.ErrInvalidPagination)
+			return
+		}
+	}
+
+	resp, err := h.merchService.ListMerch(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetMerch - обработчик получения товара по названию
+func (h *MerchHandler) GetMerch(w http.ResponseWriter, r *http.Request) {
+	merch, err := h.merchService.GetMerchByName(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merch.ToItem())
+}
+
+// CreateMerch - обработчик добавления товара в каталог
+func (h *MerchHandler) CreateMerch(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateMerchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	merch, err := h.merchService.CreateMerch(r.Context(), req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, merch.ToItem())
+}
+
+// UpdateMerch - обработчик изменения товара
+func (h *MerchHandler) UpdateMerch(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateMerchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	merch, err := h.merchService.UpdateMerch(r.Context(), mux.Vars(r)["name"], req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merch.ToItem())
+}
+
+// ChangeStock - обработчик пополнения и изменения остатка товара
+func (h *MerchHandler) ChangeStock(w http.ResponseWriter, r *http.Request) {
+	var req models.StockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	merch, err := h.merchService.ChangeStock(r.Context(), mux.Vars(r)["name"], req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merch.ToItem())
+}
+
+// RetireMerch - обработчик снятия товара с продажи
+func (h *MerchHandler) RetireMerch(w http.ResponseWriter, r *http.Request) {
+	err := h.merchService.RetireMerch(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
+	"merch-shop/internal/models"
+	"merch-shop/internal/services"
+	"net/http"
+	"strconv"
+)
+
+type SchedulerHandler struct {
+	schedulerService *services.SchedulerService
+}
+
+func NewSchedulerHandler(schedulerService *services.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{schedulerService: schedulerService}
+}
+
+// CreateScheduledTransfer - обработчик создания разового или повторяющегося перевода
+func (h *SchedulerHandler) CreateScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	var req models.ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	transfer, err := h.schedulerService.Create(r.Context(), username, req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, transfer)
+}
+
+// ListScheduledTransfers - обработчик получения запланированных переводов пользователя
+func (h *SchedulerHandler) ListScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	transfers, err := h.schedulerService.List(r.Context(), username)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+// GetScheduledRuns - обработчик получения запусков запланированного перевода
+func (h *SchedulerHandler) GetScheduledRuns(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrScheduleNotFound)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	runs, err := h.schedulerService.Runs(r.Context(), username, uint(id))
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// CancelScheduledTransfer - обработчик отмены запланированного перевода
+func (h *SchedulerHandler) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrScheduleNotFound)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	if err = h.schedulerService.Cancel(r.Context(), username, uint(id)); err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"github.com/gorilla/mux"
 	"log"
 	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
 	"merch-shop/internal/models"
 	"merch-shop/internal/services"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type ShopHandler struct {
@@ -28,87 +31,104 @@ func (h *ShopHandler) BuyItem(w http.ResponseWriter, r *http.Request) {
 	// Достаём username из контекста
 	username, ok := r.Context().Value("username").(string)
 	if !ok {
-		WriteErrorResponse(w, "unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, errs.ErrUnauthorized)
 		return
 	}
 
-	merch, err := h.merchService.GetMerchByName(merchName)
-	switch {
-	case errors.Is(err, errs.ErrMerchNotFound):
-		WriteErrorResponse(w, err.Error(), http.StatusBadRequest)
+	merch, err := h.merchService.GetMerchByName(r.Context(), merchName)
+	if err != nil {
+		httperr.Write(w, r, err)
 		return
-	default:
-		if err != nil {
-			WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
-			log.Println("failed to buy merch: ", err)
-			return
-		}
 	}
 
-	err = h.userService.BuyMerch(username, merch)
-	switch {
-	case errors.Is(err, errs.ErrUserNotFound):
-		WriteErrorResponse(w, err.Error(), http.StatusBadRequest)
-	case errors.Is(err, errs.ErrNotEnoughCoins):
-		WriteErrorResponse(w, err.Error(), http.StatusBadRequest)
-	default:
-		if err != nil {
-			WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
-			log.Println("failed to buy merch: ", err)
-			return
-		}
+	if err = h.userService.BuyMerch(r.Context(), username, merch); err != nil {
+		httperr.Write(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if _, err = w.Write([]byte("merch successfully purchased")); err != nil {
-		WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
 		log.Printf("Error writing response: %v", err)
 	}
 }
 
+// CreateOrder - обработчик оформления заказа из нескольких товаров
+func (h *ShopHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	order, err := h.userService.CreateOrder(r.Context(), username, req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+}
+
+// RefundPurchase - обработчик возврата покупки
+func (h *ShopHandler) RefundPurchase(w http.ResponseWriter, r *http.Request) {
+	purchaseID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrPurchaseNotFound)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value("role").(string)
+
+	refund, err := h.userService.RefundPurchase(r.Context(), username, role, uint(purchaseID))
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, refund)
+}
+
 // SendCoin - обработчик отправки монет другому пользователю
 func (h *ShopHandler) SendCoin(w http.ResponseWriter, r *http.Request) {
 	// Декодируем JSON-запрос
 	var sendCoinRequest models.SendCoinRequest
 	if err := json.NewDecoder(r.Body).Decode(&sendCoinRequest); err != nil {
-		WriteErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
 		return
 	}
 
 	// Получаем username из контекста
 	username, ok := r.Context().Value("username").(string)
 	if !ok {
-		WriteErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, errs.ErrUnauthorized)
 		return
 	}
 
 	// Нельзя отправлять монеты самому себе
 	if username == sendCoinRequest.ToUser {
-		WriteErrorResponse(w, "You can't send coins to yourself.", http.StatusBadRequest)
+		httperr.Write(w, r, errs.ErrSendCoinsToYourself)
 		return
 	}
 
 	// Вызываем сервис для отправки монет
-	err := h.userService.SendCoin(username, sendCoinRequest)
-
-	switch {
-	case errors.Is(err, errs.ErrUserNotFound),
-		errors.Is(err, errs.ErrNegativeCoins),
-		errors.Is(err, errs.ErrNotEnoughCoins),
-		errors.Is(err, errs.ErrSendCoinsToYourself):
-		WriteErrorResponse(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if err != nil {
-		WriteErrorResponse(w, "Internal server error", http.StatusInternalServerError)
-		log.Println("failed to send coins: ", err)
+	if err := h.userService.SendCoin(r.Context(), username, sendCoinRequest); err != nil {
+		httperr.Write(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	if _, err = w.Write([]byte("merch successfully purchased")); err != nil {
-		WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
+	if _, err := w.Write([]byte("merch successfully purchased")); err != nil {
 		log.Printf("Error writing response: %v", err)
 	}
 }
@@ -118,24 +138,239 @@ func (h *ShopHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	// Получаем username из контекста
 	username, ok := r.Context().Value("username").(string)
 	if !ok {
-		WriteErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, errs.ErrUnauthorized)
 		return
 	}
 
 	// Получаем информацию о пользователе
-	info, err := h.userService.GetUserInfo(username)
+	info, err := h.userService.GetUserInfo(r.Context(), username)
 	if err != nil {
-		WriteErrorResponse(w, "Failed to fetch user info", http.StatusInternalServerError)
-		log.Println("failed to get user info:", err)
+		httperr.Write(w, r, err)
 		return
 	}
 
-	// Отправляем JSON-ответ
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err = json.NewEncoder(w).Encode(info); err != nil {
-		WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
-		log.Printf("Error encoding response to JSON: %v", err)
+	writeJSON(w, http.StatusOK, info)
+}
+
+// GetHistory - обработчик постраничной истории переводов и покупок
+func (h *ShopHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := models.HistoryQuery{
+		Cursor:       params.Get("cursor"),
+		Direction:    params.Get("direction"),
+		Counterparty: params.Get("counterparty"),
+		Category:     params.Get("category"),
+	}
+
+	var err error
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil {
+			httperr.Write(w, r, errs.ErrInvalidPagination)
+			return
+		}
+	}
+	if query.MinAmount, err = parseOptionalInt(params.Get("minAmount")); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidHistoryFilter)
+		return
+	}
+	if query.MaxAmount, err = parseOptionalInt(params.Get("maxAmount")); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidHistoryFilter)
+		return
+	}
+	if query.From, err = parseOptionalTime(params.Get("from")); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidHistoryFilter)
+		return
+	}
+	if query.To, err = parseOptionalTime(params.Get("to")); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidHistoryFilter)
+		return
+	}
+
+	resp, err := h.userService.GetHistory(r.Context(), username, query)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseOptionalInt - разбирает необязательный целочисленный параметр запроса
+func parseOptionalInt(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// parseOptionalTime - разбирает необязательный параметр запроса в формате RFC 3339
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateCoinRequest - обработчик запроса монет у другого пользователя
+func (h *ShopHandler) CreateCoinRequest(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCoinRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	request, err := h.userService.CreateCoinRequest(r.Context(), username, req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, request)
+}
+
+// ListCoinRequests - обработчик получения входящих или исходящих запросов монет
+func (h *ShopHandler) ListCoinRequests(w http.ResponseWriter, r *http.Request) {
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := models.CoinRequestQuery{Direction: params.Get("direction"), Status: params.Get("status")}
+
+	requests, err := h.userService.ListCoinRequests(r.Context(), username, query)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// AcceptCoinRequest - обработчик оплаты запроса монет
+func (h *ShopHandler) AcceptCoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerCoinRequest(w, r, h.userService.AcceptCoinRequest)
+}
+
+// DeclineCoinRequest - обработчик отказа в запросе монет
+func (h *ShopHandler) DeclineCoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerCoinRequest(w, r, h.userService.DeclineCoinRequest)
+}
+
+func (h *ShopHandler) answerCoinRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, username string, id uint) (*models.CoinRequestResponse, error)) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrCoinRequestNotFound)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	request, err := answer(r.Context(), username, uint(id))
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, request)
+}
+
+// CreateHold - обработчик удержания монет
+func (h *ShopHandler) CreateHold(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	hold, err := h.userService.CreateHold(r.Context(), username, req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, hold)
+}
+
+// ReleaseHold - обработчик передачи удержанных монет получателю
+func (h *ShopHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrHoldNotFound)
+		return
+	}
+
+	var req models.ReleaseHoldRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	hold, err := h.userService.ReleaseHold(r.Context(), username, uint(id), req)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// ReturnHold - обработчик возврата удержанных монет владельцу
+func (h *ShopHandler) ReturnHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httperr.Write(w, r, errs.ErrHoldNotFound)
+		return
+	}
+
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	hold, err := h.userService.ReturnHold(r.Context(), username, uint(id))
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
 	}
 
+	writeJSON(w, http.StatusOK, hold)
 }
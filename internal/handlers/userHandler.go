@@ -5,51 +5,137 @@ import (
 	"errors"
 	"log"
 	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
 	"merch-shop/internal/models"
 	"merch-shop/internal/services"
+	"net"
 	"net/http"
 )
 
 type UserHandler struct {
 	userService *services.UserService
+	loginGuard  *services.LoginGuard
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, loginGuard *services.LoginGuard) *UserHandler {
+	return &UserHandler{userService: userService, loginGuard: loginGuard}
 }
 
 // Authenticate - обработчик аутентификации
 func (h *UserHandler) Authenticate(w http.ResponseWriter, r *http.Request) {
 	var authReq models.AuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&authReq); err != nil {
-		WriteErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
 		return
 	}
 
-	resp, err := h.userService.Authenticate(&authReq)
-	if errors.Is(err, errs.ErrInvalidPassword) {
-		WriteErrorResponse(w, err.Error(), http.StatusBadRequest)
+	// Отклоняем попытку до проверки пароля, если имя пользователя или адрес клиента заблокированы
+	ip := clientIP(r)
+	if err := h.loginGuard.Check(r.Context(), authReq.Username, ip); err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	resp, err := h.userService.Authenticate(r.Context(), &authReq)
+	if errors.Is(err, errs.ErrInvalidPassword) || errors.Is(err, errs.ErrUnknownUser) {
+		// Клиенту не сообщаем, что именно не подошло, чтобы нельзя было перебирать имена пользователей
+		log.Printf("failed login for %q from %s: %v", authReq.Username, ip, err)
+		if err = h.loginGuard.RecordFailure(r.Context(), authReq.Username, ip); err != nil {
+			log.Println("failed to record login failure: ", err)
+		}
+		httperr.Write(w, r, errs.ErrInvalidCredentials)
 		return
 	}
 	if err != nil {
-		WriteErrorResponse(w, "Internal server error", http.StatusInternalServerError)
-		log.Println("Failed authenticate: ", err)
+		httperr.Write(w, r, err)
 		return
 	}
+	if err = h.loginGuard.RecordSuccess(r.Context(), authReq.Username); err != nil {
+		log.Println("failed to reset login attempts: ", err)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(resp); err != nil {
-		WriteErrorResponse(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
-		log.Printf("Error encoding response to JSON: %v", err)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Register - обработчик регистрации нового пользователя
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var registerReq models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&registerReq); err != nil {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	resp, err := h.userService.Register(r.Context(), &registerReq)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// Refresh - обработчик обновления пары токенов
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var refreshReq models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refreshReq); err != nil || refreshReq.RefreshToken == "" {
+		httperr.Write(w, r, errs.ErrInvalidRequestBody)
+		return
+	}
+
+	resp, err := h.userService.Refresh(r.Context(), &refreshReq)
+	if err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Logout - обработчик выхода: отзывает refresh-токен и выпущенные access-токены
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	username, ok := r.Context().Value("username").(string)
+	if !ok {
+		httperr.Write(w, r, errs.ErrUnauthorized)
+		return
+	}
+
+	// Тело запроса необязательно
+	var logoutReq models.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&logoutReq); err != nil {
+			httperr.Write(w, r, errs.ErrInvalidRequestBody)
+			return
+		}
+	}
+
+	if err := h.userService.Logout(r.Context(), username, &logoutReq); err != nil {
+		httperr.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetJWKS - обработчик публикации открытых ключей проверки токенов
+func (h *UserHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.userService.JWKS())
+}
+
+// clientIP - адрес клиента из соединения. Заголовки прокси не учитываются: их может подделать сам клиент.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
+	return host
 }
 
-// WriteErrorResponse - вспомогательная функция для отправки ошибки
-func WriteErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+// writeJSON - вспомогательная функция для отправки JSON-ответа
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(models.ErrorResponse{Errors: message}); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("Error encoding response to JSON: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
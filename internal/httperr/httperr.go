@@ -0,0 +1,47 @@
+// Package httperr - ответы с ошибкой в формате problem+json; общий для обработчиков и middleware
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// Write - отправляет ошибку в формате problem+json. Код и статус определяются errs.Resolve;
+// ошибки вне таксономии записываются в журнал и отдаются клиенту как внутренние.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, details := errs.Resolve(err)
+	// Если время запроса истекло или клиент отключился, сбой обращения к базе - следствие этого,
+	// даже когда сервис уже заменил исходную ошибку на внутреннюю
+	if ctxErr := r.Context().Err(); ctxErr != nil && apiErr.Status >= http.StatusInternalServerError {
+		apiErr, details = errs.Resolve(ctxErr)
+	}
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	var blocked *errs.LoginBlockedError
+	if errors.As(err, &blocked) {
+		w.Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	err = json.NewEncoder(w).Encode(models.ErrorResponse{
+		Type:     "about:blank",
+		Title:    http.StatusText(apiErr.Status),
+		Status:   apiErr.Status,
+		Detail:   apiErr.Message,
+		Instance: r.URL.Path,
+		Code:     apiErr.Code,
+		Details:  details,
+		Errors:   apiErr.Message,
+	})
+	if err != nil {
+		log.Printf("Error encoding response to JSON: %v", err)
+	}
+}
@@ -4,34 +4,40 @@ import (
 	"context"
 	"log"
 	"merch-shop/internal/errs"
-	"merch-shop/internal/handlers"
+	"merch-shop/internal/httperr"
 	"merch-shop/internal/services"
 	"net/http"
 	"strings"
 )
 
-// AuthMiddleware проверяет токен и передаёт username в контекст запроса.
+// AuthMiddleware проверяет токен и передаёт username и роль в контекст запроса.
 func AuthMiddleware(userService *services.UserService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				handlers.WriteErrorResponse(w, "missing Authorization header", http.StatusUnauthorized)
+				httperr.Write(w, r, errs.ErrUnauthorized)
 				return
 			}
 
 			// Ожидаем формат "Bearer <token>"
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			username, err := userService.ExtractUsernameFromToken(tokenString)
+			claims, err := userService.ExtractClaimsFromToken(r.Context(), tokenString)
 			if err != nil {
-				handlers.WriteErrorResponse(w, errs.ErrInvalidToken.Error(), http.StatusUnauthorized)
+				// Проверка не завершилась из-за истёкшего срока запроса: сам токен при этом может быть действительным
+				if ctxErr := r.Context().Err(); ctxErr != nil {
+					httperr.Write(w, r, ctxErr)
+					return
+				}
+				httperr.Write(w, r, errs.ErrInvalidToken)
 				log.Println("failed extract username from token ", err)
 				return
 			}
 
-			// Добавляем username в контекст запроса
+			// Добавляем username и роль в контекст запроса
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, "username", username)
+			ctx = context.WithValue(ctx, "username", claims.Username)
+			ctx = context.WithValue(ctx, "role", claims.Role)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
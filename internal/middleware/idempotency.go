@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
+	"merch-shop/internal/services"
+	"net/http"
+	"time"
+)
+
+// finishTimeout - сколько ждать сохранения ответа или освобождения ключа после обработки запроса
+const finishTimeout = 5 * time.Second
+
+// IdempotencyMiddleware возвращает сохранённый ответ на повторный запрос с тем же заголовком Idempotency-Key.
+// Должен подключаться после AuthMiddleware: ключи хранятся отдельно для каждого пользователя.
+func IdempotencyMiddleware(idempotencyService *services.IdempotencyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			username, ok := r.Context().Value("username").(string)
+			if !ok {
+				httperr.Write(w, r, errs.ErrUnauthorized)
+				return
+			}
+
+			// Читаем тело, чтобы снять отпечаток, и возвращаем его обработчику
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				httperr.Write(w, r, errs.ErrInvalidRequestBody)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, err := idempotencyService.Begin(r.Context(), username, key, services.RequestFingerprint(r.Method, r.URL.Path, body))
+			if err != nil {
+				httperr.Write(w, r, err)
+				return
+			}
+
+			// Повтор: отдаём сохранённый ответ, не выполняя запрос
+			if record.Completed() {
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				if _, err = w.Write(record.Response); err != nil {
+					log.Printf("Error writing response: %v", err)
+				}
+				return
+			}
+
+			res := newBufferedResponse()
+			defer func() {
+				// При панике освобождаем ключ, чтобы клиент мог повторить запрос
+				if p := recover(); p != nil {
+					ctx, cancel := finishContext(r)
+					defer cancel()
+					if err := idempotencyService.Release(ctx, record); err != nil {
+						log.Println("failed to release idempotency key: ", err)
+					}
+					panic(p)
+				}
+			}()
+
+			// Обработчик выполняется в транзакции, в которой сохраняется и его ответ;
+			// клиент получает ответ только после фиксации транзакции
+			err = idempotencyService.Execute(r.Context(), record, func(ctx context.Context) (int, string, []byte) {
+				next.ServeHTTP(res, r.WithContext(ctx))
+				return res.statusCode(), res.header.Get("Content-Type"), res.body.Bytes()
+			})
+			if err != nil || res.statusCode() >= http.StatusInternalServerError {
+				// Изменения запроса откатились: освобождаем ключ, чтобы клиент мог повторить запрос
+				ctx, cancel := finishContext(r)
+				defer cancel()
+				if releaseErr := idempotencyService.Release(ctx, record); releaseErr != nil {
+					log.Println("failed to release idempotency key: ", releaseErr)
+				}
+			}
+			if err != nil {
+				httperr.Write(w, r, err)
+				return
+			}
+			res.flush(w)
+		})
+	}
+}
+
+// finishContext - контекст для сохранения ответа или освобождения ключа: он не отменяется вместе с запросом,
+// чтобы ключ не остался занятым, если время запроса истекло или клиент отключился
+func finishContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), finishTimeout)
+}
+
+// bufferedResponse - запоминает ответ обработчика, не отправляя его клиенту
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// flush - отправляет запомненный ответ клиенту
+func (b *bufferedResponse) flush(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.statusCode())
+	if _, err := w.Write(b.body.Bytes()); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
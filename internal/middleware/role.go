@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"merch-shop/internal/errs"
+	"merch-shop/internal/httperr"
+	"net/http"
+)
+
+// RequireRole пропускает только пользователей с одной из перечисленных ролей.
+// Должен подключаться после AuthMiddleware, например к подроутеру административных эндпоинтов.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(roles))
+	for _, role := range roles {
+		allowed[role] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := r.Context().Value("role").(string)
+			if !ok {
+				httperr.Write(w, r, errs.ErrUnauthorized)
+				return
+			}
+
+			if _, ok = allowed[role]; !ok {
+				httperr.Write(w, r, errs.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout ограничивает время обработки запроса: по истечении timeout контекст запроса отменяется
+// вместе с запросами к базе, а обработчик отвечает ошибкой REQUEST_TIMEOUT.
+// Вложенный Timeout не продлевает срок внешнего, поэтому маршруты с разным сроком подключаются к разным подроутерам.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"github.com/stretchr/testify/assert"
+	"merch-shop/migrations"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []int
+		wantErr      bool
+	}{
+		{
+			name: "миграции упорядочены по версии",
+			files: fstest.MapFS{
+				"0002_add_stock.up.sql":     {Data: []byte("ALTER TABLE merches ADD stock bigint")},
+				"0002_add_stock.down.sql":   {Data: []byte("ALTER TABLE merches DROP stock")},
+				"0001_init.up.sql":          {Data: []byte("CREATE TABLE merches (id bigint)")},
+				"0001_init.down.sql":        {Data: []byte("DROP TABLE merches")},
+				"README.md":                 {Data: []byte("не миграция")},
+				"0003_draft.up.sql.orig":    {Data: []byte("игнорируется")},
+				"embed.go":                  {Data: []byte("package migrations")},
+				"0010_add_index.up.sql":     {Data: []byte("CREATE INDEX idx ON merches (id)")},
+				"0010_add_index.down.sql":   {Data: []byte("DROP INDEX idx")},
+				"0004_unrelated.up.sql.bak": {Data: []byte("игнорируется")},
+			},
+			wantVersions: []int{1, 2, 10},
+		},
+		{
+			name: "нет файла отката",
+			files: fstest.MapFS{
+				"0001_init.up.sql": {Data: []byte("CREATE TABLE merches (id bigint)")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "разные названия у одной версии",
+			files: fstest.MapFS{
+				"0001_init.up.sql":    {Data: []byte("CREATE TABLE merches (id bigint)")},
+				"0001_other.down.sql": {Data: []byte("DROP TABLE merches")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loaded, err := Load(tt.files)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			versions := make([]int, len(loaded))
+			for i, migration := range loaded {
+				versions[i] = migration.Version
+			}
+			assert.Equal(t, tt.wantVersions, versions)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, loaded) {
+		assert.Equal(t, 1, loaded[0].Version)
+	}
+	for i := 1; i < len(loaded); i++ {
+		assert.Equal(t, loaded[i-1].Version+1, loaded[i].Version, "versions must be consecutive")
+	}
+}
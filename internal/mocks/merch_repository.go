@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	models "merch-shop/internal/models"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,9 +15,45 @@ type MerchRepository struct {
 	mock.Mock
 }
 
-// GetMerchByName provides a mock function with given fields: name
-func (_m *MerchRepository) GetMerchByName(name string) (*models.Merch, error) {
-	ret := _m.Called(name)
+// CreateMerch provides a mock function with given fields: ctx, merch
+func (_m *MerchRepository) CreateMerch(ctx context.Context, merch *models.Merch) error {
+	ret := _m.Called(ctx, merch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateMerch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Merch) error); ok {
+		r0 = rf(ctx, merch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteMerch provides a mock function with given fields: ctx, merch
+func (_m *MerchRepository) DeleteMerch(ctx context.Context, merch *models.Merch) error {
+	ret := _m.Called(ctx, merch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMerch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Merch) error); ok {
+		r0 = rf(ctx, merch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetMerchByName provides a mock function with given fields: ctx, name
+func (_m *MerchRepository) GetMerchByName(ctx context.Context, name string) (*models.Merch, error) {
+	ret := _m.Called(ctx, name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetMerchByName")
@@ -23,19 +61,19 @@ func (_m *MerchRepository) GetMerchByName(name string) (*models.Merch, error) {
 
 	var r0 *models.Merch
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*models.Merch, error)); ok {
-		return rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.Merch, error)); ok {
+		return rf(ctx, name)
 	}
-	if rf, ok := ret.Get(0).(func(string) *models.Merch); ok {
-		r0 = rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Merch); ok {
+		r0 = rf(ctx, name)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Merch)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(name)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -43,6 +81,97 @@ func (_m *MerchRepository) GetMerchByName(name string) (*models.Merch, error) {
 	return r0, r1
 }
 
+// ListMerch provides a mock function with given fields: ctx, limit, offset, sort
+func (_m *MerchRepository) ListMerch(ctx context.Context, limit int, offset int, sort string) ([]models.Merch, int64, error) {
+	ret := _m.Called(ctx, limit, offset, sort)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListMerch")
+	}
+
+	var r0 []models.Merch
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string) ([]models.Merch, int64, error)); ok {
+		return rf(ctx, limit, offset, sort)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string) []models.Merch); ok {
+		r0 = rf(ctx, limit, offset, sort)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Merch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, string) int64); ok {
+		r1 = rf(ctx, limit, offset, sort)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int, int, string) error); ok {
+		r2 = rf(ctx, limit, offset, sort)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RestockMerch provides a mock function with given fields: ctx, merch, quantity
+func (_m *MerchRepository) RestockMerch(ctx context.Context, merch *models.Merch, quantity int) error {
+	ret := _m.Called(ctx, merch, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestockMerch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Merch, int) error); ok {
+		r0 = rf(ctx, merch, quantity)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetMerchStock provides a mock function with given fields: ctx, merch, stock
+func (_m *MerchRepository) SetMerchStock(ctx context.Context, merch *models.Merch, stock *int) error {
+	ret := _m.Called(ctx, merch, stock)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetMerchStock")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Merch, *int) error); ok {
+		r0 = rf(ctx, merch, stock)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateMerch provides a mock function with given fields: ctx, merch
+func (_m *MerchRepository) UpdateMerch(ctx context.Context, merch *models.Merch) error {
+	ret := _m.Called(ctx, merch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMerch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Merch) error); ok {
+		r0 = rf(ctx, merch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewMerchRepository creates a new instance of MerchRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMerchRepository(t interface {
@@ -3,8 +3,12 @@
 package mocks
 
 import (
+	context "context"
+
 	models "merch-shop/internal/models"
 
+	time "time"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -13,17 +17,95 @@ type UserRepository struct {
 	mock.Mock
 }
 
-// BuyMerch provides a mock function with given fields: user, merch
-func (_m *UserRepository) BuyMerch(user *models.User, merch *models.Merch) error {
-	ret := _m.Called(user, merch)
+// AcceptCoinRequest provides a mock function with given fields: ctx, request, transaction, limits, now
+func (_m *UserRepository) AcceptCoinRequest(ctx context.Context, request *models.CoinRequest, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error {
+	ret := _m.Called(ctx, request, transaction, limits, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AcceptCoinRequest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.CoinRequest, *models.Transaction, models.TransferLimits, time.Time) error); ok {
+		r0 = rf(ctx, request, transaction, limits, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AdjustBalance provides a mock function with given fields: ctx, adminID, user, amount, reason, maxBalance
+func (_m *UserRepository) AdjustBalance(ctx context.Context, adminID uint, user *models.User, amount int, reason string, maxBalance *int) (*models.GrantResult, error) {
+	ret := _m.Called(ctx, adminID, user, amount, reason, maxBalance)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AdjustBalance")
+	}
+
+	var r0 *models.GrantResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, *models.User, int, string, *int) (*models.GrantResult, error)); ok {
+		return rf(ctx, adminID, user, amount, reason, maxBalance)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, *models.User, int, string, *int) *models.GrantResult); ok {
+		r0 = rf(ctx, adminID, user, amount, reason, maxBalance)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.GrantResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, *models.User, int, string, *int) error); ok {
+		r1 = rf(ctx, adminID, user, amount, reason, maxBalance)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ApplyGrants provides a mock function with given fields: ctx, adminID, reason, lines, dryRun, maxBalance
+func (_m *UserRepository) ApplyGrants(ctx context.Context, adminID uint, reason string, lines []models.GrantLine, dryRun bool, maxBalance *int) (*models.GrantBatchResponse, error) {
+	ret := _m.Called(ctx, adminID, reason, lines, dryRun, maxBalance)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyGrants")
+	}
+
+	var r0 *models.GrantBatchResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, string, []models.GrantLine, bool, *int) (*models.GrantBatchResponse, error)); ok {
+		return rf(ctx, adminID, reason, lines, dryRun, maxBalance)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, string, []models.GrantLine, bool, *int) *models.GrantBatchResponse); ok {
+		r0 = rf(ctx, adminID, reason, lines, dryRun, maxBalance)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.GrantBatchResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, string, []models.GrantLine, bool, *int) error); ok {
+		r1 = rf(ctx, adminID, reason, lines, dryRun, maxBalance)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BuyMerch provides a mock function with given fields: ctx, user, merch
+func (_m *UserRepository) BuyMerch(ctx context.Context, user *models.User, merch *models.Merch) error {
+	ret := _m.Called(ctx, user, merch)
 
 	if len(ret) == 0 {
 		panic("no return value specified for BuyMerch")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.User, *models.Merch) error); ok {
-		r0 = rf(user, merch)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, *models.Merch) error); ok {
+		r0 = rf(ctx, user, merch)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -31,17 +113,101 @@ func (_m *UserRepository) BuyMerch(user *models.User, merch *models.Merch) error
 	return r0
 }
 
-// CreateUser provides a mock function with given fields: user
-func (_m *UserRepository) CreateUser(user *models.User) error {
-	ret := _m.Called(user)
+// CreateCoinRequest provides a mock function with given fields: ctx, request
+func (_m *UserRepository) CreateCoinRequest(ctx context.Context, request *models.CoinRequest) error {
+	ret := _m.Called(ctx, request)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateCoinRequest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.CoinRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateHold provides a mock function with given fields: ctx, user, hold
+func (_m *UserRepository) CreateHold(ctx context.Context, user *models.User, hold *models.Hold) error {
+	ret := _m.Called(ctx, user, hold)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, *models.Hold) error); ok {
+		r0 = rf(ctx, user, hold)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateOrder provides a mock function with given fields: ctx, user, items
+func (_m *UserRepository) CreateOrder(ctx context.Context, user *models.User, items []models.OrderItemRequest) (*models.Order, error) {
+	ret := _m.Called(ctx, user, items)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOrder")
+	}
+
+	var r0 *models.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, []models.OrderItemRequest) (*models.Order, error)); ok {
+		return rf(ctx, user, items)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, []models.OrderItemRequest) *models.Order); ok {
+		r0 = rf(ctx, user, items)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.User, []models.OrderItemRequest) error); ok {
+		r1 = rf(ctx, user, items)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateUser provides a mock function with given fields: ctx, user
+func (_m *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User) error); ok {
+		r0 = rf(ctx, user)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeclineCoinRequest provides a mock function with given fields: ctx, request, now
+func (_m *UserRepository) DeclineCoinRequest(ctx context.Context, request *models.CoinRequest, now time.Time) error {
+	ret := _m.Called(ctx, request, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeclineCoinRequest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.CoinRequest, time.Time) error); ok {
+		r0 = rf(ctx, request, now)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -49,9 +215,9 @@ func (_m *UserRepository) CreateUser(user *models.User) error {
 	return r0
 }
 
-// GetCoinHistory provides a mock function with given fields: userID
-func (_m *UserRepository) GetCoinHistory(userID uint) (models.CoinHistory, error) {
-	ret := _m.Called(userID)
+// GetCoinHistory provides a mock function with given fields: ctx, userID, limit
+func (_m *UserRepository) GetCoinHistory(ctx context.Context, userID uint, limit int) (models.CoinHistory, error) {
+	ret := _m.Called(ctx, userID, limit)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetCoinHistory")
@@ -59,17 +225,107 @@ func (_m *UserRepository) GetCoinHistory(userID uint) (models.CoinHistory, error
 
 	var r0 models.CoinHistory
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uint) (models.CoinHistory, error)); ok {
-		return rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) (models.CoinHistory, error)); ok {
+		return rf(ctx, userID, limit)
 	}
-	if rf, ok := ret.Get(0).(func(uint) models.CoinHistory); ok {
-		r0 = rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) models.CoinHistory); ok {
+		r0 = rf(ctx, userID, limit)
 	} else {
 		r0 = ret.Get(0).(models.CoinHistory)
 	}
 
-	if rf, ok := ret.Get(1).(func(uint) error); ok {
-		r1 = rf(userID)
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int) error); ok {
+		r1 = rf(ctx, userID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCoinRequest provides a mock function with given fields: ctx, id
+func (_m *UserRepository) GetCoinRequest(ctx context.Context, id uint) (*models.CoinRequest, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCoinRequest")
+	}
+
+	var r0 *models.CoinRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*models.CoinRequest, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *models.CoinRequest); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.CoinRequest)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHistory provides a mock function with given fields: ctx, userID, query, after
+func (_m *UserRepository) GetHistory(ctx context.Context, userID uint, query models.HistoryQuery, after *models.HistoryCursor) ([]models.HistoryEntry, error) {
+	ret := _m.Called(ctx, userID, query, after)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHistory")
+	}
+
+	var r0 []models.HistoryEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, models.HistoryQuery, *models.HistoryCursor) ([]models.HistoryEntry, error)); ok {
+		return rf(ctx, userID, query, after)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, models.HistoryQuery, *models.HistoryCursor) []models.HistoryEntry); ok {
+		r0 = rf(ctx, userID, query, after)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.HistoryEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, models.HistoryQuery, *models.HistoryCursor) error); ok {
+		r1 = rf(ctx, userID, query, after)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHold provides a mock function with given fields: ctx, id
+func (_m *UserRepository) GetHold(ctx context.Context, id uint) (*models.Hold, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHold")
+	}
+
+	var r0 *models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*models.Hold, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *models.Hold); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -77,9 +333,69 @@ func (_m *UserRepository) GetCoinHistory(userID uint) (models.CoinHistory, error
 	return r0, r1
 }
 
-// GetUserByUsername provides a mock function with given fields: username
-func (_m *UserRepository) GetUserByUsername(username string) (*models.User, error) {
-	ret := _m.Called(username)
+// GetPurchase provides a mock function with given fields: ctx, id
+func (_m *UserRepository) GetPurchase(ctx context.Context, id uint) (*models.Purchase, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPurchase")
+	}
+
+	var r0 *models.Purchase
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*models.Purchase, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *models.Purchase); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Purchase)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserByID provides a mock function with given fields: ctx, id
+func (_m *UserRepository) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserByID")
+	}
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*models.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *models.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserByUsername provides a mock function with given fields: ctx, username
+func (_m *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ret := _m.Called(ctx, username)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByUsername")
@@ -87,19 +403,19 @@ func (_m *UserRepository) GetUserByUsername(username string) (*models.User, erro
 
 	var r0 *models.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*models.User, error)); ok {
-		return rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.User, error)); ok {
+		return rf(ctx, username)
 	}
-	if rf, ok := ret.Get(0).(func(string) *models.User); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.User); ok {
+		r0 = rf(ctx, username)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -107,9 +423,9 @@ func (_m *UserRepository) GetUserByUsername(username string) (*models.User, erro
 	return r0, r1
 }
 
-// GetUserInventory provides a mock function with given fields: userID
-func (_m *UserRepository) GetUserInventory(userID uint) ([]models.Item, error) {
-	ret := _m.Called(userID)
+// GetUserInventory provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) GetUserInventory(ctx context.Context, userID uint) ([]models.Item, error) {
+	ret := _m.Called(ctx, userID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserInventory")
@@ -117,19 +433,127 @@ func (_m *UserRepository) GetUserInventory(userID uint) ([]models.Item, error) {
 
 	var r0 []models.Item
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uint) ([]models.Item, error)); ok {
-		return rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]models.Item, error)); ok {
+		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(uint) []models.Item); ok {
-		r0 = rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []models.Item); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Item)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(uint) error); ok {
-		r1 = rf(userID)
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementTokenVersion provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) IncrementTokenVersion(ctx context.Context, userID uint) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementTokenVersion")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListActiveHolds provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) ListActiveHolds(ctx context.Context, userID uint) ([]models.Hold, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListActiveHolds")
+	}
+
+	var r0 []models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]models.Hold, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []models.Hold); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListCoinRequests provides a mock function with given fields: ctx, userID, query, now, limit
+func (_m *UserRepository) ListCoinRequests(ctx context.Context, userID uint, query models.CoinRequestQuery, now time.Time, limit int) ([]models.CoinRequest, error) {
+	ret := _m.Called(ctx, userID, query, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCoinRequests")
+	}
+
+	var r0 []models.CoinRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, models.CoinRequestQuery, time.Time, int) ([]models.CoinRequest, error)); ok {
+		return rf(ctx, userID, query, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, models.CoinRequestQuery, time.Time, int) []models.CoinRequest); ok {
+		r0 = rf(ctx, userID, query, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.CoinRequest)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, models.CoinRequestQuery, time.Time, int) error); ok {
+		r1 = rf(ctx, userID, query, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListExpiredHolds provides a mock function with given fields: ctx, now, limit
+func (_m *UserRepository) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListExpiredHolds")
+	}
+
+	var r0 []models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]models.Hold, error)); ok {
+		return rf(ctx, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []models.Hold); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -137,17 +561,101 @@ func (_m *UserRepository) GetUserInventory(userID uint) ([]models.Item, error) {
 	return r0, r1
 }
 
-// SendCoin provides a mock function with given fields: fromUser, toUser, amount
-func (_m *UserRepository) SendCoin(fromUser *models.User, toUser *models.User, amount int) error {
-	ret := _m.Called(fromUser, toUser, amount)
+// RefundPurchase provides a mock function with given fields: ctx, purchaseID
+func (_m *UserRepository) RefundPurchase(ctx context.Context, purchaseID uint) (*models.RefundResponse, error) {
+	ret := _m.Called(ctx, purchaseID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefundPurchase")
+	}
+
+	var r0 *models.RefundResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*models.RefundResponse, error)); ok {
+		return rf(ctx, purchaseID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *models.RefundResponse); ok {
+		r0 = rf(ctx, purchaseID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RefundResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, purchaseID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseHold provides a mock function with given fields: ctx, hold, recipient, transaction, limits, now
+func (_m *UserRepository) ReleaseHold(ctx context.Context, hold *models.Hold, recipient *models.User, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error {
+	ret := _m.Called(ctx, hold, recipient, transaction, limits, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Hold, *models.User, *models.Transaction, models.TransferLimits, time.Time) error); ok {
+		r0 = rf(ctx, hold, recipient, transaction, limits, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReturnHold provides a mock function with given fields: ctx, hold, status, now
+func (_m *UserRepository) ReturnHold(ctx context.Context, hold *models.Hold, status string, now time.Time) error {
+	ret := _m.Called(ctx, hold, status, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReturnHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Hold, string, time.Time) error); ok {
+		r0 = rf(ctx, hold, status, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendCoin provides a mock function with given fields: ctx, fromUser, toUser, transaction, limits
+func (_m *UserRepository) SendCoin(ctx context.Context, fromUser *models.User, toUser *models.User, transaction *models.Transaction, limits models.TransferLimits) error {
+	ret := _m.Called(ctx, fromUser, toUser, transaction, limits)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SendCoin")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.User, *models.User, int) error); ok {
-		r0 = rf(fromUser, toUser, amount)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, *models.User, *models.Transaction, models.TransferLimits) error); ok {
+		r0 = rf(ctx, fromUser, toUser, transaction, limits)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateUserRole provides a mock function with given fields: ctx, username, role
+func (_m *UserRepository) UpdateUserRole(ctx context.Context, username string, role string) error {
+	ret := _m.Called(ctx, username, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUserRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, username, role)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Состояния запроса монет
+const (
+	CoinRequestPending  = "pending"  // Ожидает ответа плательщика
+	CoinRequestAccepted = "accepted" // Плательщик перевёл монеты
+	CoinRequestDeclined = "declined" // Плательщик отказал
+	CoinRequestExpired  = "expired"  // Срок ответа истёк; в базе не хранится, вычисляется по ExpiresAt
+)
+
+// Направления списка запросов монет
+const (
+	CoinRequestsIncoming = "incoming" // Запросы, адресованные пользователю
+	CoinRequestsOutgoing = "outgoing" // Запросы, созданные пользователем
+)
+
+// CoinRequest - запрос монет: Requester просит Payer перевести ему Amount монет
+type CoinRequest struct {
+	gorm.Model
+	RequesterID   uint       `gorm:"not null;index"`
+	PayerID       uint       `gorm:"not null;index"`
+	Amount        int        `gorm:"not null"`
+	Note          string     `gorm:"not null;default:''"` // Комментарий для плательщика
+	Status        string     `gorm:"not null;index"`
+	ExpiresAt     time.Time  `gorm:"not null"` // После этого момента запрос нельзя принять
+	RespondedAt   *time.Time // Когда плательщик принял или отклонил запрос
+	TransactionID *uint      // Перевод, которым оплачен запрос
+	RequesterName string     `gorm:"->;-:migration"` // Имя запросившего; только для чтения
+	PayerName     string     `gorm:"->;-:migration"` // Имя плательщика; только для чтения
+}
+
+// EffectiveStatus - состояние запроса в момент now с учётом истечения срока
+func (r *CoinRequest) EffectiveStatus(now time.Time) string {
+	if r.Status == CoinRequestPending && !now.Before(r.ExpiresAt) {
+		return CoinRequestExpired
+	}
+	return r.Status
+}
+
+// ToResponse - представление запроса монет в ответе API
+func (r *CoinRequest) ToResponse(now time.Time) CoinRequestResponse {
+	return CoinRequestResponse{
+		ID:          r.ID,
+		Requester:   r.RequesterName,
+		Payer:       r.PayerName,
+		Amount:      r.Amount,
+		Note:        r.Note,
+		Status:      r.EffectiveStatus(now),
+		ExpiresAt:   r.ExpiresAt,
+		RespondedAt: r.RespondedAt,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+// CoinRequestQuery - параметры запроса списка запросов монет
+type CoinRequestQuery struct {
+	Direction string // incoming или outgoing
+	Status    string // pending, accepted, declined или expired; пустое значение — все запросы
+}
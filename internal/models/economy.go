@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// economySettingsID - настройки экономики хранятся в единственной строке таблицы
+const economySettingsID = 1
+
+// EconomySettings - параметры экономики магазина. Незаданные (nil) ограничения не действуют.
+type EconomySettings struct {
+	ID               uint      `gorm:"primarykey" json:"-"`
+	WelcomeBonus     int       `gorm:"not null" json:"welcomeBonus"` // Стартовый баланс нового пользователя
+	MinTransfer      int       `gorm:"not null" json:"minTransfer"`  // Минимальная сумма перевода
+	MaxTransfer      *int      `json:"maxTransfer"`                  // Максимальная сумма одного перевода
+	DailyTransferCap *int      `json:"dailyTransferCap"`             // Сколько монет пользователь может отправить за сутки (UTC)
+	MaxBalance       *int      `json:"maxBalance"`                   // Максимальный баланс, который можно получить переводами и начислениями
+	UpdatedAt        time.Time `json:"updatedAt"`                    // Время последнего изменения
+	UpdatedBy        string    `json:"updatedBy,omitempty"`          // Администратор, изменивший настройки
+}
+
+// DefaultEconomySettings - настройки по умолчанию: приветственный бонус 1000 монет, переводы без ограничений
+func DefaultEconomySettings() EconomySettings {
+	return EconomySettings{ID: economySettingsID, WelcomeBonus: 1000, MinTransfer: 1}
+}
+
+// TransferLimits - ограничения перевода, которые проверяются в транзакции под блокировкой строк пользователей
+type TransferLimits struct {
+	DailyCap   *int // Сколько монет отправитель может перевести за текущие сутки (UTC)
+	MaxBalance *int // Максимальный баланс получателя после перевода
+}
+
+// TransferLimits - ограничения перевода из настроек экономики
+func (s *EconomySettings) TransferLimits() TransferLimits {
+	return TransferLimits{DailyCap: s.DailyTransferCap, MaxBalance: s.MaxBalance}
+}
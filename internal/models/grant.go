@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// Grant - начисление или списание монет администратором. Начисления из одного массового
+// зачисления связаны общим пакетом (GrantBatch), разовые корректировки пакета не имеют.
+type Grant struct {
+	gorm.Model
+	UserID  uint   `gorm:"not null;index" json:"userId"`
+	AdminID uint   `gorm:"not null" json:"adminId"`        // Кто выполнил операцию
+	BatchID *uint  `gorm:"index" json:"batchId,omitempty"` // Пакет массового зачисления
+	Amount  int    `gorm:"not null" json:"amount"`         // Положительное — начисление, отрицательное — списание
+	Reason  string `gorm:"not null" json:"reason"`         // Обоснование операции
+}
+
+// GrantBatch - массовое зачисление монет
+type GrantBatch struct {
+	gorm.Model
+	AdminID uint   `gorm:"not null"`
+	Reason  string `gorm:"not null"`
+	Total   int    `gorm:"not null"` // Сумма всех строк пакета
+}
+
+// GrantLine - строка массового зачисления
+type GrantLine struct {
+	Username string `json:"username"` // Получатель
+	Amount   int    `json:"amount"`   // Положительное — начисление, отрицательное — списание
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Виды записей истории операций
+const (
+	HistoryTypeSent     = "sent"     // Отправленный перевод
+	HistoryTypeReceived = "received" // Полученный перевод
+	HistoryTypePurchase = "purchase" // Покупка мерча
+	HistoryTypeRefund   = "refund"   // Возврат покупки
+	HistoryTypeGrant    = "grant"    // Начисление или списание администратором
+)
+
+// HistoryTypes - все виды записей, которые возвращает история; по ним проверяется курсор, полученный от клиента
+var HistoryTypes = map[string]bool{
+	HistoryTypeSent:     true,
+	HistoryTypeReceived: true,
+	HistoryTypePurchase: true,
+	HistoryTypeRefund:   true,
+	HistoryTypeGrant:    true,
+}
+
+// HistoryDirections - значения фильтра direction и соответствующие им виды записей
+var HistoryDirections = map[string]string{
+	"sent":      HistoryTypeSent,
+	"received":  HistoryTypeReceived,
+	"purchases": HistoryTypePurchase,
+	"refunds":   HistoryTypeRefund,
+	"grants":    HistoryTypeGrant,
+}
+
+// HistoryEntry - запись истории операций пользователя
+type HistoryEntry struct {
+	ID           uint      `json:"id"`                     // id перевода, покупки или начисления (для возврата — id возвращённой покупки)
+	Type         string    `json:"type"`                   // sent, received, purchase, refund или grant
+	Counterparty string    `json:"counterparty,omitempty"` // Второй участник перевода
+	Item         string    `json:"item,omitempty"`         // Купленный товар
+	Quantity     int       `json:"quantity,omitempty"`     // Количество купленных предметов
+	Amount       int       `json:"amount"`                 // Количество монет; для списания администратором — отрицательное
+	Reason       string    `json:"reason,omitempty"`       // Обоснование начисления администратором
+	Message      string    `json:"message,omitempty"`      // Сообщение к переводу
+	Category     string    `json:"category,omitempty"`     // Категория перевода
+	CreatedAt    time.Time `json:"createdAt"`              // Время операции
+}
+
+// HistoryCursor - позиция последней записи страницы; следующая страница начинается после неё
+type HistoryCursor struct {
+	CreatedAt time.Time `json:"t"`
+	Type      string    `json:"k"`
+	ID        uint      `json:"i"`
+}
+
+// HistoryQuery - параметры запроса страницы истории операций
+type HistoryQuery struct {
+	Limit        int        // Размер страницы
+	Cursor       string     // nextCursor предыдущей страницы
+	Direction    string     // sent, received, purchases, refunds или grants; пустое значение — все записи
+	Counterparty string     // Только переводы с этим пользователем
+	Category     string     // Только переводы этой категории
+	MinAmount    *int       // Сумма не меньше
+	MaxAmount    *int       // Сумма не больше
+	From         *time.Time // Не раньше этого момента
+	To           *time.Time // Раньше этого момента
+}
+
+// HistoryResponse - страница истории операций
+type HistoryResponse struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"nextCursor,omitempty"` // Передаётся в cursor для следующей страницы
+}
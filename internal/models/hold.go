@@ -0,0 +1,50 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Состояния удержания
+const (
+	HoldActive   = "active"   // Монеты удерживаются
+	HoldReleased = "released" // Монеты переданы получателю
+	HoldReturned = "returned" // Монеты возвращены владельцу
+	HoldExpired  = "expired"  // Срок истёк, монеты возвращены владельцу автоматически
+)
+
+// Hold - удержание монет: Amount монет владельца переносится со свободного баланса на счёт удержаний
+// и позже передаётся получателю или возвращается владельцу
+type Hold struct {
+	gorm.Model
+	UserID        uint       `gorm:"not null;index"` // Владелец удержанных монет
+	Amount        int        `gorm:"not null"`
+	Note          string     `gorm:"not null;default:''"` // Назначение, например описание задачи
+	Status        string     `gorm:"not null;index"`
+	ExpiresAt     time.Time  `gorm:"not null;index"` // После этого момента монеты возвращаются владельцу
+	ResolvedAt    *time.Time // Когда монеты переданы или возвращены
+	RecipientID   *uint      // Кому переданы монеты
+	TransactionID *uint      // Перевод, которым монеты переданы получателю
+	RecipientName string     `gorm:"->;-:migration"` // Имя получателя; только для чтения
+}
+
+// HoldSettings - параметры удержаний
+type HoldSettings struct {
+	DefaultTTL     time.Duration // Срок удержания, если он не указан
+	MaxTTL         time.Duration // Наибольший срок удержания
+	ExpiryInterval time.Duration // Как часто возвращать монеты истёкших удержаний
+}
+
+// ToResponse - представление удержания в ответе API
+func (h *Hold) ToResponse() HoldResponse {
+	return HoldResponse{
+		ID:         h.ID,
+		Amount:     h.Amount,
+		Note:       h.Note,
+		Status:     h.Status,
+		ExpiresAt:  h.ExpiresAt,
+		ResolvedAt: h.ResolvedAt,
+		Recipient:  h.RecipientName,
+		CreatedAt:  h.CreatedAt,
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotencyKey - сохранённый результат запроса, выполненного с заголовком Idempotency-Key.
+// gorm.Model не используется: мягкое удаление мешало бы повторно занять ключ после истечения окна.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primarykey"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time
+	Username    string    `gorm:"not null;uniqueIndex:idx_idempotency_keys_username_key"`
+	Key         string    `gorm:"not null;uniqueIndex:idx_idempotency_keys_username_key"`
+	RequestHash string    `gorm:"not null"` // Отпечаток метода, пути и тела запроса
+	LockedUntil time.Time // До какого времени ключ занят выполняющимся запросом
+	StatusCode  int       // HTTP-код сохранённого ответа, 0 — запрос ещё выполняется
+	ContentType string
+	Response    []byte
+}
+
+// Completed - сохранён ли уже ответ на запрос
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
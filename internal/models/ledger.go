@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Системные счета журнала
+const (
+	AccountIssuance = "system:issuance" // Источник выпущенных монет (приветственные бонусы, начальные остатки, начисления администраторов)
+	AccountShop     = "system:shop"     // Выручка магазина от покупок
+	AccountEscrow   = "system:escrow"   // Удержанные монеты пользователей
+)
+
+// Основания проводок
+const (
+	LedgerReasonOpeningBalance = "opening_balance"
+	LedgerReasonWelcomeBonus   = "welcome_bonus"
+	LedgerReasonTransfer       = "transfer"
+	LedgerReasonPurchase       = "purchase"
+	LedgerReasonRefund         = "refund"
+	LedgerReasonGrant          = "grant"
+	LedgerReasonHold           = "hold"
+	LedgerReasonHoldRelease    = "hold_release"
+	LedgerReasonHoldReturn     = "hold_return"
+)
+
+// LedgerEntry - проводка в журнале движения монет.
+// Журнал только дополняется: каждая операция записывает проводки с нулевой суммой, а записи не изменяются и не удаляются.
+type LedgerEntry struct {
+	ID            uint      `gorm:"primarykey"`
+	CreatedAt     time.Time `gorm:"not null"`
+	Account       string    `gorm:"not null;index"`
+	UserID        *uint     `gorm:"index"` // Заполняется для счетов пользователей
+	Delta         int       `gorm:"not null"`
+	Reason        string    `gorm:"not null"`
+	TransactionID *uint     // Ссылка на перевод
+	PurchaseID    *uint     // Ссылка на покупку
+	GrantID       *uint     // Ссылка на начисление администратором
+	HoldID        *uint     // Ссылка на удержание
+}
+
+// UserAccount - имя счёта пользователя в журнале
+func UserAccount(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// UserLeg - проводка по счёту пользователя
+func UserLeg(userID uint, delta int) LedgerEntry {
+	return LedgerEntry{Account: UserAccount(userID), UserID: &userID, Delta: delta}
+}
+
+// SystemLeg - проводка по системному счёту
+func SystemLeg(account string, delta int) LedgerEntry {
+	return LedgerEntry{Account: account, Delta: delta}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LoginAttempt - счётчик неудачных попыток входа по ключу: имени пользователя или IP-адресу клиента
+type LoginAttempt struct {
+	Key          string    `gorm:"primaryKey"` // "user:<username>" или "ip:<address>"
+	Failures     int       `gorm:"not null;default:0"`
+	BlockedUntil time.Time // До этого момента попытки входа по ключу отклоняются
+	UpdatedAt    time.Time `gorm:"not null"` // Время последней неудачной попытки
+}
+
+// LoginLimit - пороги неудачных попыток для одного вида ключа
+type LoginLimit struct {
+	FreeAttempts int // Попытки без задержки
+	MaxAttempts  int // После стольких попыток ключ блокируется на LockoutDuration
+}
+
+// LoginGuardSettings - параметры защиты от перебора паролей
+type LoginGuardSettings struct {
+	User            LoginLimit    // Пороги для имени пользователя
+	IP              LoginLimit    // Пороги для IP-адреса клиента
+	BaseDelay       time.Duration // Задержка после первой попытки сверх бесплатных; далее удваивается
+	MaxDelay        time.Duration // Верхняя граница задержки
+	LockoutDuration time.Duration // Длительность блокировки после MaxAttempts попыток
+	Window          time.Duration // Счётчик сбрасывается, если неудачных попыток не было дольше этого времени
+}
@@ -2,9 +2,28 @@ package models
 
 import "gorm.io/gorm"
 
+// Варианты сортировки каталога
+const (
+	MerchSortName      = "name"
+	MerchSortPriceAsc  = "price_asc"
+	MerchSortPriceDesc = "price_desc"
+)
+
 // Merch - структура пользователя
 type Merch struct {
 	gorm.Model
-	Name  string `gorm:"unique;not null" json:"name"`
-	Price int    `json:"price"`
+	Name         string `gorm:"unique;not null" json:"name"`
+	Price        int    `json:"price"`
+	Stock        *int   `json:"stock"`        // Остаток на складе; nil — без ограничения
+	PerUserLimit *int   `json:"perUserLimit"` // Сколько штук может купить один пользователь; nil — без ограничения
+}
+
+// ToItem - представление товара в ответах каталога
+func (m *Merch) ToItem() MerchItem {
+	return MerchItem{Name: m.Name, Price: m.Price, Stock: m.Stock, PerUserLimit: m.PerUserLimit}
+}
+
+// InStock - хватает ли на складе quantity штук
+func (m *Merch) InStock(quantity int) bool {
+	return m.Stock == nil || *m.Stock >= quantity
 }
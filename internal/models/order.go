@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// Order - заказ: одна или несколько строк (Purchase), оплаченных одним списанием
+type Order struct {
+	gorm.Model
+	UserID uint        `gorm:"not null;index"`
+	Total  int         `gorm:"not null"`
+	Lines  []OrderLine `gorm:"-"`
+}
+
+// OrderLine - строка оформленного заказа
+type OrderLine struct {
+	PurchaseID uint   `json:"purchaseId"` // id покупки; по нему оформляется возврат
+	Item       string `json:"item"`       // Название товара
+	Quantity   int    `json:"quantity"`   // Количество
+	UnitPrice  int    `json:"unitPrice"`  // Цена за единицу
+	Amount     int    `json:"amount"`     // Стоимость строки
+}
+
+// ToResponse - представление заказа в ответе API
+func (o *Order) ToResponse() OrderResponse {
+	return OrderResponse{ID: o.ID, Total: o.Total, Items: o.Lines, CreatedAt: o.CreatedAt}
+}
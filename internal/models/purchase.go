@@ -1,10 +1,24 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
-// Purchase - структура для хранения информации о покупке
+// Purchase - структура для хранения информации о покупке: строка заказа.
+// Покупки, сделанные до появления заказов, не привязаны к заказу и содержат один предмет.
+// Возвращённая покупка не удаляется, а помечается временем возврата.
 type Purchase struct {
 	gorm.Model
-	UserID  uint `gorm:"not null" json:"userId"`
-	MerchID uint `gorm:"not null" json:"merchId"`
+	UserID     uint       `gorm:"not null;index" json:"userId"`
+	MerchID    uint       `gorm:"not null;index" json:"merchId"`
+	OrderID    *uint      `gorm:"index" json:"orderId,omitempty"`
+	Quantity   int        `gorm:"not null;default:1" json:"quantity"`
+	UnitPrice  int        `gorm:"not null;default:0" json:"unitPrice"` // Цена за единицу на момент покупки
+	RefundedAt *time.Time `json:"refundedAt,omitempty"`                // Время возврата; nil — покупка действует
+}
+
+// Refunded - возвращена ли покупка
+func (p *Purchase) Refunded() bool {
+	return p.RefundedAt != nil
 }
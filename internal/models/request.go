@@ -1,13 +1,115 @@
 package models
 
+import "time"
+
 // AuthRequest - структура для запроса аутентификации
 type AuthRequest struct {
 	Username string `json:"username"` // Имя пользователя для аутентификации
 	Password string `json:"password"` // Пароль для аутентификации
 }
 
+// RegisterRequest - структура для запроса регистрации
+type RegisterRequest struct {
+	Username string `json:"username"` // Имя нового пользователя
+	Password string `json:"password"` // Пароль нового пользователя
+}
+
 // SendCoinRequest - структура для запроса отправки монет другому пользователю
 type SendCoinRequest struct {
-	ToUser string `json:"toUser"` // Имя пользователя, которому нужно отправить монеты
-	Amount int    `json:"amount"` // Количество монет, которые необходимо отправить
+	ToUser   string `json:"toUser"`             // Имя пользователя, которому нужно отправить монеты
+	Amount   int    `json:"amount"`             // Количество монет, которые необходимо отправить
+	Message  string `json:"message,omitempty"`  // Сообщение получателю; необязательно
+	Category string `json:"category,omitempty"` // Категория перевода (thanks, help, birthday, ...); необязательно
+}
+
+// AdjustBalanceRequest - структура для запроса корректировки баланса пользователя администратором
+type AdjustBalanceRequest struct {
+	Amount int    `json:"amount"` // Положительное — начисление, отрицательное — списание
+	Reason string `json:"reason"` // Обоснование; обязательно
+}
+
+// GrantRequest - структура для запроса массового зачисления монет
+type GrantRequest struct {
+	Reason string      `json:"reason"` // Обоснование для всех строк; обязательно
+	Grants []GrantLine `json:"grants"` // Получатели и суммы
+}
+
+// ScheduleTransferRequest - структура для запроса запланированного перевода; задаётся ровно одно из полей RunAt и Cron
+type ScheduleTransferRequest struct {
+	ToUser   string     `json:"toUser"`             // Получатель
+	Amount   int        `json:"amount"`             // Сумма каждого перевода
+	Message  string     `json:"message,omitempty"`  // Сообщение получателю
+	Category string     `json:"category,omitempty"` // Категория перевода
+	RunAt    *time.Time `json:"runAt,omitempty"`    // Время разового перевода (RFC 3339)
+	Cron     string     `json:"cron,omitempty"`     // Расписание повторяющегося перевода в формате cron (UTC)
+}
+
+// CreateOrderRequest - структура для запроса оформления заказа
+type CreateOrderRequest struct {
+	Items []OrderItemRequest `json:"items"` // Строки заказа
+}
+
+// CreateCoinRequestRequest - структура для запроса монет у другого пользователя
+type CreateCoinRequestRequest struct {
+	FromUser string `json:"fromUser"`       // У кого запрашиваются монеты
+	Amount   int    `json:"amount"`         // Сколько монет запрашивается
+	Note     string `json:"note,omitempty"` // Комментарий для плательщика
+}
+
+// CreateHoldRequest - структура для запроса удержания монет
+type CreateHoldRequest struct {
+	Amount    int        `json:"amount"`              // Сколько монет удержать
+	Note      string     `json:"note,omitempty"`      // Назначение удержания
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // Когда вернуть монеты, если они не переданы; по умолчанию через HOLD_TTL
+}
+
+// ReleaseHoldRequest - структура для запроса передачи удержанных монет
+type ReleaseHoldRequest struct {
+	ToUser string `json:"toUser"` // Получатель монет
+}
+
+// OrderItemRequest - строка заказа
+type OrderItemRequest struct {
+	Item     string `json:"item"`     // Название товара
+	Quantity int    `json:"quantity"` // Количество
+}
+
+// CreateMerchRequest - структура для запроса добавления товара в каталог
+type CreateMerchRequest struct {
+	Name         string `json:"name"`         // Название товара
+	Price        int    `json:"price"`        // Цена товара в монетах
+	Stock        *int   `json:"stock"`        // Остаток на складе; не задан — без ограничения
+	PerUserLimit *int   `json:"perUserLimit"` // Сколько штук может купить один пользователь; не задан — без ограничения
+}
+
+// UpdateMerchRequest - структура для запроса изменения товара; незаданные поля не меняются
+type UpdateMerchRequest struct {
+	Name         *string `json:"name"`         // Новое название товара
+	Price        *int    `json:"price"`        // Новая цена товара
+	PerUserLimit *int    `json:"perUserLimit"` // Новый лимит на пользователя; 0 снимает лимит
+}
+
+// StockRequest - структура для запроса изменения остатка; задаётся ровно одно из полей
+type StockRequest struct {
+	Add       *int `json:"add"`       // Пополнить склад на указанное количество
+	Set       *int `json:"set"`       // Установить остаток
+	Unlimited bool `json:"unlimited"` // Снять ограничение остатка
+}
+
+// MerchListQuery - параметры запроса страницы каталога
+type MerchListQuery struct {
+	Limit  int    // Размер страницы
+	Offset int    // Смещение от начала каталога
+	Sort   string // Порядок сортировки: name, price_asc, price_desc
+}
+
+// RefreshRequest - структура для запроса обновления токенов
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"` // Refresh-токен, полученный при входе или предыдущем обновлении
+}
+
+// LogoutRequest - структура для запроса выхода
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"` // Refresh-токен текущей сессии
+	AllSessions  bool   `json:"allSessions,omitempty"`  // Завершить все сессии пользователя
 }
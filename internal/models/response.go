@@ -1,20 +1,33 @@
 package models
 
-// ErrorResponse - структура для ответа с ошибкой.
+import "time"
+
+// ErrorResponse - структура для ответа с ошибкой в формате RFC 7807 (application/problem+json).
 type ErrorResponse struct {
-	Errors string `json:"errors"` // Сообщение об ошибке, описывающее проблему.
+	Type     string                 `json:"type"`              // Тип проблемы; about:blank — смысл задаётся статусом и кодом
+	Title    string                 `json:"title"`             // Краткое описание HTTP-статуса
+	Status   int                    `json:"status"`            // HTTP-статус ответа
+	Detail   string                 `json:"detail"`            // Описание ошибки для человека
+	Instance string                 `json:"instance"`          // Путь запроса, вызвавшего ошибку
+	Code     string                 `json:"code"`              // Стабильный машиночитаемый код ошибки
+	Details  map[string]interface{} `json:"details,omitempty"` // Подробности конкретного случая
+	Errors   string                 `json:"errors"`            // Сообщение об ошибке; оставлено для совместимости со старыми клиентами
 }
 
 // AuthResponse - структура для ответа с токеном
 type AuthResponse struct {
-	Token string `json:"token"` // JWT-токен для доступа к защищенным ресурсам.
+	Token        string `json:"token"`                  // JWT-токен для доступа к защищенным ресурсам.
+	RefreshToken string `json:"refreshToken,omitempty"` // Непрозрачный токен для получения новой пары токенов.
+	ExpiresIn    int    `json:"expiresIn,omitempty"`    // Время жизни JWT-токена в секундах.
 }
 
 // InfoResponse - структура для ответа с информацией о монетах, инвентаре и истории транзакций.
 type InfoResponse struct {
-	Coins       int         `json:"coins"`       // Количество доступных монет
-	Inventory   []Item      `json:"inventory"`   // Инвентарь пользователя
-	CoinHistory CoinHistory `json:"coinHistory"` // История транзакций с монетами
+	Coins       int            `json:"coins"`           // Количество доступных монет
+	HeldCoins   int            `json:"heldCoins"`       // Удержанные монеты
+	Holds       []HoldResponse `json:"holds,omitempty"` // Действующие удержания
+	Inventory   []Item         `json:"inventory"`       // Инвентарь пользователя
+	CoinHistory CoinHistory    `json:"coinHistory"`     // История транзакций с монетами
 }
 
 // Item - структура для предмета в инвентаре.
@@ -31,7 +44,152 @@ type CoinHistory struct {
 
 // CoinTransaction - структура для транзакции с монетами.
 type CoinTransaction struct {
-	FromUser string `json:"fromUser,omitempty"` // Отправитель (если монеты получены)
-	ToUser   string `json:"toUser,omitempty"`   // Получатель (если монеты отправлены)
-	Amount   int    `json:"amount"`             // Количество монет
+	FromUser  string    `json:"fromUser,omitempty"` // Отправитель (если монеты получены)
+	ToUser    string    `json:"toUser,omitempty"`   // Получатель (если монеты отправлены)
+	Amount    int       `json:"amount"`             // Количество монет
+	Message   string    `json:"message,omitempty"`  // Сообщение к переводу
+	Category  string    `json:"category,omitempty"` // Категория перевода
+	CreatedAt time.Time `json:"createdAt"`          // Время перевода
+}
+
+// ReconciliationReport - результат сверки балансов пользователей с журналом проводок.
+type ReconciliationReport struct {
+	Balanced      bool                 `json:"balanced"`      // Сумма всех проводок равна нулю
+	Imbalance     int                  `json:"imbalance"`     // Сумма всех проводок журнала
+	Discrepancies []BalanceDiscrepancy `json:"discrepancies"` // Пользователи с расхождением баланса
+}
+
+// BalanceDiscrepancy - расхождение баланса пользователя с журналом.
+type BalanceDiscrepancy struct {
+	UserID      uint   `json:"userId"`
+	Username    string `json:"username"`
+	CachedCoins int    `json:"cachedCoins"` // Баланс в users.coins
+	LedgerCoins int    `json:"ledgerCoins"` // Сумма проводок по счёту пользователя
+}
+
+// MerchItem - структура товара в каталоге.
+type MerchItem struct {
+	Name         string `json:"name"`                   // Название товара
+	Price        int    `json:"price"`                  // Цена товара в монетах
+	Stock        *int   `json:"stock"`                  // Остаток на складе; null — без ограничения
+	PerUserLimit *int   `json:"perUserLimit,omitempty"` // Сколько штук может купить один пользователь
+}
+
+// MerchListResponse - структура для ответа со страницей каталога.
+type MerchListResponse struct {
+	Items  []MerchItem `json:"items"`  // Товары на странице
+	Total  int64       `json:"total"`  // Общее число товаров в каталоге
+	Limit  int         `json:"limit"`  // Размер страницы
+	Offset int         `json:"offset"` // Смещение от начала каталога
+}
+
+// JWKS - набор открытых ключей проверки JWT-токенов (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK - открытый ключ в формате JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`           // Тип ключа: RSA или OKP
+	Kid string `json:"kid"`           // Идентификатор ключа
+	Use string `json:"use"`           // Назначение ключа: sig
+	Alg string `json:"alg"`           // Алгоритм подписи
+	N   string `json:"n,omitempty"`   // Модуль RSA
+	E   string `json:"e,omitempty"`   // Открытая экспонента RSA
+	Crv string `json:"crv,omitempty"` // Кривая OKP: Ed25519
+	X   string `json:"x,omitempty"`   // Открытый ключ Ed25519
+}
+
+// OrderResponse - структура для ответа с оформленным заказом.
+type OrderResponse struct {
+	ID        uint        `json:"id"`        // Номер заказа
+	Total     int         `json:"total"`     // Списано монет
+	Items     []OrderLine `json:"items"`     // Строки заказа
+	CreatedAt time.Time   `json:"createdAt"` // Время оформления
+}
+
+// RefundResponse - структура для ответа с оформленным возвратом.
+type RefundResponse struct {
+	PurchaseID uint      `json:"purchaseId"` // Возвращённая покупка
+	Item       string    `json:"item"`       // Название товара
+	Quantity   int       `json:"quantity"`   // Количество возвращённых предметов
+	Amount     int       `json:"amount"`     // Возвращено монет
+	Balance    int       `json:"balance"`    // Баланс покупателя после возврата
+	RefundedAt time.Time `json:"refundedAt"` // Время возврата
+}
+
+// CoinRequestResponse - структура для ответа с запросом монет
+type CoinRequestResponse struct {
+	ID          uint       `json:"id"`
+	Requester   string     `json:"requester"`             // Кто запросил монеты
+	Payer       string     `json:"payer"`                 // У кого запрошены монеты
+	Amount      int        `json:"amount"`                // Запрошено монет
+	Note        string     `json:"note,omitempty"`        // Комментарий для плательщика
+	Status      string     `json:"status"`                // pending, accepted, declined или expired
+	ExpiresAt   time.Time  `json:"expiresAt"`             // Срок ответа
+	RespondedAt *time.Time `json:"respondedAt,omitempty"` // Время ответа плательщика
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// HoldResponse - структура для ответа с удержанием монет
+type HoldResponse struct {
+	ID         uint       `json:"id"`
+	Amount     int        `json:"amount"`               // Удержано монет
+	Note       string     `json:"note,omitempty"`       // Назначение удержания
+	Status     string     `json:"status"`               // active, released, returned или expired
+	ExpiresAt  time.Time  `json:"expiresAt"`            // Когда монеты вернутся владельцу
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"` // Когда монеты переданы или возвращены
+	Recipient  string     `json:"recipient,omitempty"`  // Кому переданы монеты
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// GrantResult - результат начисления или списания монет одному пользователю
+type GrantResult struct {
+	GrantID  uint   `json:"grantId,omitempty"` // Не заполняется при пробном запуске
+	Username string `json:"username"`          // Получатель
+	Amount   int    `json:"amount"`            // Начислено (или списано, если отрицательное)
+	Balance  int    `json:"balance"`           // Баланс после операции
+}
+
+// GrantBatchResponse - структура для ответа на массовое зачисление монет
+type GrantBatchResponse struct {
+	BatchID uint          `json:"batchId,omitempty"` // Не заполняется при пробном запуске
+	DryRun  bool          `json:"dryRun"`            // Пробный запуск: изменения не сохранены
+	Reason  string        `json:"reason"`            // Обоснование
+	Total   int           `json:"total"`             // Сумма всех строк
+	Grants  []GrantResult `json:"grants"`            // Результаты по получателям
+}
+
+// ScheduledTransferResponse - структура для ответа с запланированным переводом
+type ScheduledTransferResponse struct {
+	ID           uint       `json:"id"`
+	ToUser       string     `json:"toUser"`               // Получатель
+	Amount       int        `json:"amount"`               // Сумма каждого перевода
+	Message      string     `json:"message,omitempty"`    // Сообщение получателю
+	Category     string     `json:"category,omitempty"`   // Категория перевода
+	RunAt        *time.Time `json:"runAt,omitempty"`      // Время разового перевода
+	Cron         string     `json:"cron,omitempty"`       // Расписание повторяющегося перевода
+	Status       string     `json:"status"`               // active, completed, failed или cancelled
+	NextRunAt    *time.Time `json:"nextRunAt"`            // Следующий запуск
+	LastRunAt    *time.Time `json:"lastRunAt,omitempty"`  // Последний запуск
+	LastStatus   string     `json:"lastStatus,omitempty"` // Итог последнего запуска: succeeded или failed
+	LastError    string     `json:"lastError,omitempty"`  // Код ошибки последнего неудачного запуска
+	FailureCount int        `json:"failureCount"`         // Неудачных запусков подряд
+	RunCount     int        `json:"runCount"`             // Всего запусков
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// ScheduledRunResponse - структура для ответа с запуском запланированного перевода
+type ScheduledRunResponse struct {
+	ScheduledFor time.Time  `json:"scheduledFor"`           // Плановое время запуска
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`   // Время завершения
+	Status       string     `json:"status"`                 // pending, succeeded или failed
+	ErrorCode    string     `json:"errorCode,omitempty"`    // Код ошибки
+	ErrorMessage string     `json:"errorMessage,omitempty"` // Описание ошибки
+}
+
+// HealthResponse - структура для ответа проверок /healthz и /readyz
+type HealthResponse struct {
+	Status string            `json:"status"`           // ok или failed
+	Checks map[string]string `json:"checks,omitempty"` // Результат каждой проверки готовности: ok или failed
 }
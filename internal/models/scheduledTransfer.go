@@ -0,0 +1,136 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"merch-shop/internal/cron"
+	"time"
+)
+
+// Состояния запланированного перевода
+const (
+	ScheduleStatusActive    = "active"    // Ожидает следующего запуска
+	ScheduleStatusCompleted = "completed" // Разовый перевод выполнен
+	ScheduleStatusFailed    = "failed"    // Разовый перевод не выполнен
+	ScheduleStatusCancelled = "cancelled" // Отменён владельцем
+)
+
+// Состояния запуска запланированного перевода
+const (
+	RunStatusPending   = "pending"   // Запуск взят в работу
+	RunStatusSucceeded = "succeeded" // Перевод выполнен
+	RunStatusFailed    = "failed"    // Перевод не выполнен, причина в ErrorCode
+)
+
+// SchedulerSettings - параметры выполнения запланированных переводов
+type SchedulerSettings struct {
+	Interval         time.Duration // Как часто проверять наступившие переводы
+	BatchSize        int           // Сколько переводов брать в работу за одну проверку
+	Lease            time.Duration // Через сколько незавершённый запуск считается брошенным и выполняется повторно
+	MaxActivePerUser int           // Сколько действующих запланированных переводов может быть у пользователя
+}
+
+// ScheduledTransfer - разовый перевод на будущую дату (RunAt) или повторяющийся по расписанию cron (Cron, UTC)
+type ScheduledTransfer struct {
+	gorm.Model
+	SenderID   uint       `gorm:"not null;index"`
+	ReceiverID uint       `gorm:"not null"`
+	Amount     int        `gorm:"not null"`
+	Message    string     `gorm:"not null;default:''"`
+	Category   string     `gorm:"not null;default:''"`
+	RunAt      *time.Time // Время разового перевода
+	Cron       string     `gorm:"not null;default:''"` // Расписание повторяющегося перевода
+	Status     string     `gorm:"not null;index"`
+	NextRunAt  *time.Time `gorm:"index"` // Следующий запуск; nil — запусков больше не будет
+
+	LastRunAt    *time.Time // Время последнего запуска
+	LastStatus   string     `gorm:"not null;default:''"` // Итог последнего запуска
+	LastError    string     `gorm:"not null;default:''"` // Код ошибки последнего неудачного запуска
+	FailureCount int        `gorm:"not null;default:0"`  // Неудачных запусков подряд
+	RunCount     int        `gorm:"not null;default:0"`  // Всего запусков
+	ReceiverName string     `gorm:"->;-:migration"`      // Имя получателя; только для чтения
+}
+
+// Recurring - повторяющийся ли перевод
+func (s *ScheduledTransfer) Recurring() bool {
+	return s.Cron != ""
+}
+
+// Advance - переносит следующий запуск после выполненного в момент now. Разовый перевод больше не запускается;
+// для повторяющегося пропущенные за время простоя запуски не наверстываются: следующий — первый после now.
+func (s *ScheduledTransfer) Advance(now time.Time) error {
+	s.LastRunAt = &now
+	s.RunCount++
+	if !s.Recurring() {
+		s.NextRunAt = nil
+		return nil
+	}
+
+	schedule, err := cron.Parse(s.Cron)
+	if err != nil {
+		return err
+	}
+	next, err := schedule.Next(now)
+	if err != nil {
+		s.NextRunAt = nil
+		s.Status = ScheduleStatusCompleted
+		return nil
+	}
+	s.NextRunAt = &next
+	return nil
+}
+
+// ToResponse - представление запланированного перевода в ответе API
+func (s *ScheduledTransfer) ToResponse() ScheduledTransferResponse {
+	return ScheduledTransferResponse{
+		ID:           s.ID,
+		ToUser:       s.ReceiverName,
+		Amount:       s.Amount,
+		Message:      s.Message,
+		Category:     s.Category,
+		RunAt:        s.RunAt,
+		Cron:         s.Cron,
+		Status:       s.Status,
+		NextRunAt:    s.NextRunAt,
+		LastRunAt:    s.LastRunAt,
+		LastStatus:   s.LastStatus,
+		LastError:    s.LastError,
+		FailureCount: s.FailureCount,
+		RunCount:     s.RunCount,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+// ScheduledRun - запуск запланированного перевода. Перевод, выполненный запуском, ссылается на него
+// (Transaction.ScheduledRunID), поэтому повторное выполнение того же запуска не переведёт монеты дважды.
+type ScheduledRun struct {
+	ID           uint      `gorm:"primarykey"`
+	ScheduleID   uint      `gorm:"not null;uniqueIndex:idx_scheduled_run"`
+	ScheduledFor time.Time `gorm:"not null;uniqueIndex:idx_scheduled_run"` // Плановое время запуска
+	ClaimedAt    time.Time `gorm:"not null"`                               // Когда запуск взят в работу
+	FinishedAt   *time.Time
+	Status       string `gorm:"not null;index"`
+	ErrorCode    string `gorm:"not null;default:''"` // Код ошибки (errs.Error.Code) неудачного запуска
+	ErrorMessage string `gorm:"not null;default:''"`
+}
+
+// ToResponse - представление запуска в ответе API
+func (r *ScheduledRun) ToResponse() ScheduledRunResponse {
+	return ScheduledRunResponse{
+		ScheduledFor: r.ScheduledFor,
+		FinishedAt:   r.FinishedAt,
+		Status:       r.Status,
+		ErrorCode:    r.ErrorCode,
+		ErrorMessage: r.ErrorMessage,
+	}
+}
+
+// DueTransfer - запуск, который нужно выполнить, вместе с параметрами перевода
+type DueTransfer struct {
+	RunID            uint
+	ScheduleID       uint
+	SenderUsername   string
+	ReceiverUsername string
+	Amount           int
+	Message          string
+	Category         string
+}
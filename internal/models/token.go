@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RefreshToken - непрозрачный refresh-токен. В базе хранится только его хэш.
+// Токены, выпущенные ротацией из одного входа, образуют семейство: повторное использование
+// уже заменённого токена отзывает всё семейство.
+type RefreshToken struct {
+	ID           uint      `gorm:"primarykey"`
+	CreatedAt    time.Time `gorm:"not null"`
+	UserID       uint      `gorm:"not null;index"`
+	TokenHash    string    `gorm:"not null;uniqueIndex"`
+	FamilyID     string    `gorm:"not null;index"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	ReplacedByID *uint // Токен, выданный взамен при ротации
+}
@@ -2,10 +2,31 @@ package models
 
 import "gorm.io/gorm"
 
+// Категории переводов
+const (
+	TransferCategoryThanks      = "thanks"      // Благодарность
+	TransferCategoryHelp        = "help"        // За помощь
+	TransferCategoryBirthday    = "birthday"    // Поздравление с днём рождения
+	TransferCategoryAchievement = "achievement" // За достижение
+	TransferCategoryOther       = "other"       // Прочее
+)
+
+// TransferCategories - допустимые категории перевода
+var TransferCategories = map[string]bool{
+	TransferCategoryThanks:      true,
+	TransferCategoryHelp:        true,
+	TransferCategoryBirthday:    true,
+	TransferCategoryAchievement: true,
+	TransferCategoryOther:       true,
+}
+
 // Transaction - структура для хранения информации о покупке
 type Transaction struct {
 	gorm.Model
-	SenderId   uint `gorm:"not null" json:"senderId"`
-	ReceiverId uint `gorm:"not null" json:"receiverId"`
-	Amount     int  `gorm:"not null" json:"amount"`
+	SenderId       uint   `gorm:"not null;index" json:"senderId"`
+	ReceiverId     uint   `gorm:"not null;index" json:"receiverId"`
+	Amount         int    `gorm:"not null" json:"amount"`
+	Message        string `gorm:"not null;default:''" json:"message,omitempty"`        // Сообщение получателю
+	Category       string `gorm:"not null;default:'';index" json:"category,omitempty"` // Категория перевода
+	ScheduledRunID *uint  `gorm:"uniqueIndex" json:"-"`                                // Запуск запланированного перевода; уникален, чтобы запуск не выполнился дважды
 }
@@ -1,11 +1,50 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Роли пользователей
+const (
+	RoleUser    = "user"    // Обычный сотрудник
+	RoleAdmin   = "admin"   // Управление каталогом, балансами и пользователями
+	RoleAuditor = "auditor" // Только чтение отчётов
+)
 
 // User - структура пользователя
 type User struct {
 	gorm.Model
 	Username string `gorm:"unique;not null" json:"username"`
 	Password string `gorm:"not null" json:"-"`
-	Coins    int    `json:"coins"`
+	Coins    int    `json:"coins"` // Свободный баланс
+	Role     string `gorm:"not null;default:user" json:"role"`
+
+	// Удержанные монеты: не входят в Coins и недоступны для покупок и переводов до передачи или возврата
+	HeldCoins int `gorm:"not null;default:0" json:"heldCoins"`
+
+	// Версия токенов: увеличивается при выходе и отзыве, делая выпущенные access-токены недействительными
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+}
+
+// IsValidRole - проверяет, что роль известна
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
+
+// TokenClaims - данные пользователя, извлечённые из JWT-токена
+type TokenClaims struct {
+	Username string
+	Role     string
+}
+
+// AuthSettings - параметры входа и выдачи токенов
+type AuthSettings struct {
+	AccessTTL    time.Duration // Время жизни JWT access-токена
+	RefreshTTL   time.Duration // Время жизни refresh-токена
+	AutoRegister bool          // Создавать аккаунт при входе с неизвестным именем пользователя
 }
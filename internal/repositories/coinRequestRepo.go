@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"time"
+)
+
+// CreateCoinRequest - сохраняет запрос монет
+func (r *UserRepo) CreateCoinRequest(ctx context.Context, request *models.CoinRequest) error {
+	return conn(ctx, r.db).Create(request).Error
+}
+
+// GetCoinRequest - запрос монет с именами участников
+func (r *UserRepo) GetCoinRequest(ctx context.Context, id uint) (*models.CoinRequest, error) {
+	var request models.CoinRequest
+	if err := r.coinRequestsWithNames(ctx).First(&request, "coin_requests.id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ListCoinRequests - входящие или исходящие запросы монет пользователя, от новых к старым.
+// Истёкшие запросы хранятся в состоянии pending, поэтому фильтр по состоянию учитывает срок ответа.
+func (r *UserRepo) ListCoinRequests(ctx context.Context, userID uint, query models.CoinRequestQuery, now time.Time, limit int) ([]models.CoinRequest, error) {
+	q := r.coinRequestsWithNames(ctx)
+	if query.Direction == models.CoinRequestsOutgoing {
+		q = q.Where("coin_requests.requester_id = ?", userID)
+	} else {
+		q = q.Where("coin_requests.payer_id = ?", userID)
+	}
+
+	switch query.Status {
+	case models.CoinRequestPending:
+		q = q.Where("coin_requests.status = ? AND coin_requests.expires_at > ?", models.CoinRequestPending, now)
+	case models.CoinRequestExpired:
+		q = q.Where("coin_requests.status = ? AND coin_requests.expires_at <= ?", models.CoinRequestPending, now)
+	case models.CoinRequestAccepted, models.CoinRequestDeclined:
+		q = q.Where("coin_requests.status = ?", query.Status)
+	}
+
+	var requests []models.CoinRequest
+	err := q.Order("coin_requests.id DESC").Limit(limit).Find(&requests).Error
+	return requests, err
+}
+
+// coinRequestsWithNames - запрос запросов монет с именами запросившего и плательщика
+func (r *UserRepo) coinRequestsWithNames(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).Model(&models.CoinRequest{}).
+		Select("coin_requests.*, ru.username AS requester_name, pu.username AS payer_name").
+		Joins("LEFT JOIN users ru ON ru.id = coin_requests.requester_id").
+		Joins("LEFT JOIN users pu ON pu.id = coin_requests.payer_id")
+}
+
+// AcceptCoinRequest - оплачивает запрос: в одной транзакции переводит монеты от плательщика запросившему
+// и отмечает запрос принятым. Строка запроса блокируется, поэтому запрос нельзя оплатить дважды.
+func (r *UserRepo) AcceptCoinRequest(ctx context.Context, request *models.CoinRequest, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockUsers(tx, request.PayerID, request.RequesterID)
+		if err != nil {
+			return err
+		}
+		payer, requester := locked[request.PayerID], locked[request.RequesterID]
+
+		current, err := lockPendingCoinRequest(tx, request.ID, now)
+		if err != nil {
+			return err
+		}
+
+		transaction.Amount = current.Amount
+		if err = transferCoins(tx, payer, requester, transaction, limits); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": models.CoinRequestAccepted, "responded_at": now, "transaction_id": transaction.ID}
+		if err = tx.Model(current).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		request.Status, request.RespondedAt, request.TransactionID = models.CoinRequestAccepted, &now, &transaction.ID
+		return nil
+	})
+}
+
+// DeclineCoinRequest - отклоняет ожидающий ответа запрос монет
+func (r *UserRepo) DeclineCoinRequest(ctx context.Context, request *models.CoinRequest, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		current, err := lockPendingCoinRequest(tx, request.ID, now)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": models.CoinRequestDeclined, "responded_at": now}
+		if err = tx.Model(current).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		request.Status, request.RespondedAt = models.CoinRequestDeclined, &now
+		return nil
+	})
+}
+
+// lockPendingCoinRequest - читает запрос монет с блокировкой и проверяет, что он ещё ждёт ответа
+func lockPendingCoinRequest(tx *gorm.DB, id uint, now time.Time) (*models.CoinRequest, error) {
+	var request models.CoinRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, id).Error; err != nil {
+		return nil, err
+	}
+
+	switch request.EffectiveStatus(now) {
+	case models.CoinRequestPending:
+		return &request, nil
+	case models.CoinRequestExpired:
+		return nil, errs.ErrCoinRequestExpired
+	default:
+		return nil, errs.ErrCoinRequestNotPending
+	}
+}
@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// txContextKey - ключ контекста, под которым хранится транзакция, открытая для всего запроса
+type txContextKey struct{}
+
+// conn - подключение для запросов репозитория: транзакция из контекста, если запрос выполняется внутри неё, иначе пул.
+// Собственные транзакции репозиториев внутри такой транзакции становятся точками сохранения.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"merch-shop/internal/errs"
+)
+
+// Коды ошибок Postgres для нарушенных ограничений
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+)
+
+// constraintErrors - ошибки, которыми отвечают нарушения ограничений схемы (миграции 0001 и 0003).
+// Ограничения, которых нет в списке, остаются внутренними ошибками.
+var constraintErrors = map[string]*errs.Error{
+	"uni_users_username": errs.ErrUserAlreadyExists,
+	"uni_merches_name":   errs.ErrMerchAlreadyExists,
+
+	"chk_users_coins_non_negative":            errs.ErrNotEnoughCoins,
+	"chk_users_held_coins_non_negative":       errs.ErrNotEnoughCoins,
+	"chk_merches_price_positive":              errs.ErrInvalidPrice,
+	"chk_merches_stock_non_negative":          errs.ErrOutOfStock,
+	"chk_merches_per_user_limit_positive":     errs.ErrInvalidStock,
+	"chk_orders_total_non_negative":           errs.ErrInvalidOrder,
+	"chk_purchases_quantity_positive":         errs.ErrInvalidOrder,
+	"chk_purchases_unit_price_non_negative":   errs.ErrInvalidPrice,
+	"chk_transactions_amount_positive":        errs.ErrNegativeCoins,
+	"chk_transactions_distinct_users":         errs.ErrSendCoinsToYourself,
+	"chk_scheduled_transfers_amount_positive": errs.ErrNegativeCoins,
+	"chk_scheduled_transfers_distinct_users":  errs.ErrSendCoinsToYourself,
+	"chk_coin_requests_amount_positive":       errs.ErrNegativeCoins,
+	"chk_coin_requests_distinct_users":        errs.ErrSendCoinsToYourself,
+	"chk_holds_amount_positive":               errs.ErrNegativeCoins,
+	"chk_grants_amount_non_zero":              errs.ErrInvalidGrant,
+
+	"fk_orders_user":                  errs.ErrUserNotFound,
+	"fk_purchases_user":               errs.ErrUserNotFound,
+	"fk_purchases_merch":              errs.ErrMerchNotFound,
+	"fk_transactions_sender":          errs.ErrUserNotFound,
+	"fk_transactions_receiver":        errs.ErrUserNotFound,
+	"fk_grants_user":                  errs.ErrUserNotFound,
+	"fk_holds_user":                   errs.ErrUserNotFound,
+	"fk_holds_recipient":              errs.ErrUserNotFound,
+	"fk_ledger_entries_user":          errs.ErrUserNotFound,
+	"fk_refresh_tokens_user":          errs.ErrUserNotFound,
+	"fk_scheduled_transfers_sender":   errs.ErrUserNotFound,
+	"fk_scheduled_transfers_receiver": errs.ErrUserNotFound,
+	"fk_coin_requests_requester":      errs.ErrUserNotFound,
+	"fk_coin_requests_payer":          errs.ErrUserNotFound,
+}
+
+// TranslateConstraintError - заменяет нарушение известного ограничения схемы соответствующей ошибкой errs.
+// Остальные ошибки возвращаются без изменений.
+func TranslateConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgForeignKeyViolation, pgUniqueViolation, pgCheckViolation:
+	default:
+		return err
+	}
+	if appErr, ok := constraintErrors[pgErr.ConstraintName]; ok {
+		return appErr
+	}
+	return err
+}
+
+// ConstraintErrors - плагин gorm, который переводит нарушения ограничений схемы в ошибки errs
+// для всех запросов через подключение. Подключается через db.Use(repositories.ConstraintErrors{}).
+type ConstraintErrors struct{}
+
+func (ConstraintErrors) Name() string {
+	return "merch-shop:constraint_errors"
+}
+
+func (ConstraintErrors) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	name := "merch-shop:translate_constraint_error"
+	if err := callbacks.Create().After("*").Register(name, translateConstraintCallback); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("*").Register(name, translateConstraintCallback); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("*").Register(name, translateConstraintCallback); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("*").Register(name, translateConstraintCallback); err != nil {
+		return err
+	}
+	// Row выполняет Raw(...).Scan и Rows; Raw - Exec
+	if err := callbacks.Row().After("*").Register(name, translateConstraintCallback); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("*").Register(name, translateConstraintCallback)
+}
+
+// translateConstraintCallback - заменяет ошибку выполненного запроса, если она вызвана ограничением схемы
+func translateConstraintCallback(db *gorm.DB) {
+	if db.Error != nil {
+		db.Error = TranslateConstraintError(db.Error)
+	}
+}
@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"testing"
+)
+
+func TestTranslateConstraintError(t *testing.T) {
+	plainErr := errors.New("connection reset")
+	unknownConstraint := &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "chk_unknown"}
+	otherCode := &pgconn.PgError{Code: "40001", ConstraintName: "chk_users_coins_non_negative"}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "отрицательный баланс",
+			err:  &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "chk_users_coins_non_negative"},
+			want: errs.ErrNotEnoughCoins,
+		},
+		{
+			name: "перевод самому себе",
+			err:  &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "chk_transactions_distinct_users"},
+			want: errs.ErrSendCoinsToYourself,
+		},
+		{
+			name: "несуществующий получатель",
+			err:  &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "fk_transactions_receiver"},
+			want: errs.ErrUserNotFound,
+		},
+		{
+			name: "повторное имя пользователя",
+			err:  &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "uni_users_username"},
+			want: errs.ErrUserAlreadyExists,
+		},
+		{
+			name: "ошибка обёрнута",
+			err:  fmt.Errorf("create: %w", &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "chk_merches_price_positive"}),
+			want: errs.ErrInvalidPrice,
+		},
+		{
+			name: "неизвестное ограничение не переводится",
+			err:  unknownConstraint,
+			want: unknownConstraint,
+		},
+		{
+			name: "другой код ошибки не переводится",
+			err:  otherCode,
+			want: otherCode,
+		},
+		{
+			name: "ошибка не из Postgres не переводится",
+			err:  plainErr,
+			want: plainErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, TranslateConstraintError(tt.err))
+		})
+	}
+}
+
+// failingConnPool - подключение, которое отвечает на любой запрос заданной ошибкой
+type failingConnPool struct {
+	err error
+}
+
+func (p failingConnPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, p.err
+}
+
+func (p failingConnPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, p.err
+}
+
+func (p failingConnPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, p.err
+}
+
+func (p failingConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestConstraintErrorsPlugin(t *testing.T) {
+	violation := &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "chk_users_coins_non_negative"}
+
+	tests := []struct {
+		name string
+		run  func(db *gorm.DB) error
+	}{
+		{
+			name: "Create",
+			run: func(db *gorm.DB) error {
+				return db.Create(&models.User{Username: "user"}).Error
+			},
+		},
+		{
+			name: "Updates",
+			run: func(db *gorm.DB) error {
+				return db.Model(&models.User{}).Where("id = ?", 1).Update("coins", -1).Error
+			},
+		},
+		{
+			name: "Exec",
+			run: func(db *gorm.DB) error {
+				return db.Exec(`UPDATE users SET coins = coins - 1 WHERE id = ?`, 1).Error
+			},
+		},
+		{
+			name: "Raw и Scan",
+			run: func(db *gorm.DB) error {
+				var coins int
+				return db.Raw(`UPDATE users SET coins = coins - 1 WHERE id = ? RETURNING coins`, 1).Scan(&coins).Error
+			},
+		},
+		{
+			name: "Rows",
+			run: func(db *gorm.DB) error {
+				_, err := db.Raw(`UPDATE users SET coins = coins - 1 RETURNING coins`).Rows()
+				return err
+			},
+		},
+		{
+			name: "Find",
+			run: func(db *gorm.DB) error {
+				var users []models.User
+				return db.Find(&users).Error
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := gorm.Open(postgres.New(postgres.Config{Conn: failingConnPool{err: violation}}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+			require.NoError(t, err)
+			require.NoError(t, db.Use(ConstraintErrors{}))
+
+			assert.Equal(t, errs.ErrNotEnoughCoins, tt.run(db))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/models"
+)
+
+type EconomyRepository interface {
+	GetEconomySettings(ctx context.Context) (*models.EconomySettings, error)
+	InitEconomySettings(ctx context.Context, defaults *models.EconomySettings) error
+	SaveEconomySettings(ctx context.Context, settings *models.EconomySettings) error
+}
+
+// EconomyRepo - структура для работы с настройками экономики
+type EconomyRepo struct {
+	db *gorm.DB
+}
+
+func NewEconomyRepo(db *gorm.DB) *EconomyRepo {
+	return &EconomyRepo{db: db}
+}
+
+// GetEconomySettings - читает текущие настройки экономики
+func (r *EconomyRepo) GetEconomySettings(ctx context.Context) (*models.EconomySettings, error) {
+	var settings models.EconomySettings
+	if err := conn(ctx, r.db).First(&settings).Error; err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// InitEconomySettings - записывает настройки по умолчанию, если их ещё нет; уже сохранённые настройки не меняются
+func (r *EconomyRepo) InitEconomySettings(ctx context.Context, defaults *models.EconomySettings) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(defaults).Error
+}
+
+// SaveEconomySettings - сохраняет настройки экономики целиком
+func (r *EconomyRepo) SaveEconomySettings(ctx context.Context, settings *models.EconomySettings) error {
+	return conn(ctx, r.db).Save(settings).Error
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"merch-shop/internal/migrate"
+)
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	CheckSchema(ctx context.Context) error
+}
+
+// HealthRepo - проверки доступности базы и версии её схемы
+type HealthRepo struct {
+	db       *gorm.DB
+	migrator *migrate.Migrator
+}
+
+func NewHealthRepo(db *gorm.DB, migrator *migrate.Migrator) *HealthRepo {
+	return &HealthRepo{db: db, migrator: migrator}
+}
+
+// Ping - проверяет, что база отвечает
+func (r *HealthRepo) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckSchema - проверяет, что все миграции этой сборки применены
+func (r *HealthRepo) CheckSchema(ctx context.Context) error {
+	return r.migrator.CheckSchema(ctx)
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"time"
+)
+
+// CreateHold - удерживает hold.Amount монет пользователя: списывает их со свободного баланса
+// и переносит на счёт удержаний
+func (r *UserRepo) CreateHold(ctx context.Context, user *models.User, hold *models.Hold) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		owner, err := lockUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if err = debitCoins(tx, owner, hold.Amount); err != nil {
+			return err
+		}
+		if err = changeHeldCoins(tx, owner, hold.Amount); err != nil {
+			return err
+		}
+
+		hold.UserID = owner.ID
+		hold.Status = models.HoldActive
+		if err = tx.Create(hold).Error; err != nil {
+			return err
+		}
+
+		err = postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonHold, HoldID: &hold.ID},
+			models.UserLeg(owner.ID, -hold.Amount),
+			models.SystemLeg(models.AccountEscrow, hold.Amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		user.Coins, user.HeldCoins = owner.Coins, owner.HeldCoins
+		return nil
+	})
+}
+
+// GetHold - удержание с именем получателя
+func (r *UserRepo) GetHold(ctx context.Context, id uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := r.holdsWithRecipient(ctx).First(&hold, "holds.id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ListActiveHolds - действующие удержания пользователя в порядке истечения
+func (r *UserRepo) ListActiveHolds(ctx context.Context, userID uint) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.holdsWithRecipient(ctx).
+		Where("holds.user_id = ? AND holds.status = ?", userID, models.HoldActive).
+		Order("holds.expires_at, holds.id").
+		Find(&holds).Error
+	return holds, err
+}
+
+// holdsWithRecipient - запрос удержаний с именем получателя
+func (r *UserRepo) holdsWithRecipient(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).Model(&models.Hold{}).
+		Select("holds.*, u.username AS recipient_name").
+		Joins("LEFT JOIN users u ON u.id = holds.recipient_id")
+}
+
+// ReleaseHold - передаёт удержанные монеты получателю. Передача записывается как перевод от владельца
+// и проходит проверки лимитов limits.
+func (r *UserRepo) ReleaseHold(ctx context.Context, hold *models.Hold, recipient *models.User, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockUsers(tx, hold.UserID, recipient.ID)
+		if err != nil {
+			return err
+		}
+		owner, receiver := locked[hold.UserID], locked[recipient.ID]
+
+		current, err := lockActiveHold(tx, hold.ID)
+		if err != nil {
+			return err
+		}
+		amount := current.Amount
+
+		if err = checkTransferLimits(tx, owner, receiver, amount, limits); err != nil {
+			return err
+		}
+		if err = changeHeldCoins(tx, owner, -amount); err != nil {
+			return err
+		}
+		if err = creditCoins(tx, receiver, amount); err != nil {
+			return err
+		}
+
+		transaction.Amount = amount
+		transaction.SenderId = owner.ID
+		transaction.ReceiverId = receiver.ID
+		if err = tx.Create(transaction).Error; err != nil {
+			return err
+		}
+
+		err = postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonHoldRelease, TransactionID: &transaction.ID, HoldID: &current.ID},
+			models.SystemLeg(models.AccountEscrow, -amount),
+			models.UserLeg(receiver.ID, amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": models.HoldReleased, "resolved_at": now, "recipient_id": receiver.ID, "transaction_id": transaction.ID}
+		if err = tx.Model(current).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		hold.Status, hold.ResolvedAt, hold.RecipientID, hold.TransactionID = models.HoldReleased, &now, &receiver.ID, &transaction.ID
+		hold.RecipientName = receiver.Username
+		return nil
+	})
+}
+
+// ReturnHold - возвращает удержанные монеты владельцу; status - returned при отмене владельцем или expired по истечении срока
+func (r *UserRepo) ReturnHold(ctx context.Context, hold *models.Hold, status string, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		owner, err := lockUser(tx, hold.UserID)
+		if err != nil {
+			return err
+		}
+
+		current, err := lockActiveHold(tx, hold.ID)
+		if err != nil {
+			return err
+		}
+		amount := current.Amount
+
+		if err = changeHeldCoins(tx, owner, -amount); err != nil {
+			return err
+		}
+		if err = creditCoins(tx, owner, amount); err != nil {
+			return err
+		}
+
+		err = postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonHoldReturn, HoldID: &current.ID},
+			models.SystemLeg(models.AccountEscrow, -amount),
+			models.UserLeg(owner.ID, amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Model(current).Updates(map[string]interface{}{"status": status, "resolved_at": now}).Error; err != nil {
+			return err
+		}
+
+		hold.Status, hold.ResolvedAt = status, &now
+		return nil
+	})
+}
+
+// ListExpiredHolds - до limit действующих удержаний, срок которых истёк к моменту now
+func (r *UserRepo) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := conn(ctx, r.db).Where("status = ? AND expires_at <= ?", models.HoldActive, now).
+		Order("expires_at, id").
+		Limit(limit).
+		Find(&holds).Error
+	return holds, err
+}
+
+// lockActiveHold - читает удержание с блокировкой и проверяет, что монеты ещё удерживаются
+func lockActiveHold(tx *gorm.DB, id uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, id).Error; err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldActive {
+		return nil, errs.ErrHoldNotActive
+	}
+	return &hold, nil
+}
+
+// changeHeldCoins - изменяет сумму удержанных монет заблокированного пользователя на delta
+func changeHeldCoins(tx *gorm.DB, user *models.User, delta int) error {
+	err := tx.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("held_coins", gorm.Expr("held_coins + ?", delta)).Error
+	if err != nil {
+		return err
+	}
+	user.HeldCoins += delta
+	return nil
+}
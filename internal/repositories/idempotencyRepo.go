@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/models"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(ctx context.Context, username, key string) (*models.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, id uint) error
+	TakeOverIdempotencyKey(ctx context.Context, id uint, now time.Time) (bool, error)
+	LockIdempotencyKey(ctx context.Context, id uint) error
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// IdempotencyRepo - структура для работы с таблицей ключей идемпотентности
+type IdempotencyRepo struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepo(db *gorm.DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+// ReserveIdempotencyKey - занимает ключ; возвращает false, если ключ уже занят другим запросом
+func (r *IdempotencyRepo) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyKey) (bool, error) {
+	res := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// GetIdempotencyKey - ищет ключ пользователя
+func (r *IdempotencyRepo) GetIdempotencyKey(ctx context.Context, username, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	if err := conn(ctx, r.db).Where("username = ? AND key = ?", username, key).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyKey - сохраняет ответ на запрос
+func (r *IdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyKey) error {
+	return conn(ctx, r.db).Model(record).Select("StatusCode", "ContentType", "Response").Updates(record).Error
+}
+
+// DeleteIdempotencyKey - освобождает ключ
+func (r *IdempotencyRepo) DeleteIdempotencyKey(ctx context.Context, id uint) error {
+	return conn(ctx, r.db).Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// TakeOverIdempotencyKey - освобождает ключ запроса, который не сохранил ответ до конца аренды. Возвращает false,
+// если ответ уже сохранён или аренда не истекла. Удаление ждёт блокировки строки, которую держит транзакция
+// выполняющегося запроса, поэтому ключ такого запроса не освобождается.
+func (r *IdempotencyRepo) TakeOverIdempotencyKey(ctx context.Context, id uint, now time.Time) (bool, error) {
+	res := conn(ctx, r.db).Where("status_code = 0 AND locked_until < ?", now).Delete(&models.IdempotencyKey{}, id)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// LockIdempotencyKey - блокирует строку ключа до конца транзакции из ctx
+func (r *IdempotencyRepo) LockIdempotencyKey(ctx context.Context, id uint) error {
+	var record models.IdempotencyKey
+	return conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&record, id).Error
+}
+
+// Transaction - выполняет fn в транзакции. Репозитории, вызванные с контекстом, переданным в fn, работают в этой же транзакции.
+func (r *IdempotencyRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"merch-shop/internal/models"
+)
+
+type LedgerRepository interface {
+	GetBalanceDiscrepancies(ctx context.Context) ([]models.BalanceDiscrepancy, error)
+	GetLedgerImbalance(ctx context.Context) (int, error)
+	OpenMissingAccounts(ctx context.Context) (int64, error)
+}
+
+// LedgerRepo - структура для работы с журналом движения монет
+type LedgerRepo struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepo(db *gorm.DB) *LedgerRepo {
+	return &LedgerRepo{db: db}
+}
+
+// GetBalanceDiscrepancies - находит пользователей, чей баланс расходится с суммой проводок по их счёту
+func (r *LedgerRepo) GetBalanceDiscrepancies(ctx context.Context) ([]models.BalanceDiscrepancy, error) {
+	var discrepancies []models.BalanceDiscrepancy
+	err := conn(ctx, r.db).Raw(`
+		SELECT u.id AS user_id, u.username, u.coins AS cached_coins, COALESCE(SUM(l.delta), 0) AS ledger_coins
+		FROM users u
+		LEFT JOIN ledger_entries l ON l.user_id = u.id
+		WHERE u.deleted_at IS NULL
+		GROUP BY u.id, u.username, u.coins
+		HAVING u.coins <> COALESCE(SUM(l.delta), 0)
+		ORDER BY u.id
+	`).Scan(&discrepancies).Error
+
+	return discrepancies, err
+}
+
+// GetLedgerImbalance - сумма всех проводок журнала; для сбалансированного журнала она равна нулю
+func (r *LedgerRepo) GetLedgerImbalance(ctx context.Context) (int, error) {
+	var imbalance int
+	err := conn(ctx, r.db).Raw(`SELECT COALESCE(SUM(delta), 0) FROM ledger_entries`).Scan(&imbalance).Error
+	return imbalance, err
+}
+
+// OpenMissingAccounts - записывает начальные остатки пользователям, у которых ещё нет проводок
+// (созданным до появления журнала). Возвращает число открытых счетов.
+func (r *LedgerRepo) OpenMissingAccounts(ctx context.Context) (int64, error) {
+	var opened int64
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Запрещаем параллельное открытие счетов несколькими экземплярами сервера
+		if err := tx.Exec(`LOCK TABLE ledger_entries IN SHARE ROW EXCLUSIVE MODE`).Error; err != nil {
+			return err
+		}
+
+		return tx.Raw(`
+			WITH opened AS (
+				INSERT INTO ledger_entries (created_at, account, user_id, delta, reason)
+				SELECT NOW(), 'user:' || u.id, u.id, u.coins, ?
+				FROM users u
+				WHERE u.deleted_at IS NULL
+				  AND NOT EXISTS (SELECT 1 FROM ledger_entries l WHERE l.user_id = u.id)
+				RETURNING delta
+			), issued AS (
+				INSERT INTO ledger_entries (created_at, account, delta, reason)
+				SELECT NOW(), ?, -SUM(delta), ?
+				FROM opened
+				HAVING COUNT(*) > 0
+			)
+			SELECT COUNT(*) FROM opened
+		`, models.LedgerReasonOpeningBalance, models.AccountIssuance, models.LedgerReasonOpeningBalance).
+			Scan(&opened).Error
+	})
+
+	return opened, err
+}
+
+// postLedgerEntries - записывает проводки одной операции. Основание и ссылки берутся из template,
+// сумма проводок должна быть равна нулю.
+func postLedgerEntries(tx *gorm.DB, template models.LedgerEntry, legs ...models.LedgerEntry) error {
+	sum := 0
+	for i := range legs {
+		sum += legs[i].Delta
+		legs[i].Reason = template.Reason
+		legs[i].TransactionID = template.TransactionID
+		legs[i].PurchaseID = template.PurchaseID
+		legs[i].GrantID = template.GrantID
+		legs[i].HoldID = template.HoldID
+	}
+	if sum != 0 {
+		return fmt.Errorf("unbalanced ledger entries for %s: sum is %d", template.Reason, sum)
+	}
+
+	return tx.Create(&legs).Error
+}
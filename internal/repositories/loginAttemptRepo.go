@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"merch-shop/internal/models"
+	"time"
+)
+
+type LoginAttemptRepository interface {
+	GetLoginAttempts(ctx context.Context, keys []string) ([]models.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	BlockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+// LoginAttemptRepo - счётчики неудачных входов в Postgres, общие для всех экземпляров сервиса
+type LoginAttemptRepo struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepo(db *gorm.DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db}
+}
+
+// GetLoginAttempts - счётчики по ключам; ключи без неудачных попыток не возвращаются
+func (r *LoginAttemptRepo) GetLoginAttempts(ctx context.Context, keys []string) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := conn(ctx, r.db).Where("key IN ?", keys).Find(&attempts).Error
+	return attempts, err
+}
+
+// RecordLoginFailure - атомарно увеличивает счётчик и возвращает его новое значение.
+// Если последняя неудача была раньше now - window, счёт начинается заново.
+func (r *LoginAttemptRepo) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	var failures int
+	err := conn(ctx, r.db).Raw(`
+		INSERT INTO login_attempts (key, failures, updated_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.updated_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			updated_at = EXCLUDED.updated_at
+		RETURNING failures`,
+		key, now, now.Add(-window),
+	).Scan(&failures).Error
+	return failures, err
+}
+
+// BlockLogin - запрещает попытки входа по ключу до указанного момента
+func (r *LoginAttemptRepo) BlockLogin(ctx context.Context, key string, until time.Time) error {
+	return conn(ctx, r.db).Model(&models.LoginAttempt{}).Where("key = ?", key).Update("blocked_until", until).Error
+}
+
+// ResetLoginAttempts - сбрасывает счётчик и блокировку по ключу
+func (r *LoginAttemptRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	return conn(ctx, r.db).Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
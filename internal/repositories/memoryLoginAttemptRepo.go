@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"merch-shop/internal/models"
+	"sync"
+	"time"
+)
+
+// memorySweepThreshold - размер, после которого из памяти удаляются устаревшие счётчики
+const memorySweepThreshold = 10000
+
+// MemoryLoginAttemptRepo - счётчики неудачных входов в памяти процесса.
+// Подходит для одного экземпляра сервиса: при нескольких экземплярах используйте LoginAttemptRepo.
+type MemoryLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+	window   time.Duration // Максимальное окно, использованное при записи; нужно для очистки
+}
+
+func NewMemoryLoginAttemptRepo() *MemoryLoginAttemptRepo {
+	return &MemoryLoginAttemptRepo{attempts: make(map[string]*models.LoginAttempt)}
+}
+
+// GetLoginAttempts - счётчики по ключам; ключи без неудачных попыток не возвращаются
+func (r *MemoryLoginAttemptRepo) GetLoginAttempts(_ context.Context, keys []string) ([]models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var attempts []models.LoginAttempt
+	for _, key := range keys {
+		if attempt, ok := r.attempts[key]; ok {
+			attempts = append(attempts, *attempt)
+		}
+	}
+	return attempts, nil
+}
+
+// RecordLoginFailure - увеличивает счётчик и возвращает его новое значение.
+// Если последняя неудача была раньше now - window, счёт начинается заново.
+func (r *MemoryLoginAttemptRepo) RecordLoginFailure(_ context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if window > r.window {
+		r.window = window
+	}
+	if len(r.attempts) >= memorySweepThreshold {
+		r.sweep(now)
+	}
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &models.LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	if attempt.UpdatedAt.Before(now.Add(-window)) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.UpdatedAt = now
+	return attempt.Failures, nil
+}
+
+// BlockLogin - запрещает попытки входа по ключу до указанного момента
+func (r *MemoryLoginAttemptRepo) BlockLogin(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.BlockedUntil = until
+	}
+	return nil
+}
+
+// ResetLoginAttempts - сбрасывает счётчик и блокировку по ключу
+func (r *MemoryLoginAttemptRepo) ResetLoginAttempts(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+// sweep - удаляет счётчики, у которых истекли и окно, и блокировка
+func (r *MemoryLoginAttemptRepo) sweep(now time.Time) {
+	for key, attempt := range r.attempts {
+		if attempt.UpdatedAt.Before(now.Add(-r.window)) && attempt.BlockedUntil.Before(now) {
+			delete(r.attempts, key)
+		}
+	}
+}
@@ -1,12 +1,28 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"gorm.io/gorm"
+	"merch-shop/internal/errs"
 	"merch-shop/internal/models"
 )
 
 type MerchRepository interface {
-	GetMerchByName(name string) (*models.Merch, error)
+	GetMerchByName(ctx context.Context, name string) (*models.Merch, error)
+	ListMerch(ctx context.Context, limit, offset int, sort string) ([]models.Merch, int64, error)
+	CreateMerch(ctx context.Context, merch *models.Merch) error
+	UpdateMerch(ctx context.Context, merch *models.Merch) error
+	DeleteMerch(ctx context.Context, merch *models.Merch) error
+	RestockMerch(ctx context.Context, merch *models.Merch, quantity int) error
+	SetMerchStock(ctx context.Context, merch *models.Merch, stock *int) error
+}
+
+// merchOrders - допустимые варианты сортировки каталога
+var merchOrders = map[string]string{
+	models.MerchSortName:      "name",
+	models.MerchSortPriceAsc:  "price ASC, name",
+	models.MerchSortPriceDesc: "price DESC, name",
 }
 
 // MerchRepo - структура для работы с базой данных
@@ -18,10 +34,103 @@ func NewMerchRepo(db *gorm.DB) *MerchRepo {
 	return &MerchRepo{db: db}
 }
 
-func (r *MerchRepo) GetMerchByName(name string) (*models.Merch, error) {
+func (r *MerchRepo) GetMerchByName(ctx context.Context, name string) (*models.Merch, error) {
 	var merch models.Merch
-	if err := r.db.Where("name = ?", name).First(&merch).Error; err != nil {
+	if err := conn(ctx, r.db).Where("name = ?", name).First(&merch).Error; err != nil {
 		return nil, err
 	}
 	return &merch, nil
 }
+
+// ListMerch - возвращает страницу каталога (без снятых с продажи товаров) и общее число товаров
+func (r *MerchRepo) ListMerch(ctx context.Context, limit, offset int, sort string) ([]models.Merch, int64, error) {
+	order, ok := merchOrders[sort]
+	if !ok {
+		order = merchOrders[models.MerchSortName]
+	}
+
+	var total int64
+	if err := conn(ctx, r.db).Model(&models.Merch{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var merches []models.Merch
+	if err := conn(ctx, r.db).Order(order).Limit(limit).Offset(offset).Find(&merches).Error; err != nil {
+		return nil, 0, err
+	}
+	return merches, total, nil
+}
+
+// CreateMerch - добавляет товар в каталог. Снятый с продажи товар с тем же именем возвращается в продажу.
+func (r *MerchRepo) CreateMerch(ctx context.Context, merch *models.Merch) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var existing models.Merch
+		err := tx.Unscoped().Where("name = ?", merch.Name).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(merch).Error
+		}
+		if err != nil {
+			return err
+		}
+		if !existing.DeletedAt.Valid {
+			return errs.ErrMerchAlreadyExists
+		}
+
+		existing.DeletedAt = gorm.DeletedAt{}
+		existing.Price = merch.Price
+		existing.Stock = merch.Stock
+		existing.PerUserLimit = merch.PerUserLimit
+		if err = tx.Unscoped().Save(&existing).Error; err != nil {
+			return err
+		}
+		*merch = existing
+		return nil
+	})
+}
+
+// UpdateMerch - сохраняет изменённые имя, цену и лимит на пользователя; остаток меняется только через RestockMerch и SetMerchStock
+func (r *MerchRepo) UpdateMerch(ctx context.Context, merch *models.Merch) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Имя уникально в том числе среди снятых с продажи товаров
+		var count int64
+		err := tx.Unscoped().Model(&models.Merch{}).
+			Where("name = ? AND id <> ?", merch.Name, merch.ID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errs.ErrMerchAlreadyExists
+		}
+
+		return tx.Model(merch).Select("Name", "Price", "PerUserLimit").Updates(merch).Error
+	})
+}
+
+// DeleteMerch - снимает товар с продажи (мягкое удаление); купленные экземпляры остаются в инвентаре
+func (r *MerchRepo) DeleteMerch(ctx context.Context, merch *models.Merch) error {
+	return conn(ctx, r.db).Delete(merch).Error
+}
+
+// RestockMerch - атомарно пополняет склад товара с ограниченным остатком
+func (r *MerchRepo) RestockMerch(ctx context.Context, merch *models.Merch, quantity int) error {
+	res := conn(ctx, r.db).Model(&models.Merch{}).
+		Where("id = ? AND stock IS NOT NULL", merch.ID).
+		Update("stock", gorm.Expr("stock + ?", quantity))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errs.ErrInvalidStock
+	}
+	return conn(ctx, r.db).First(merch, merch.ID).Error
+}
+
+// SetMerchStock - устанавливает остаток товара; nil снимает ограничение
+func (r *MerchRepo) SetMerchStock(ctx context.Context, merch *models.Merch, stock *int) error {
+	if err := conn(ctx, r.db).Model(merch).Update("stock", stock).Error; err != nil {
+		return err
+	}
+	merch.Stock = stock
+	return nil
+}
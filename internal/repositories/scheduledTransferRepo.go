@@ -0,0 +1,229 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"time"
+)
+
+type ScheduledTransferRepository interface {
+	CountActiveScheduledTransfers(ctx context.Context, senderID uint) (int64, error)
+	CreateScheduledTransfer(ctx context.Context, transfer *models.ScheduledTransfer) error
+	GetScheduledTransfer(ctx context.Context, id uint) (*models.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, senderID uint) ([]models.ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, transfer *models.ScheduledTransfer) error
+	ListScheduledRuns(ctx context.Context, scheduleID uint, limit int) ([]models.ScheduledRun, error)
+	ClaimDueTransfers(ctx context.Context, now time.Time, limit int) ([]models.DueTransfer, error)
+	ReclaimStaleRuns(ctx context.Context, staleBefore, now time.Time, limit int) ([]models.DueTransfer, error)
+	RunHasTransaction(ctx context.Context, runID uint) (bool, error)
+	FinishScheduledRun(ctx context.Context, due models.DueTransfer, status, errorCode, errorMessage string, now time.Time) error
+}
+
+// ScheduledTransferRepo - структура для работы с запланированными переводами
+type ScheduledTransferRepo struct {
+	db *gorm.DB
+}
+
+func NewScheduledTransferRepo(db *gorm.DB) *ScheduledTransferRepo {
+	return &ScheduledTransferRepo{db: db}
+}
+
+// CountActiveScheduledTransfers - число действующих запланированных переводов пользователя
+func (r *ScheduledTransferRepo) CountActiveScheduledTransfers(ctx context.Context, senderID uint) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.ScheduledTransfer{}).
+		Where("sender_id = ? AND status = ?", senderID, models.ScheduleStatusActive).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *ScheduledTransferRepo) CreateScheduledTransfer(ctx context.Context, transfer *models.ScheduledTransfer) error {
+	return conn(ctx, r.db).Create(transfer).Error
+}
+
+// GetScheduledTransfer - запланированный перевод с именем получателя
+func (r *ScheduledTransferRepo) GetScheduledTransfer(ctx context.Context, id uint) (*models.ScheduledTransfer, error) {
+	var transfer models.ScheduledTransfer
+	if err := r.withReceiverName(ctx).First(&transfer, "scheduled_transfers.id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// ListScheduledTransfers - запланированные переводы пользователя, от новых к старым
+func (r *ScheduledTransferRepo) ListScheduledTransfers(ctx context.Context, senderID uint) ([]models.ScheduledTransfer, error) {
+	var transfers []models.ScheduledTransfer
+	err := r.withReceiverName(ctx).
+		Where("scheduled_transfers.sender_id = ?", senderID).
+		Order("scheduled_transfers.id DESC").
+		Find(&transfers).Error
+	return transfers, err
+}
+
+// withReceiverName - запрос запланированных переводов с именем получателя
+func (r *ScheduledTransferRepo) withReceiverName(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).Model(&models.ScheduledTransfer{}).
+		Select("scheduled_transfers.*, u.username AS receiver_name").
+		Joins("LEFT JOIN users u ON u.id = scheduled_transfers.receiver_id")
+}
+
+// CancelScheduledTransfer - отменяет перевод; уже взятый в работу запуск завершится
+func (r *ScheduledTransferRepo) CancelScheduledTransfer(ctx context.Context, transfer *models.ScheduledTransfer) error {
+	return conn(ctx, r.db).Model(transfer).
+		Where("status = ?", models.ScheduleStatusActive).
+		Updates(map[string]interface{}{"status": models.ScheduleStatusCancelled, "next_run_at": nil}).Error
+}
+
+// ListScheduledRuns - последние limit запусков перевода, от новых к старым
+func (r *ScheduledTransferRepo) ListScheduledRuns(ctx context.Context, scheduleID uint, limit int) ([]models.ScheduledRun, error) {
+	var runs []models.ScheduledRun
+	err := conn(ctx, r.db).Where("schedule_id = ?", scheduleID).Order("scheduled_for DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// ClaimDueTransfers - берёт в работу до limit переводов, время которых наступило. В одной транзакции
+// следующий запуск переносится вперёд и создаётся запись запуска. Строки блокируются с SKIP LOCKED,
+// поэтому несколько экземпляров сервера не возьмут один и тот же перевод.
+func (r *ScheduledTransferRepo) ClaimDueTransfers(ctx context.Context, now time.Time, limit int) ([]models.DueTransfer, error) {
+	var due []models.DueTransfer
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var transfers []models.ScheduledTransfer
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", models.ScheduleStatusActive, now).
+			Order("next_run_at").
+			Limit(limit).
+			Find(&transfers).Error
+		if err != nil {
+			return err
+		}
+
+		runIDs := make([]uint, 0, len(transfers))
+		for i := range transfers {
+			transfer := &transfers[i]
+			scheduledFor := *transfer.NextRunAt
+
+			invalidSchedule := transfer.Advance(now) != nil
+			if invalidSchedule {
+				// Расписание в базе не разбирается: останавливаем перевод, чтобы он не блокировал очередь
+				transfer.Status, transfer.NextRunAt, transfer.LastError = models.ScheduleStatusFailed, nil, errs.ErrInvalidSchedule.Code
+			}
+			err = tx.Model(transfer).
+				Select("Status", "NextRunAt", "LastRunAt", "RunCount", "LastError").
+				Updates(transfer).Error
+			if err != nil {
+				return err
+			}
+			if invalidSchedule {
+				continue
+			}
+
+			run := models.ScheduledRun{ScheduleID: transfer.ID, ScheduledFor: scheduledFor, ClaimedAt: now, Status: models.RunStatusPending}
+			if err = tx.Create(&run).Error; err != nil {
+				return err
+			}
+			runIDs = append(runIDs, run.ID)
+		}
+
+		due, err = loadDueTransfers(tx, runIDs)
+		return err
+	})
+	return due, err
+}
+
+// ReclaimStaleRuns - повторно берёт в работу запуски, которые остались незавершёнными дольше staleBefore
+// (например, экземпляр сервера остановился посреди выполнения)
+func (r *ScheduledTransferRepo) ReclaimStaleRuns(ctx context.Context, staleBefore, now time.Time, limit int) ([]models.DueTransfer, error) {
+	var due []models.DueTransfer
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var runs []models.ScheduledRun
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND claimed_at < ?", models.RunStatusPending, staleBefore).
+			Order("id").
+			Limit(limit).
+			Find(&runs).Error
+		if err != nil || len(runs) == 0 {
+			return err
+		}
+
+		runIDs := make([]uint, len(runs))
+		for i := range runs {
+			runIDs[i] = runs[i].ID
+		}
+		if err = tx.Model(&models.ScheduledRun{}).Where("id IN ?", runIDs).Update("claimed_at", now).Error; err != nil {
+			return err
+		}
+
+		due, err = loadDueTransfers(tx, runIDs)
+		return err
+	})
+	return due, err
+}
+
+// loadDueTransfers - параметры переводов для запусков runIDs. Имя удалённого пользователя остаётся пустым,
+// и перевод завершится ошибкой USER_NOT_FOUND.
+func loadDueTransfers(tx *gorm.DB, runIDs []uint) ([]models.DueTransfer, error) {
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+
+	var due []models.DueTransfer
+	err := tx.Raw(`
+		SELECT r.id AS run_id, s.id AS schedule_id,
+			COALESCE(su.username, '') AS sender_username, COALESCE(ru.username, '') AS receiver_username,
+			s.amount, s.message, s.category
+		FROM scheduled_runs r
+		JOIN scheduled_transfers s ON s.id = r.schedule_id
+		LEFT JOIN users su ON su.id = s.sender_id AND su.deleted_at IS NULL
+		LEFT JOIN users ru ON ru.id = s.receiver_id AND ru.deleted_at IS NULL
+		WHERE r.id IN ?
+		ORDER BY r.id
+	`, runIDs).Scan(&due).Error
+	return due, err
+}
+
+// RunHasTransaction - выполнен ли уже перевод для запуска
+func (r *ScheduledTransferRepo) RunHasTransaction(ctx context.Context, runID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.Transaction{}).Where("scheduled_run_id = ?", runID).Count(&count).Error
+	return count > 0, err
+}
+
+// FinishScheduledRun - записывает итог запуска и обновляет состояние перевода: разовый перевод завершается,
+// у повторяющегося считаются неудачные запуски подряд. Уже завершённый запуск не меняется.
+func (r *ScheduledTransferRepo) FinishScheduledRun(ctx context.Context, due models.DueTransfer, status, errorCode, errorMessage string, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.ScheduledRun{}).
+			Where("id = ? AND status = ?", due.RunID, models.RunStatusPending).
+			Updates(map[string]interface{}{
+				"status":        status,
+				"finished_at":   now,
+				"error_code":    errorCode,
+				"error_message": errorMessage,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		failures := gorm.Expr("failure_count + 1")
+		oneOffStatus := models.ScheduleStatusFailed
+		if status == models.RunStatusSucceeded {
+			failures = gorm.Expr("0")
+			oneOffStatus = models.ScheduleStatusCompleted
+		}
+
+		return tx.Model(&models.ScheduledTransfer{}).
+			Where("id = ?", due.ScheduleID).
+			Updates(map[string]interface{}{
+				"last_status":   status,
+				"last_error":    errorCode,
+				"failure_count": failures,
+				// Разовый перевод завершается; отменённый и повторяющийся сохраняют состояние
+				"status": gorm.Expr("CASE WHEN cron = '' AND status = ? THEN ? ELSE status END", models.ScheduleStatusActive, oneOffStatus),
+			}).Error
+	})
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"merch-shop/internal/models"
+	"time"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id uint, replacedByID *uint) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uint) error
+}
+
+// TokenRepo - структура для работы с refresh-токенами
+type TokenRepo struct {
+	db *gorm.DB
+}
+
+func NewTokenRepo(db *gorm.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+// CreateRefreshToken - сохраняет новый refresh-токен
+func (r *TokenRepo) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return conn(ctx, r.db).Create(token).Error
+}
+
+// GetRefreshTokenByHash - ищет refresh-токен по хэшу
+func (r *TokenRepo) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := conn(ctx, r.db).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeRefreshToken - отзывает токен, если он ещё не отозван; возвращает false, если токен уже был отозван
+func (r *TokenRepo) RevokeRefreshToken(ctx context.Context, id uint, replacedByID *uint) (bool, error) {
+	res := conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": replacedByID})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// RevokeRefreshTokenFamily - отзывает все токены семейства
+func (r *TokenRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserRefreshTokens - отзывает все токены пользователя
+func (r *TokenRepo) RevokeUserRefreshTokens(ctx context.Context, userID uint) error {
+	return conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,19 +1,48 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"merch-shop/internal/errs"
 	"merch-shop/internal/models"
+	"time"
 )
 
 type UserRepository interface {
-	GetUserByUsername(username string) (*models.User, error)
-	CreateUser(user *models.User) error
-	SendCoin(fromUser, toUser *models.User, amount int) error
-	BuyMerch(user *models.User, merch *models.Merch) error
-	GetUserInventory(userID uint) ([]models.Item, error)
-	GetCoinHistory(userID uint) (models.CoinHistory, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUserRole(ctx context.Context, username, role string) error
+	IncrementTokenVersion(ctx context.Context, userID uint) error
+	SendCoin(ctx context.Context, fromUser, toUser *models.User, transaction *models.Transaction, limits models.TransferLimits) error
+	BuyMerch(ctx context.Context, user *models.User, merch *models.Merch) error
+	CreateOrder(ctx context.Context, user *models.User, items []models.OrderItemRequest) (*models.Order, error)
+	GetPurchase(ctx context.Context, id uint) (*models.Purchase, error)
+	RefundPurchase(ctx context.Context, purchaseID uint) (*models.RefundResponse, error)
+	AdjustBalance(ctx context.Context, adminID uint, user *models.User, amount int, reason string, maxBalance *int) (*models.GrantResult, error)
+	ApplyGrants(ctx context.Context, adminID uint, reason string, lines []models.GrantLine, dryRun bool, maxBalance *int) (*models.GrantBatchResponse, error)
+	CreateCoinRequest(ctx context.Context, request *models.CoinRequest) error
+	GetCoinRequest(ctx context.Context, id uint) (*models.CoinRequest, error)
+	ListCoinRequests(ctx context.Context, userID uint, query models.CoinRequestQuery, now time.Time, limit int) ([]models.CoinRequest, error)
+	AcceptCoinRequest(ctx context.Context, request *models.CoinRequest, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error
+	DeclineCoinRequest(ctx context.Context, request *models.CoinRequest, now time.Time) error
+	CreateHold(ctx context.Context, user *models.User, hold *models.Hold) error
+	GetHold(ctx context.Context, id uint) (*models.Hold, error)
+	ListActiveHolds(ctx context.Context, userID uint) ([]models.Hold, error)
+	ReleaseHold(ctx context.Context, hold *models.Hold, recipient *models.User, transaction *models.Transaction, limits models.TransferLimits, now time.Time) error
+	ReturnHold(ctx context.Context, hold *models.Hold, status string, now time.Time) error
+	ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error)
+	GetUserInventory(ctx context.Context, userID uint) ([]models.Item, error)
+	GetCoinHistory(ctx context.Context, userID uint, limit int) (models.CoinHistory, error)
+	GetHistory(ctx context.Context, userID uint, query models.HistoryQuery, after *models.HistoryCursor) ([]models.HistoryEntry, error)
 }
 
+// errDryRun - откатывает транзакцию пробного запуска
+var errDryRun = errors.New("dry run")
+
 // UserRepo - структура для работы с базой данных
 type UserRepo struct {
 	db *gorm.DB
@@ -24,107 +53,676 @@ func NewUserRepo(db *gorm.DB) *UserRepo {
 }
 
 // GetUserByUsername - ищет пользователя по имени
-func (r *UserRepo) GetUserByUsername(username string) (*models.User, error) {
+func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := conn(ctx, r.db).Where("username = ?", username).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// CreateUser - создаёт нового пользователя
-func (r *UserRepo) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+// GetUserByID - ищет пользователя по id
+func (r *UserRepo) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := conn(ctx, r.db).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-// BuyMerch - списывает монеты и добавляет предмет в инвентарь
-func (r *UserRepo) BuyMerch(user *models.User, merch *models.Merch) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Списываем монеты
-		user.Coins -= merch.Price
-		if err := tx.Save(&user).Error; err != nil {
+// CreateUser - создаёт нового пользователя и проводит начисление его стартового баланса
+func (r *UserRepo) CreateUser(ctx context.Context, user *models.User) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
 			return err
 		}
 
-		// Добавляем предмет в инвентарь (запись в purchases)
-		purchase := models.Purchase{
-			UserID:  user.ID,
-			MerchID: merch.ID,
+		return postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonWelcomeBonus},
+			models.SystemLeg(models.AccountIssuance, -user.Coins),
+			models.UserLeg(user.ID, user.Coins),
+		)
+	})
+}
+
+// UpdateUserRole - меняет роль пользователя
+func (r *UserRepo) UpdateUserRole(ctx context.Context, username, role string) error {
+	res := conn(ctx, r.db).Model(&models.User{}).Where("username = ?", username).Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// IncrementTokenVersion - делает недействительными все выпущенные пользователю access-токены
+func (r *UserRepo) IncrementTokenVersion(ctx context.Context, userID uint) error {
+	return conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// BuyMerch - покупает один предмет: заказ из одной строки
+func (r *UserRepo) BuyMerch(ctx context.Context, user *models.User, merch *models.Merch) error {
+	_, err := r.CreateOrder(ctx, user, []models.OrderItemRequest{{Item: merch.Name, Quantity: 1}})
+	return err
+}
+
+// CreateOrder - оформляет заказ: цены читаются, остатки уменьшаются, сумма списывается и строки записываются в одной транзакции.
+// Если какой-то товар не найден или монет не хватает на весь заказ, не выполняется ни одна строка.
+func (r *UserRepo) CreateOrder(ctx context.Context, user *models.User, items []models.OrderItemRequest) (*models.Order, error) {
+	order := &models.Order{UserID: user.ID}
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Блокируем строку покупателя до конца транзакции
+		buyer, err := lockUser(tx, user.ID)
+		if err != nil {
+			return err
 		}
 
-		if err := tx.Create(&purchase).Error; err != nil {
+		// Товары без ограничения остатка читаем с разделяемой блокировкой: их цены не меняются, пока заказ
+		// не оформлен, а параллельные заказы тех же товаров не ждут друг друга.
+		// Товары с ограниченным остатком читаем без блокировки, остаток уменьшается условным UPDATE ниже.
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = item.Item
+		}
+		var merches []models.Merch
+		err = tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("name IN ? AND stock IS NULL", names).Order("id").Find(&merches).Error
+		if err != nil {
 			return err
 		}
+		var limited []models.Merch
+		if err = tx.Where("name IN ? AND stock IS NOT NULL", names).Order("id").Find(&limited).Error; err != nil {
+			return err
+		}
+
+		// Уменьшаем остатки в порядке id. Условие stock >= quantity не даёт продать больше, чем есть
+		// на складе, а блокировка строки, взятая UPDATE, держит цену товара до конца транзакции.
+		quantities := make(map[string]int, len(items))
+		for _, item := range items {
+			quantities[item.Item] = item.Quantity
+		}
+		for i := range limited {
+			if err = reserveStock(tx, &limited[i], quantities[limited[i].Name]); err != nil {
+				return err
+			}
+		}
+		merches = append(merches, limited...)
 
+		byName := make(map[string]*models.Merch, len(merches))
+		for i := range merches {
+			byName[merches[i].Name] = &merches[i]
+		}
+
+		purchases := make([]models.Purchase, len(items))
+		order.Lines = make([]models.OrderLine, len(items))
+		for i, item := range items {
+			merch, ok := byName[item.Item]
+			if !ok {
+				return errs.WithDetails(errs.ErrMerchNotFound, map[string]interface{}{"item": item.Item})
+			}
+			if err = checkPurchaseLimits(tx, buyer.ID, merch, item.Quantity); err != nil {
+				return err
+			}
+			purchases[i] = models.Purchase{UserID: buyer.ID, MerchID: merch.ID, Quantity: item.Quantity, UnitPrice: merch.Price}
+			order.Lines[i] = models.OrderLine{Item: merch.Name, Quantity: item.Quantity, UnitPrice: merch.Price, Amount: merch.Price * item.Quantity}
+			order.Total += order.Lines[i].Amount
+		}
+
+		// Списываем сумму всего заказа, только если монет хватает
+		if err = debitCoins(tx, buyer, order.Total); err != nil {
+			return err
+		}
+
+		if err = tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		// Добавляем предметы в инвентарь (записи в purchases) и отражаем каждую строку в журнале
+		for i := range purchases {
+			purchases[i].OrderID = &order.ID
+			if err = tx.Create(&purchases[i]).Error; err != nil {
+				return err
+			}
+			order.Lines[i].PurchaseID = purchases[i].ID
+
+			amount := order.Lines[i].Amount
+			err = postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonPurchase, PurchaseID: &purchases[i].ID},
+				models.UserLeg(buyer.ID, -amount),
+				models.SystemLeg(models.AccountShop, amount),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		user.Coins = buyer.Coins
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// reserveStock - уменьшает ограниченный остаток товара, если его хватает, и перечитывает цену под блокировкой строки
+func reserveStock(tx *gorm.DB, merch *models.Merch, quantity int) error {
+	res := tx.Model(merch).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "price"}, {Name: "stock"}}}).
+		Where("stock >= ?", quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	// Остатка не хватило: сообщаем, сколько осталось на складе сейчас
+	var available int
+	if err := tx.Model(&models.Merch{}).Where("id = ?", merch.ID).Select("COALESCE(stock, 0)").Scan(&available).Error; err != nil {
+		return err
+	}
+	return errs.WithDetails(errs.ErrOutOfStock, map[string]interface{}{"item": merch.Name, "available": available})
 }
 
-func (r *UserRepo) SendCoin(fromUser, toUser *models.User, amount int) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Списываем монеты у отправителя
-		fromUser.Coins -= amount
-		if err := tx.Save(fromUser).Error; err != nil {
+// checkPurchaseLimits - проверяет лимит покупок пользователя
+func checkPurchaseLimits(tx *gorm.DB, userID uint, merch *models.Merch, quantity int) error {
+	if merch.PerUserLimit == nil {
+		return nil
+	}
+
+	// Строка пользователя заблокирована, поэтому параллельный заказ того же пользователя не превысит лимит.
+	// Возвращённые покупки в лимит не засчитываются.
+	var bought int
+	err := tx.Model(&models.Purchase{}).
+		Where("user_id = ? AND merch_id = ? AND refunded_at IS NULL", userID, merch.ID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&bought).Error
+	if err != nil {
+		return err
+	}
+	if bought+quantity > *merch.PerUserLimit {
+		remaining := *merch.PerUserLimit - bought
+		if remaining < 0 {
+			remaining = 0
+		}
+		return errs.WithDetails(errs.ErrPurchaseLimit, map[string]interface{}{"item": merch.Name, "remaining": remaining})
+	}
+	return nil
+}
+
+// GetPurchase - получает покупку по id
+func (r *UserRepo) GetPurchase(ctx context.Context, id uint) (*models.Purchase, error) {
+	var purchase models.Purchase
+	if err := conn(ctx, r.db).First(&purchase, id).Error; err != nil {
+		return nil, err
+	}
+	return &purchase, nil
+}
+
+// RefundPurchase - возвращает покупку: монеты начисляются покупателю, остаток товара восстанавливается,
+// а покупка помечается возвращённой. Всё выполняется в одной транзакции; повторный возврат отклоняется.
+func (r *UserRepo) RefundPurchase(ctx context.Context, purchaseID uint) (*models.RefundResponse, error) {
+	var refund *models.RefundResponse
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var purchase models.Purchase
+		if err := tx.First(&purchase, purchaseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrPurchaseNotFound
+			}
+			return err
+		}
+
+		// Блокируем покупателя, затем товар и покупку — в том же порядке, что и при оформлении заказа
+		buyer, err := lockUser(tx, purchase.UserID)
+		if err != nil {
+			return err
+		}
+		// Товар мог быть снят с продажи, но вернуть купленный экземпляр всё равно можно
+		var merch models.Merch
+		err = tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&merch, purchase.MerchID).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&purchase, purchaseID).Error; err != nil {
+			return err
+		}
+		if purchase.Refunded() {
+			return errs.ErrAlreadyRefunded
+		}
+
+		// Возвращаем столько, сколько было списано: сумму берём из журнала, для покупок,
+		// сделанных до его появления, — из текущей цены товара
+		var amount int
+		err = tx.Raw(`
+			SELECT COALESCE(-SUM(le.delta), ?)
+			FROM ledger_entries le
+			WHERE le.purchase_id = ? AND le.user_id = ? AND le.reason = ?
+		`, merch.Price*purchase.Quantity, purchase.ID, purchase.UserID, models.LedgerReasonPurchase).
+			Scan(&amount).Error
+		if err != nil {
+			return err
+		}
+
+		if err = creditCoins(tx, buyer, amount); err != nil {
+			return err
+		}
+
+		// Возвращаем предметы на склад, если остаток товара ограничен
+		if merch.Stock != nil {
+			err = tx.Unscoped().Model(&models.Merch{}).
+				Where("id = ?", merch.ID).
+				Update("stock", gorm.Expr("stock + ?", purchase.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err = tx.Model(&purchase).Update("refunded_at", now).Error; err != nil {
 			return err
 		}
 
-		// Начисляем монеты получателю
-		toUser.Coins += amount
-		if err := tx.Save(toUser).Error; err != nil {
+		err = postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonRefund, PurchaseID: &purchase.ID},
+			models.SystemLeg(models.AccountShop, -amount),
+			models.UserLeg(buyer.ID, amount),
+		)
+		if err != nil {
 			return err
 		}
 
-		// Записываем транзакцию в историю
-		transaction := models.Transaction{
-			SenderId:   fromUser.ID,
-			ReceiverId: toUser.ID,
+		refund = &models.RefundResponse{
+			PurchaseID: purchase.ID,
+			Item:       merch.Name,
+			Quantity:   purchase.Quantity,
 			Amount:     amount,
+			Balance:    buyer.Coins,
+			RefundedAt: now,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// AdjustBalance - начисляет (amount > 0) или списывает (amount < 0) монеты пользователю от имени администратора.
+// Начисление не может поднять баланс выше maxBalance (nil - без ограничения).
+func (r *UserRepo) AdjustBalance(ctx context.Context, adminID uint, user *models.User, amount int, reason string, maxBalance *int) (*models.GrantResult, error) {
+	var result *models.GrantResult
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		grant := models.Grant{UserID: locked.ID, AdminID: adminID, Amount: amount, Reason: reason}
+		if err = applyGrant(tx, locked, &grant, maxBalance); err != nil {
+			return err
+		}
+
+		result = &models.GrantResult{GrantID: grant.ID, Username: locked.Username, Amount: amount, Balance: locked.Coins}
+		user.Coins = locked.Coins
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ApplyGrants - массовое зачисление: все строки применяются в одной транзакции, ошибка в любой строке отменяет весь пакет.
+// При пробном запуске результаты вычисляются так же, но транзакция откатывается.
+func (r *UserRepo) ApplyGrants(ctx context.Context, adminID uint, reason string, lines []models.GrantLine, dryRun bool, maxBalance *int) (*models.GrantBatchResponse, error) {
+	resp := &models.GrantBatchResponse{DryRun: dryRun, Reason: reason, Grants: make([]models.GrantResult, len(lines))}
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		usernames := make([]string, len(lines))
+		for i, line := range lines {
+			usernames[i] = line.Username
+			resp.Total += line.Amount
 		}
 
-		if err := tx.Create(&transaction).Error; err != nil {
+		var users []models.User
+		if err := tx.Select("id", "username").Where("username IN ?", usernames).Find(&users).Error; err != nil {
+			return err
+		}
+		ids := make(map[string]uint, len(users))
+		for _, u := range users {
+			ids[u.Username] = u.ID
+		}
+		userIDs := make([]uint, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line.Username]
+			if !ok {
+				return errs.WithDetails(errs.ErrUserNotFound, map[string]interface{}{"username": line.Username})
+			}
+			userIDs[i] = id
+		}
+
+		// Блокируем всех получателей в порядке id, как при переводах
+		locked, err := lockUsers(tx, userIDs...)
+		if err != nil {
 			return err
 		}
 
+		batch := models.GrantBatch{AdminID: adminID, Reason: reason, Total: resp.Total}
+		if err = tx.Create(&batch).Error; err != nil {
+			return err
+		}
+
+		for i, line := range lines {
+			user := locked[userIDs[i]]
+			grant := models.Grant{UserID: user.ID, AdminID: adminID, BatchID: &batch.ID, Amount: line.Amount, Reason: reason}
+			if err = applyGrant(tx, user, &grant, maxBalance); err != nil {
+				return err
+			}
+			resp.Grants[i] = models.GrantResult{GrantID: grant.ID, Username: user.Username, Amount: line.Amount, Balance: user.Coins}
+		}
+		resp.BatchID = batch.ID
+
+		if dryRun {
+			return errDryRun
+		}
 		return nil
 	})
+	if errors.Is(err, errDryRun) {
+		// Изменения откатаны, поэтому идентификаторы пакета и начислений не возвращаем
+		resp.BatchID = 0
+		for i := range resp.Grants {
+			resp.Grants[i].GrantID = 0
+		}
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
-// GetUserInventory - получает список предметов в инвентаре пользователя
-func (r *UserRepo) GetUserInventory(userID uint) ([]models.Item, error) {
+// applyGrant - меняет баланс заблокированного пользователя, записывает начисление и отражает его в журнале.
+// Начисление не может поднять баланс выше maxBalance, как и перевод, а списание не может увести баланс в минус.
+func applyGrant(tx *gorm.DB, user *models.User, grant *models.Grant, maxBalance *int) error {
+	if grant.Amount > 0 {
+		if maxBalance != nil && user.Coins+grant.Amount > *maxBalance {
+			return errs.WithDetails(errs.ErrBalanceLimit, map[string]interface{}{"username": user.Username, "maxBalance": *maxBalance})
+		}
+		if err := creditCoins(tx, user, grant.Amount); err != nil {
+			return err
+		}
+	} else if err := debitCoins(tx, user, -grant.Amount); err != nil {
+		if errors.Is(err, errs.ErrNotEnoughCoins) {
+			return errs.WithDetails(err, map[string]interface{}{"username": user.Username, "balance": user.Coins})
+		}
+		return err
+	}
+
+	if err := tx.Create(grant).Error; err != nil {
+		return err
+	}
+
+	return postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonGrant, GrantID: &grant.ID},
+		models.SystemLeg(models.AccountIssuance, -grant.Amount),
+		models.UserLeg(user.ID, grant.Amount),
+	)
+}
+
+// SendCoin - переводит монеты между пользователями и записывает перевод transaction (сумма, сообщение и категория)
+// с учётом ограничений limits. Строки обоих пользователей блокируются в порядке возрастания id,
+// чтобы встречные переводы не приводили к взаимоблокировке.
+func (r *UserRepo) SendCoin(ctx context.Context, fromUser, toUser *models.User, transaction *models.Transaction, limits models.TransferLimits) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockUsers(tx, fromUser.ID, toUser.ID)
+		if err != nil {
+			return err
+		}
+		sender, receiver := locked[fromUser.ID], locked[toUser.ID]
+
+		if err = transferCoins(tx, sender, receiver, transaction, limits); err != nil {
+			return err
+		}
+
+		fromUser.Coins = sender.Coins
+		toUser.Coins = receiver.Coins
+		return nil
+	})
+}
+
+// transferCoins - переводит монеты между заблокированными пользователями, записывает транзакцию и проводки
+func transferCoins(tx *gorm.DB, sender, receiver *models.User, transaction *models.Transaction, limits models.TransferLimits) error {
+	amount := transaction.Amount
+	if err := checkTransferLimits(tx, sender, receiver, amount, limits); err != nil {
+		return err
+	}
+
+	// Списываем монеты у отправителя
+	if err := debitCoins(tx, sender, amount); err != nil {
+		return err
+	}
+
+	// Начисляем монеты получателю
+	if err := creditCoins(tx, receiver, amount); err != nil {
+		return err
+	}
+
+	// Записываем транзакцию в историю
+	transaction.SenderId = sender.ID
+	transaction.ReceiverId = receiver.ID
+	if err := tx.Create(transaction).Error; err != nil {
+		return err
+	}
+
+	// Отражаем перевод в журнале
+	return postLedgerEntries(tx, models.LedgerEntry{Reason: models.LedgerReasonTransfer, TransactionID: &transaction.ID},
+		models.UserLeg(sender.ID, -amount),
+		models.UserLeg(receiver.ID, amount),
+	)
+}
+
+// checkTransferLimits - проверяет суточный лимит отправителя и максимальный баланс получателя.
+// Строки обоих пользователей заблокированы, поэтому параллельные переводы не обойдут ограничения.
+func checkTransferLimits(tx *gorm.DB, sender, receiver *models.User, amount int, limits models.TransferLimits) error {
+	if limits.DailyCap != nil {
+		// Сутки отсчитываются от полуночи UTC
+		dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+
+		var sent int
+		err := tx.Model(&models.Transaction{}).
+			Where("sender_id = ? AND created_at >= ?", sender.ID, dayStart).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&sent).Error
+		if err != nil {
+			return err
+		}
+		if sent+amount > *limits.DailyCap {
+			remaining := *limits.DailyCap - sent
+			if remaining < 0 {
+				remaining = 0
+			}
+			return errs.WithDetails(errs.ErrDailyTransferLimit, map[string]interface{}{"limit": *limits.DailyCap, "remaining": remaining})
+		}
+	}
+
+	if limits.MaxBalance != nil && receiver.Coins+amount > *limits.MaxBalance {
+		return errs.WithDetails(errs.ErrBalanceLimit, map[string]interface{}{"maxBalance": *limits.MaxBalance})
+	}
+	return nil
+}
+
+// lockUser - читает пользователя с блокировкой SELECT ... FOR UPDATE
+func lockUser(tx *gorm.DB, userID uint) (*models.User, error) {
+	locked, err := lockUsers(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return locked[userID], nil
+}
+
+// lockUsers - блокирует строки пользователей в порядке возрастания id и возвращает их по id
+func lockUsers(tx *gorm.DB, userIDs ...uint) (map[uint]*models.User, error) {
+	var users []models.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", userIDs).
+		Order("id").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	locked := make(map[uint]*models.User, len(users))
+	for i := range users {
+		locked[users[i].ID] = &users[i]
+	}
+	for _, id := range userIDs {
+		if _, ok := locked[id]; !ok {
+			return nil, errs.ErrUserNotFound
+		}
+	}
+	return locked, nil
+}
+
+// debitCoins - списывает монеты с заблокированного пользователя, не допуская отрицательного баланса
+func debitCoins(tx *gorm.DB, user *models.User, amount int) error {
+	res := tx.Model(&models.User{}).
+		Where("id = ? AND coins >= ?", user.ID, amount).
+		Update("coins", gorm.Expr("coins - ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errs.ErrNotEnoughCoins
+	}
+	user.Coins -= amount
+	return nil
+}
+
+// creditCoins - начисляет монеты заблокированному пользователю
+func creditCoins(tx *gorm.DB, user *models.User, amount int) error {
+	res := tx.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("coins", gorm.Expr("coins + ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errs.ErrUserNotFound
+	}
+	user.Coins += amount
+	return nil
+}
+
+// GetUserInventory - получает список предметов в инвентаре пользователя.
+// Снятые с продажи товары не отфильтровываются: купленные экземпляры остаются в инвентаре. Возвращённые покупки не учитываются.
+func (r *UserRepo) GetUserInventory(ctx context.Context, userID uint) ([]models.Item, error) {
 	var items []models.Item
-	err := r.db.Raw(`
-		SELECT m.name AS type, COUNT(p.merch_id) AS quantity
+	err := conn(ctx, r.db).Raw(`
+		SELECT m.name AS type, SUM(p.quantity) AS quantity
 		FROM purchases p
 		JOIN merches m ON p.merch_id = m.id
-		WHERE p.user_id = ?
+		WHERE p.user_id = ? AND p.refunded_at IS NULL
 		GROUP BY m.name
 	`, userID).Scan(&items).Error
 
 	return items, err
 }
 
-// GetCoinHistory - получает историю отправленных и полученных монет
-func (r *UserRepo) GetCoinHistory(userID uint) (models.CoinHistory, error) {
+// GetCoinHistory - получает последние limit отправленных и limit полученных переводов
+func (r *UserRepo) GetCoinHistory(ctx context.Context, userID uint, limit int) (models.CoinHistory, error) {
 	var history models.CoinHistory
 
 	// Получаем полученные монеты
-	err := r.db.Raw(`
-		SELECT u.username AS from_user, t.amount
+	err := conn(ctx, r.db).Raw(`
+		SELECT u.username AS from_user, t.amount, t.message, t.category, t.created_at
 		FROM transactions t
 		JOIN users u ON t.sender_id = u.id
-		WHERE t.receiver_id = ?
-	`, userID).Scan(&history.Received).Error
+		WHERE t.receiver_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT ?
+	`, userID, limit).Scan(&history.Received).Error
 	if err != nil {
 		return history, err
 	}
 
 	// Получаем отправленные монеты
-	err = r.db.Raw(`
-		SELECT u.username AS to_user, t.amount
+	err = conn(ctx, r.db).Raw(`
+		SELECT u.username AS to_user, t.amount, t.message, t.category, t.created_at
 		FROM transactions t
 		JOIN users u ON t.receiver_id = u.id
-		WHERE t.sender_id = ?
-	`, userID).Scan(&history.Sent).Error
-
+		WHERE t.sender_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT ?
+	`, userID, limit).Scan(&history.Sent).Error
 	return history, err
 }
+
+// historySQL - переводы, покупки (строки заказов), возвраты и начисления администраторов пользователя в едином виде. Сумма покупки берётся из журнала,
+// для покупок, сделанных до его появления, — из текущей цены товара. Возвращённая покупка остаётся в истории,
+// а возврат отображается отдельной записью с id покупки. Виды записей перечислены в models.HistoryTypes.
+const historySQL = `
+	SELECT 'sent' AS type, t.id, u.username AS counterparty, '' AS item, 0 AS quantity, t.amount, '' AS reason,
+		t.message, t.category, t.created_at
+	FROM transactions t
+	JOIN users u ON u.id = t.receiver_id
+	WHERE t.sender_id = @user AND t.deleted_at IS NULL
+	UNION ALL
+	SELECT 'received', t.id, u.username, '', 0, t.amount, '', t.message, t.category, t.created_at
+	FROM transactions t
+	JOIN users u ON u.id = t.sender_id
+	WHERE t.receiver_id = @user AND t.deleted_at IS NULL
+	UNION ALL
+	SELECT 'purchase', p.id, '', m.name, p.quantity, COALESCE(-le.delta, m.price * p.quantity), '', '', '', p.created_at
+	FROM purchases p
+	JOIN merches m ON m.id = p.merch_id
+	LEFT JOIN ledger_entries le ON le.purchase_id = p.id AND le.user_id = p.user_id AND le.reason = 'purchase'
+	WHERE p.user_id = @user AND p.deleted_at IS NULL
+	UNION ALL
+	SELECT 'refund', p.id, '', m.name, p.quantity, le.delta, '', '', '', p.refunded_at
+	FROM purchases p
+	JOIN merches m ON m.id = p.merch_id
+	JOIN ledger_entries le ON le.purchase_id = p.id AND le.user_id = p.user_id AND le.reason = 'refund'
+	WHERE p.user_id = @user AND p.deleted_at IS NULL AND p.refunded_at IS NOT NULL
+	UNION ALL
+	SELECT 'grant', g.id, '', '', 0, g.amount, g.reason, '', '', g.created_at
+	FROM grants g
+	WHERE g.user_id = @user AND g.deleted_at IS NULL`
+
+// GetHistory - страница истории операций пользователя от новых к старым, начиная после курсора after.
+// Возвращает до query.Limit+1 записей, чтобы вызывающий мог понять, есть ли следующая страница.
+func (r *UserRepo) GetHistory(ctx context.Context, userID uint, query models.HistoryQuery, after *models.HistoryCursor) ([]models.HistoryEntry, error) {
+	q := conn(ctx, r.db).Table("(?) AS history", conn(ctx, r.db).Raw(historySQL, sql.Named("user", userID)))
+
+	if query.Direction != "" {
+		q = q.Where("type = ?", models.HistoryDirections[query.Direction])
+	}
+	if query.Counterparty != "" {
+		q = q.Where("counterparty = ?", query.Counterparty)
+	}
+	if query.Category != "" {
+		q = q.Where("category = ?", query.Category)
+	}
+	if query.MinAmount != nil {
+		q = q.Where("amount >= ?", *query.MinAmount)
+	}
+	if query.MaxAmount != nil {
+		q = q.Where("amount <= ?", *query.MaxAmount)
+	}
+	if query.From != nil {
+		q = q.Where("created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		q = q.Where("created_at < ?", *query.To)
+	}
+	if after != nil {
+		q = q.Where("(created_at, type, id) < (?, ?, ?)", after.CreatedAt, after.Type, after.ID)
+	}
+
+	var entries []models.HistoryEntry
+	err := q.Order("created_at DESC, type DESC, id DESC").Limit(query.Limit + 1).Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"merch-shop/internal/errs"
+	"sync"
+	"unicode"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt игнорирует байты после 72-го
+)
+
+// ValidateUsername - проверяет имя пользователя: длина и допустимые символы
+func ValidateUsername(username string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return errs.ErrInvalidUsername
+	}
+	for _, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			return errs.ErrInvalidUsername
+		}
+	}
+	return nil
+}
+
+// ValidatePassword - проверяет стойкость пароля: длина, наличие букв и цифр
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return errs.ErrWeakPassword
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errs.ErrWeakPassword
+	}
+	return nil
+}
+
+// validateCredentials - проверки имени и пароля нового пользователя; общие для регистрации и автоматической регистрации при входе
+func validateCredentials(username, password string) error {
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+	return ValidatePassword(password)
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyPassword - сравнение пароля с заведомо чужим хэшем.
+// Выравнивает время ответа для неизвестного пользователя и неверного пароля.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"log"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"sync"
+	"time"
+)
+
+// EconomyService - настройки экономики магазина. Настройки хранятся в базе и кэшируются в памяти;
+// кэш перечитывается не реже чем раз в reloadInterval, чтобы изменения на другом экземпляре сервера доходили и сюда.
+type EconomyService struct {
+	repo           repositories.EconomyRepository
+	reloadInterval time.Duration
+	now            func() time.Time
+
+	mu       sync.RWMutex
+	settings models.EconomySettings
+	loadedAt time.Time
+}
+
+func NewEconomyService(repo repositories.EconomyRepository, reloadInterval time.Duration) *EconomyService {
+	return &EconomyService{repo: repo, reloadInterval: reloadInterval, now: time.Now, settings: models.DefaultEconomySettings()}
+}
+
+// Init - сохраняет настройки по умолчанию, если в базе их ещё нет, и загружает действующие настройки
+func (s *EconomyService) Init(ctx context.Context, defaults models.EconomySettings) error {
+	if err := ValidateEconomySettings(defaults); err != nil {
+		return err
+	}
+	if err := s.repo.InitEconomySettings(ctx, &defaults); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// Settings - действующие настройки экономики
+func (s *EconomyService) Settings(ctx context.Context) models.EconomySettings {
+	s.mu.RLock()
+	settings, stale := s.settings, s.reloadInterval > 0 && s.now().Sub(s.loadedAt) >= s.reloadInterval
+	s.mu.RUnlock()
+
+	if stale {
+		// При ошибке чтения продолжаем работать с последними загруженными настройками
+		if err := s.reload(ctx); err != nil {
+			log.Println("failed to reload economy settings: ", err)
+		} else {
+			s.mu.RLock()
+			settings = s.settings
+			s.mu.RUnlock()
+		}
+	}
+	return settings
+}
+
+// Update - заменяет настройки экономики; изменения действуют сразу
+func (s *EconomyService) Update(ctx context.Context, adminUsername string, settings models.EconomySettings) (*models.EconomySettings, error) {
+	if err := ValidateEconomySettings(settings); err != nil {
+		return nil, err
+	}
+
+	settings.ID = models.DefaultEconomySettings().ID
+	settings.UpdatedBy = adminUsername
+	if err := s.repo.SaveEconomySettings(ctx, &settings); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.settings, s.loadedAt = settings, s.now()
+	s.mu.Unlock()
+	return &settings, nil
+}
+
+// reload - перечитывает настройки из базы
+func (s *EconomyService) reload(ctx context.Context) error {
+	settings, err := s.repo.GetEconomySettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.settings, s.loadedAt = *settings, s.now()
+	s.mu.Unlock()
+	return nil
+}
+
+// ValidateEconomySettings - проверяет согласованность настроек: суммы неотрицательны,
+// минимальный перевод не больше максимального и суточного лимита, бонус не превышает максимальный баланс
+func ValidateEconomySettings(settings models.EconomySettings) error {
+	invalid := func(field string) error {
+		return errs.WithDetails(errs.ErrInvalidEconomy, map[string]interface{}{"field": field})
+	}
+
+	if settings.WelcomeBonus < 0 {
+		return invalid("welcomeBonus")
+	}
+	if settings.MinTransfer < 1 {
+		return invalid("minTransfer")
+	}
+	if settings.MaxTransfer != nil && *settings.MaxTransfer < settings.MinTransfer {
+		return invalid("maxTransfer")
+	}
+	if settings.DailyTransferCap != nil && *settings.DailyTransferCap < settings.MinTransfer {
+		return invalid("dailyTransferCap")
+	}
+	if settings.MaxBalance != nil && *settings.MaxBalance < settings.WelcomeBonus {
+		return invalid("maxBalance")
+	}
+	return nil
+}
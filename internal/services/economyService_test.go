@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"testing"
+	"time"
+)
+
+func TestUpdateEconomy(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name      string
+		settings  models.EconomySettings
+		mockSetup func(mockRepo *mocks.EconomyRepository)
+		wantErr   error
+	}{
+		{
+			name:     "новые настройки",
+			settings: models.EconomySettings{WelcomeBonus: 500, MinTransfer: 10, MaxTransfer: intPtr(100), DailyTransferCap: intPtr(300), MaxBalance: intPtr(5000)},
+			mockSetup: func(mockRepo *mocks.EconomyRepository) {
+				mockRepo.On("SaveEconomySettings", mock.Anything, mock.MatchedBy(func(settings *models.EconomySettings) bool {
+					return settings.ID == models.DefaultEconomySettings().ID && settings.UpdatedBy == "admin"
+				})).Return(nil)
+			},
+		},
+		{
+			name:      "отрицательный бонус",
+			settings:  models.EconomySettings{WelcomeBonus: -1, MinTransfer: 1},
+			mockSetup: func(mockRepo *mocks.EconomyRepository) {},
+			wantErr:   errs.ErrInvalidEconomy,
+		},
+		{
+			name:      "нулевой минимальный перевод",
+			settings:  models.EconomySettings{WelcomeBonus: 1000},
+			mockSetup: func(mockRepo *mocks.EconomyRepository) {},
+			wantErr:   errs.ErrInvalidEconomy,
+		},
+		{
+			name:      "максимальный перевод меньше минимального",
+			settings:  models.EconomySettings{WelcomeBonus: 1000, MinTransfer: 10, MaxTransfer: intPtr(5)},
+			mockSetup: func(mockRepo *mocks.EconomyRepository) {},
+			wantErr:   errs.ErrInvalidEconomy,
+		},
+		{
+			name:      "максимальный баланс меньше бонуса",
+			settings:  models.EconomySettings{WelcomeBonus: 1000, MinTransfer: 1, MaxBalance: intPtr(999)},
+			mockSetup: func(mockRepo *mocks.EconomyRepository) {},
+			wantErr:   errs.ErrInvalidEconomy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewEconomyRepository(t)
+			service := NewEconomyService(mockRepo, 0)
+
+			tt.mockSetup(mockRepo)
+
+			updated, err := service.Update(context.Background(), "admin", tt.settings)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, models.DefaultEconomySettings(), service.Settings(context.Background()))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, *updated, service.Settings(context.Background()))
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEconomySettingsReload(t *testing.T) {
+	mockRepo := mocks.NewEconomyRepository(t)
+	now := time.Now()
+	service := NewEconomyService(mockRepo, time.Minute)
+	service.now = func() time.Time { return now }
+
+	stored := models.DefaultEconomySettings()
+	mockRepo.On("InitEconomySettings", mock.Anything, mock.Anything).Return(nil).Once()
+	mockRepo.On("GetEconomySettings", mock.Anything).Return(&stored, nil).Once()
+	assert.NoError(t, service.Init(context.Background(), models.DefaultEconomySettings()))
+
+	// До истечения интервала настройки берутся из кэша
+	assert.Equal(t, 1000, service.Settings(context.Background()).WelcomeBonus)
+
+	// Изменение на другом экземпляре становится видно после интервала
+	changed := stored
+	changed.WelcomeBonus = 200
+	mockRepo.On("GetEconomySettings", mock.Anything).Return(&changed, nil).Once()
+	now = now.Add(time.Minute)
+	assert.Equal(t, 200, service.Settings(context.Background()).WelcomeBonus)
+
+	// Ошибка чтения не сбрасывает загруженные настройки
+	mockRepo.On("GetEconomySettings", mock.Anything).Return(nil, errors.New("connection refused")).Once()
+	now = now.Add(time.Minute)
+	assert.Equal(t, 200, service.Settings(context.Background()).WelcomeBonus)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"log"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"time"
+)
+
+// Результаты проверок готовности
+const (
+	HealthOK     = "ok"
+	HealthFailed = "failed"
+)
+
+// HealthService - проверки готовности экземпляра принимать запросы
+type HealthService struct {
+	repo    repositories.HealthRepository
+	timeout time.Duration // Сколько ждать ответа всех проверок
+}
+
+func NewHealthService(repo repositories.HealthRepository, timeout time.Duration) *HealthService {
+	return &HealthService{repo: repo, timeout: timeout}
+}
+
+// Readiness - проверяет соединение с базой и то, что схема на версии этой сборки.
+// Экземпляр готов, только если прошли все проверки. Подробности ошибок пишутся в журнал, а не в ответ.
+func (s *HealthService) Readiness(ctx context.Context) (*models.HealthResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	resp := &models.HealthResponse{Status: HealthOK, Checks: map[string]string{}}
+	for _, check := range []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"database", s.repo.Ping},
+		{"migrations", s.repo.CheckSchema},
+	} {
+		if err := check.run(ctx); err != nil {
+			log.Printf("readiness check %s failed: %v", check.name, err)
+			resp.Checks[check.name] = HealthFailed
+			resp.Status = HealthFailed
+			continue
+		}
+		resp.Checks[check.name] = HealthOK
+	}
+	return resp, resp.Status == HealthOK
+}
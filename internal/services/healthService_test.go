@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"testing"
+	"time"
+)
+
+func TestHealthServiceReadiness(t *testing.T) {
+	// Проверки получают контекст с ограничением времени
+	withDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+
+	tests := []struct {
+		name      string
+		pingErr   error
+		schemaErr error
+		wantReady bool
+		wantResp  *models.HealthResponse
+	}{
+		{
+			name:      "база доступна, миграции применены",
+			wantReady: true,
+			wantResp:  &models.HealthResponse{Status: HealthOK, Checks: map[string]string{"database": HealthOK, "migrations": HealthOK}},
+		},
+		{
+			name:      "база недоступна",
+			pingErr:   errors.New("connection refused"),
+			schemaErr: errors.New("connection refused"),
+			wantResp:  &models.HealthResponse{Status: HealthFailed, Checks: map[string]string{"database": HealthFailed, "migrations": HealthFailed}},
+		},
+		{
+			name:      "есть неприменённые миграции",
+			schemaErr: errors.New("1 pending migrations"),
+			wantResp:  &models.HealthResponse{Status: HealthFailed, Checks: map[string]string{"database": HealthOK, "migrations": HealthFailed}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := mocks.NewHealthRepository(t)
+			repo.On("Ping", withDeadline).Return(tt.pingErr)
+			repo.On("CheckSchema", withDeadline).Return(tt.schemaErr)
+			service := NewHealthService(repo, time.Second)
+
+			resp, ready := service.Readiness(context.Background())
+
+			assert.Equal(t, tt.wantReady, ready)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gorm.io/gorm"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"net/http"
+	"time"
+)
+
+// maxIdempotencyKeyLength - максимальная длина значения заголовка Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyService - сервис для повторного воспроизведения ответов по ключу идемпотентности
+type IdempotencyService struct {
+	repo  repositories.IdempotencyRepository
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyService - создаёт сервис; ttl задаёт окно, в течение которого повтор возвращает сохранённый ответ,
+// lease - сколько ключ остаётся занятым запросом, который так и не сохранил ответ
+func NewIdempotencyService(repo repositories.IdempotencyRepository, ttl, lease time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl, lease: lease}
+}
+
+// RequestFingerprint - отпечаток запроса, по которому повтор отличается от другого запроса с тем же ключом
+func RequestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin - занимает ключ для нового запроса или возвращает сохранённый ответ, если это повтор.
+// Новый запрос отличается от повтора по record.Completed().
+func (s *IdempotencyService) Begin(ctx context.Context, username, key, requestHash string) (*models.IdempotencyKey, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, errs.ErrInvalidIdempotencyKey
+	}
+
+	record := &models.IdempotencyKey{
+		Username:    username,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: time.Now().Add(s.lease),
+	}
+
+	// Вторая попытка нужна, если занятый ключ истёк или был освобождён между запросами
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := s.repo.ReserveIdempotencyKey(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return record, nil
+		}
+
+		existing, err := s.repo.GetIdempotencyKey(ctx, username, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Ключ за пределами окна считается свободным
+		if time.Since(existing.CreatedAt) > s.ttl {
+			if err = s.repo.DeleteIdempotencyKey(ctx, existing.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if existing.RequestHash != requestHash {
+			return nil, errs.ErrIdempotencyKeyMismatch
+		}
+		if existing.Completed() {
+			return existing, nil
+		}
+
+		// Запрос, занявший ключ, не сохранил ответ за время аренды (например, сервер остановился):
+		// повтор занимает ключ заново, а не ждёт окончания всего окна
+		if time.Now().Before(existing.LockedUntil) {
+			return nil, errs.ErrIdempotencyKeyInProgress
+		}
+		if _, err = s.repo.TakeOverIdempotencyKey(ctx, existing.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errs.ErrIdempotencyKeyInProgress
+}
+
+// errServerErrorResponse - обработчик ответил ошибкой сервера: изменения запроса откатываются, ответ не сохраняется
+var errServerErrorResponse = errors.New("server error response")
+
+// Execute - выполняет запрос, занявший ключ, в одной транзакции с сохранением ответа: изменения запроса и ответ
+// фиксируются вместе, поэтому повтор либо получает сохранённый ответ, либо выполняет запрос заново, но не дважды.
+// handle получает контекст транзакции и возвращает код, тип и тело ответа. При ответе с ошибкой сервера
+// изменения откатываются, и вызывающий освобождает ключ через Release, чтобы клиент мог повторить запрос.
+func (s *IdempotencyService) Execute(ctx context.Context, record *models.IdempotencyKey, handle func(ctx context.Context) (int, string, []byte)) error {
+	err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+		// Блокировка строки ключа держится до конца транзакции: повтор не займёт ключ, пока запрос выполняется,
+		// даже если аренда истекла
+		if err := s.repo.LockIdempotencyKey(ctx, record.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Аренда истекла раньше, чем началась транзакция, и ключ уже занял повтор
+				return errs.ErrIdempotencyKeyInProgress
+			}
+			return err
+		}
+
+		record.StatusCode, record.ContentType, record.Response = handle(ctx)
+		if record.StatusCode >= http.StatusInternalServerError {
+			return errServerErrorResponse
+		}
+		return s.repo.CompleteIdempotencyKey(ctx, record)
+	})
+	if errors.Is(err, errServerErrorResponse) {
+		return nil
+	}
+	return err
+}
+
+// Release - освобождает ключ, не сохраняя ответ
+func (s *IdempotencyService) Release(ctx context.Context, record *models.IdempotencyKey) error {
+	return s.repo.DeleteIdempotencyKey(ctx, record.ID)
+}
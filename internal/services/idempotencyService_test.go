@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyBegin(t *testing.T) {
+	const hash = "request-hash"
+
+	tests := []struct {
+		name          string
+		key           string
+		mockSetup     func(mockRepo *mocks.IdempotencyRepository)
+		wantCompleted bool
+		wantErr       error
+	}{
+		{
+			name: "новый ключ занимается",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantCompleted: false,
+			wantErr:       nil,
+		},
+		{
+			name: "повтор возвращает сохранённый ответ",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stored := &models.IdempotencyKey{ID: 1, CreatedAt: time.Now(), RequestHash: hash, StatusCode: 200}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil)
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stored, nil)
+			},
+			wantCompleted: true,
+			wantErr:       nil,
+		},
+		{
+			name: "ключ использован с другим телом",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stored := &models.IdempotencyKey{ID: 1, CreatedAt: time.Now(), RequestHash: "other", StatusCode: 200}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil)
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stored, nil)
+			},
+			wantErr: errs.ErrIdempotencyKeyMismatch,
+		},
+		{
+			name: "запрос с ключом ещё выполняется",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stored := &models.IdempotencyKey{ID: 1, CreatedAt: time.Now(), RequestHash: hash, LockedUntil: time.Now().Add(time.Minute)}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil)
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stored, nil)
+			},
+			wantErr: errs.ErrIdempotencyKeyInProgress,
+		},
+		{
+			name: "аренда прерванного запроса истекла",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stored := &models.IdempotencyKey{ID: 5, CreatedAt: time.Now().Add(-3 * time.Minute), RequestHash: hash, LockedUntil: time.Now().Add(-time.Minute)}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil).Once()
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stored, nil)
+				mockRepo.On("TakeOverIdempotencyKey", mock.Anything, uint(5), mock.Anything).Return(true, nil)
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(true, nil).Once()
+			},
+			wantCompleted: false,
+			wantErr:       nil,
+		},
+		{
+			name: "ответ сохранён, пока повтор ждал освобождения ключа",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stale := &models.IdempotencyKey{ID: 5, CreatedAt: time.Now().Add(-3 * time.Minute), RequestHash: hash, LockedUntil: time.Now().Add(-time.Minute)}
+				completed := &models.IdempotencyKey{ID: 5, CreatedAt: stale.CreatedAt, RequestHash: hash, StatusCode: 200}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil)
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stale, nil).Once()
+				mockRepo.On("TakeOverIdempotencyKey", mock.Anything, uint(5), mock.Anything).Return(false, nil)
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(completed, nil).Once()
+			},
+			wantCompleted: true,
+			wantErr:       nil,
+		},
+		{
+			name: "истёкший ключ занимается заново",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				stored := &models.IdempotencyKey{ID: 7, CreatedAt: time.Now().Add(-48 * time.Hour), RequestHash: "other", StatusCode: 200}
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil).Once()
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(stored, nil)
+				mockRepo.On("DeleteIdempotencyKey", mock.Anything, uint(7)).Return(nil)
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(true, nil).Once()
+			},
+			wantCompleted: false,
+			wantErr:       nil,
+		},
+		{
+			name: "ключ освобождён между запросами",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, nil).Once()
+				mockRepo.On("GetIdempotencyKey", mock.Anything, "Andrey", "key-1").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(true, nil).Once()
+			},
+			wantCompleted: false,
+			wantErr:       nil,
+		},
+		{
+			name:      "пустой ключ",
+			key:       "",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {},
+			wantErr:   errs.ErrInvalidIdempotencyKey,
+		},
+		{
+			name: "ошибка в репозитории",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, errs.ErrInternalServer)
+			},
+			wantErr: errs.ErrInternalServer,
+		},
+		{
+			name: "истёк срок запроса: ошибка контекста не подменяется",
+			key:  "key-1",
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(false, context.DeadlineExceeded)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewIdempotencyRepository(t)
+			service := NewIdempotencyService(mockRepo, 24*time.Hour, 2*time.Minute)
+
+			tt.mockSetup(mockRepo)
+
+			record, err := service.Begin(context.Background(), "Andrey", tt.key, hash)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantCompleted, record.Completed())
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdempotencyExecute(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		mockSetup   func(mockRepo *mocks.IdempotencyRepository)
+		wantHandled bool
+		wantErr     error
+	}{
+		{
+			name:       "ответ сохраняется в транзакции запроса",
+			statusCode: 200,
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("LockIdempotencyKey", mock.Anything, uint(3)).Return(nil)
+				mockRepo.On("CompleteIdempotencyKey", mock.Anything, mock.MatchedBy(func(record *models.IdempotencyKey) bool {
+					return record.StatusCode == 200 && string(record.Response) == "ok"
+				})).Return(nil)
+			},
+			wantHandled: true,
+		},
+		{
+			name:       "ошибка клиента сохраняется",
+			statusCode: 400,
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("LockIdempotencyKey", mock.Anything, uint(3)).Return(nil)
+				mockRepo.On("CompleteIdempotencyKey", mock.Anything, mock.Anything).Return(nil)
+			},
+			wantHandled: true,
+		},
+		{
+			name:       "ошибка сервера откатывает транзакцию без сохранения ответа",
+			statusCode: 500,
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("LockIdempotencyKey", mock.Anything, uint(3)).Return(nil)
+			},
+			wantHandled: true,
+		},
+		{
+			name:       "ключ уже занял повтор после окончания аренды",
+			statusCode: 200,
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("LockIdempotencyKey", mock.Anything, uint(3)).Return(gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrIdempotencyKeyInProgress,
+		},
+		{
+			name:       "ответ не сохранился",
+			statusCode: 200,
+			mockSetup: func(mockRepo *mocks.IdempotencyRepository) {
+				mockRepo.On("LockIdempotencyKey", mock.Anything, uint(3)).Return(nil)
+				mockRepo.On("CompleteIdempotencyKey", mock.Anything, mock.Anything).Return(errs.ErrInternalServer)
+			},
+			wantHandled: true,
+			wantErr:     errs.ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewIdempotencyRepository(t)
+			service := NewIdempotencyService(mockRepo, 24*time.Hour, 2*time.Minute)
+
+			// Транзакция откатывается, если функция вернула ошибку; здесь достаточно вызвать её и вернуть результат
+			var rolledBack bool
+			mockRepo.On("Transaction", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+				err := fn(ctx)
+				rolledBack = err != nil
+				return err
+			})
+			tt.mockSetup(mockRepo)
+
+			handled := false
+			err := service.Execute(context.Background(), &models.IdempotencyKey{ID: 3}, func(ctx context.Context) (int, string, []byte) {
+				handled = true
+				return tt.statusCode, "text/plain", []byte("ok")
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantHandled, handled)
+			assert.Equal(t, tt.wantErr != nil || tt.statusCode >= 500, rolledBack)
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"merch-shop/internal/models"
+	"sort"
+)
+
+// Поддерживаемые алгоритмы подписи токенов
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// minJWTSecretLength - минимальная длина HMAC-секрета в байтах
+const minJWTSecretLength = 16
+
+// JWTConfig - настройки подписи токенов
+type JWTConfig struct {
+	Algorithm     string // HS256, RS256 или EdDSA
+	KeyID         string // Идентификатор ключа (kid); по умолчанию вычисляется из ключа
+	Secret        []byte // HMAC-секрет для HS256
+	PrivateKeyPEM []byte // Закрытый ключ для RS256 и EdDSA
+
+	// Ключи, которые ещё принимаются при проверке в период ротации, по kid
+	PreviousSecrets       map[string][]byte
+	PreviousPublicKeysPEM map[string][]byte
+}
+
+// verificationKey - ключ проверки подписи с алгоритмом, которым он может использоваться
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// JWTKeys - ключ подписи и набор ключей проверки JWT-токенов
+type JWTKeys struct {
+	method     jwt.SigningMethod
+	signingKID string
+	signingKey interface{}
+	verifyKeys map[string]verificationKey
+}
+
+// NewJWTKeys - загружает ключи из настроек
+func NewJWTKeys(cfg JWTConfig) (*JWTKeys, error) {
+	keys := &JWTKeys{verifyKeys: make(map[string]verificationKey)}
+
+	var verifyKey interface{}
+	var kidSource []byte
+	switch cfg.Algorithm {
+	case JWTAlgorithmHS256, "":
+		if len(cfg.Secret) < minJWTSecretLength {
+			return nil, fmt.Errorf("JWT secret must be at least %d bytes", minJWTSecretLength)
+		}
+		keys.method = jwt.SigningMethodHS256
+		keys.signingKey = cfg.Secret
+		verifyKey = cfg.Secret
+		kidSource = cfg.Secret
+	case JWTAlgorithmRS256:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(cfg.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		keys.method = jwt.SigningMethodRS256
+		keys.signingKey = privateKey
+		verifyKey = &privateKey.PublicKey
+		kidSource = x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)
+	case JWTAlgorithmEdDSA:
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(cfg.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Ed25519 private key: %w", err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		publicKey := privateKey.Public().(ed25519.PublicKey)
+		keys.method = jwt.SigningMethodEdDSA
+		keys.signingKey = privateKey
+		verifyKey = publicKey
+		kidSource = publicKey
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	keys.signingKID = cfg.KeyID
+	if keys.signingKID == "" {
+		keys.signingKID = keyID(kidSource)
+	}
+	keys.verifyKeys[keys.signingKID] = verificationKey{method: keys.method, key: verifyKey}
+
+	for kid, secret := range cfg.PreviousSecrets {
+		if len(secret) < minJWTSecretLength {
+			return nil, fmt.Errorf("previous JWT secret %q must be at least %d bytes", kid, minJWTSecretLength)
+		}
+		if err := keys.addVerifyKey(kid, verificationKey{method: jwt.SigningMethodHS256, key: secret}); err != nil {
+			return nil, err
+		}
+	}
+	for kid, pemBytes := range cfg.PreviousPublicKeysPEM {
+		key, err := parsePublicKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous public key %q: %w", kid, err)
+		}
+		if err = keys.addVerifyKey(kid, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// addVerifyKey - добавляет ключ проверки, не допуская повторного kid
+func (k *JWTKeys) addVerifyKey(kid string, key verificationKey) error {
+	if _, exists := k.verifyKeys[kid]; exists {
+		return fmt.Errorf("duplicate JWT key id %q", kid)
+	}
+	k.verifyKeys[kid] = key
+	return nil
+}
+
+// Sign - подписывает токен текущим ключом и указывает его kid в заголовке
+func (k *JWTKeys) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.signingKID
+	return token.SignedString(k.signingKey)
+}
+
+// Parse - проверяет подпись токена ключом, указанным в его заголовке kid.
+// Токены без kid, выпущенные до появления ротации, проверяются текущим ключом.
+func (k *JWTKeys) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = k.signingKID
+		}
+
+		key, ok := k.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.key, nil
+	})
+}
+
+// JWKS - открытые ключи проверки для асимметричных алгоритмов; HMAC-секреты не публикуются
+func (k *JWTKeys) JWKS() models.JWKS {
+	kids := make([]string, 0, len(k.verifyKeys))
+	for kid := range k.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := models.JWKS{Keys: []models.JWK{}}
+	for _, kid := range kids {
+		key := k.verifyKeys[kid]
+		switch publicKey := key.key.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+	return jwks
+}
+
+// parsePublicKey - разбирает открытый ключ RSA или Ed25519 в формате PEM
+func parsePublicKey(pemBytes []byte) (verificationKey, error) {
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return verificationKey{method: jwt.SigningMethodRS256, key: rsaKey}, nil
+	}
+	edKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return verificationKey{}, errors.New("expected RSA or Ed25519 public key in PEM format")
+	}
+	publicKey, ok := edKey.(ed25519.PublicKey)
+	if !ok {
+		return verificationKey{}, errors.New("expected RSA or Ed25519 public key in PEM format")
+	}
+	return verificationKey{method: jwt.SigningMethodEdDSA, key: publicKey}, nil
+}
+
+// keyID - идентификатор ключа по умолчанию: начало SHA-256 от материала ключа
+func keyID(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
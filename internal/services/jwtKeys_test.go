@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newTestJWTKeys - HMAC-ключи для тестов сервисов
+func newTestJWTKeys(t *testing.T) *JWTKeys {
+	keys, err := NewJWTKeys(JWTConfig{Algorithm: JWTAlgorithmHS256, Secret: []byte("test-secret-0123456789")})
+	require.NoError(t, err)
+	return keys
+}
+
+// encodePEM - кодирует ключ в PEM
+func encodePEM(t *testing.T, blockType string, der []byte, err error) []byte {
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestJWTKeysSignAndParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	rsaPEM := encodePEM(t, "PRIVATE KEY", rsaDER, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	edPEM := encodePEM(t, "PRIVATE KEY", edDER, err)
+
+	tests := []struct {
+		name     string
+		cfg      JWTConfig
+		wantJWKS int
+		wantKty  string
+	}{
+		{
+			name:     "HS256",
+			cfg:      JWTConfig{Algorithm: JWTAlgorithmHS256, Secret: []byte("test-secret-0123456789")},
+			wantJWKS: 0,
+		},
+		{
+			name:     "RS256",
+			cfg:      JWTConfig{Algorithm: JWTAlgorithmRS256, PrivateKeyPEM: rsaPEM},
+			wantJWKS: 1,
+			wantKty:  "RSA",
+		},
+		{
+			name:     "EdDSA",
+			cfg:      JWTConfig{Algorithm: JWTAlgorithmEdDSA, KeyID: "ed-1", PrivateKeyPEM: edPEM},
+			wantJWKS: 1,
+			wantKty:  "OKP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := NewJWTKeys(tt.cfg)
+			require.NoError(t, err)
+
+			tokenString, err := keys.Sign(jwt.MapClaims{"username": "Andrey", "exp": time.Now().Add(time.Hour).Unix()})
+			require.NoError(t, err)
+
+			token, err := keys.Parse(tokenString)
+			require.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, keys.signingKID, token.Header["kid"])
+
+			jwks := keys.JWKS()
+			assert.Len(t, jwks.Keys, tt.wantJWKS)
+			if tt.wantJWKS > 0 {
+				assert.Equal(t, tt.wantKty, jwks.Keys[0].Kty)
+				assert.Equal(t, keys.signingKID, jwks.Keys[0].Kid)
+			}
+		})
+	}
+}
+
+func TestJWTKeysRotation(t *testing.T) {
+	oldKeys, err := NewJWTKeys(JWTConfig{KeyID: "old", Secret: []byte("old-secret-0123456789")})
+	require.NoError(t, err)
+	oldToken, err := oldKeys.Sign(jwt.MapClaims{"username": "Andrey"})
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	rsaPEM := encodePEM(t, "PRIVATE KEY", rsaDER, err)
+	oldRSAKeys, err := NewJWTKeys(JWTConfig{Algorithm: JWTAlgorithmRS256, KeyID: "old-rsa", PrivateKeyPEM: rsaPEM})
+	require.NoError(t, err)
+	oldRSAToken, err := oldRSAKeys.Sign(jwt.MapClaims{"username": "Andrey"})
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	publicPEM := encodePEM(t, "PUBLIC KEY", publicDER, err)
+
+	// Токен без kid, выпущенный до появления ротации
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": "Andrey"}).
+		SignedString([]byte("new-secret-0123456789"))
+	require.NoError(t, err)
+
+	unknownKeys, err := NewJWTKeys(JWTConfig{KeyID: "unknown", Secret: []byte("unknown-secret-0123456789")})
+	require.NoError(t, err)
+	unknownToken, err := unknownKeys.Sign(jwt.MapClaims{"username": "Andrey"})
+	require.NoError(t, err)
+
+	keys, err := NewJWTKeys(JWTConfig{
+		KeyID:                 "new",
+		Secret:                []byte("new-secret-0123456789"),
+		PreviousSecrets:       map[string][]byte{"old": []byte("old-secret-0123456789")},
+		PreviousPublicKeysPEM: map[string][]byte{"old-rsa": publicPEM},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "токен прежнего HMAC-ключа принимается", token: oldToken},
+		{name: "токен прежнего RSA-ключа принимается", token: oldRSAToken},
+		{name: "токен без kid проверяется текущим ключом", token: legacyToken},
+		{name: "токен неизвестного ключа отклоняется", token: unknownToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := keys.Parse(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// Открытый ключ прежней пары публикуется в JWKS до окончания ротации
+	jwks := keys.JWKS()
+	if assert.Len(t, jwks.Keys, 1) {
+		assert.Equal(t, "old-rsa", jwks.Keys[0].Kid)
+	}
+}
+
+func TestNewJWTKeysValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  JWTConfig
+	}{
+		{name: "короткий секрет", cfg: JWTConfig{Secret: []byte("short")}},
+		{name: "неизвестный алгоритм", cfg: JWTConfig{Algorithm: "none", Secret: []byte("test-secret-0123456789")}},
+		{name: "RS256 без ключа", cfg: JWTConfig{Algorithm: JWTAlgorithmRS256}},
+		{
+			name: "повторяющийся kid",
+			cfg: JWTConfig{
+				KeyID:           "same",
+				Secret:          []byte("test-secret-0123456789"),
+				PreviousSecrets: map[string][]byte{"same": []byte("old-secret-0123456789")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewJWTKeys(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+)
+
+// LedgerService - сервис для сверки балансов с журналом движения монет
+type LedgerService struct {
+	ledgerRepo repositories.LedgerRepository
+}
+
+func NewLedgerService(repo repositories.LedgerRepository) *LedgerService {
+	return &LedgerService{ledgerRepo: repo}
+}
+
+// OpenMissingAccounts - открывает счета в журнале для пользователей, созданных до его появления
+func (s *LedgerService) OpenMissingAccounts(ctx context.Context) (int64, error) {
+	return s.ledgerRepo.OpenMissingAccounts(ctx)
+}
+
+// Reconcile - сверяет кэшированные балансы пользователей с суммами проводок
+func (s *LedgerService) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	imbalance, err := s.ledgerRepo.GetLedgerImbalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies, err := s.ledgerRepo.GetBalanceDiscrepancies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if discrepancies == nil {
+		discrepancies = []models.BalanceDiscrepancy{}
+	}
+
+	return &models.ReconciliationReport{
+		Balanced:      imbalance == 0,
+		Imbalance:     imbalance,
+		Discrepancies: discrepancies,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name       string
+		mockSetup  func(mockRepo *mocks.LedgerRepository)
+		wantReport *models.ReconciliationReport
+		wantErr    error
+	}{
+		{
+			name: "журнал сходится с балансами",
+			mockSetup: func(mockRepo *mocks.LedgerRepository) {
+				mockRepo.On("GetLedgerImbalance", mock.Anything).Return(0, nil)
+				mockRepo.On("GetBalanceDiscrepancies", mock.Anything).Return(nil, nil)
+			},
+			wantReport: &models.ReconciliationReport{
+				Balanced:      true,
+				Discrepancies: []models.BalanceDiscrepancy{},
+			},
+		},
+		{
+			name: "баланс пользователя расходится с журналом",
+			mockSetup: func(mockRepo *mocks.LedgerRepository) {
+				discrepancies := []models.BalanceDiscrepancy{{UserID: 1, Username: "Andrey", CachedCoins: 1500, LedgerCoins: 1000}}
+				mockRepo.On("GetLedgerImbalance", mock.Anything).Return(0, nil)
+				mockRepo.On("GetBalanceDiscrepancies", mock.Anything).Return(discrepancies, nil)
+			},
+			wantReport: &models.ReconciliationReport{
+				Balanced:      true,
+				Discrepancies: []models.BalanceDiscrepancy{{UserID: 1, Username: "Andrey", CachedCoins: 1500, LedgerCoins: 1000}},
+			},
+		},
+		{
+			name: "несбалансированный журнал",
+			mockSetup: func(mockRepo *mocks.LedgerRepository) {
+				mockRepo.On("GetLedgerImbalance", mock.Anything).Return(50, nil)
+				mockRepo.On("GetBalanceDiscrepancies", mock.Anything).Return([]models.BalanceDiscrepancy{}, nil)
+			},
+			wantReport: &models.ReconciliationReport{
+				Balanced:      false,
+				Imbalance:     50,
+				Discrepancies: []models.BalanceDiscrepancy{},
+			},
+		},
+		{
+			name: "ошибка в репозитории",
+			mockSetup: func(mockRepo *mocks.LedgerRepository) {
+				mockRepo.On("GetLedgerImbalance", mock.Anything).Return(0, errs.ErrInternalServer)
+			},
+			wantErr: errs.ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewLedgerRepository(t)
+			service := LedgerService{ledgerRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+
+			report, err := service.Reconcile(context.Background())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantReport, report)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"time"
+)
+
+// LoginGuard - защита от перебора паролей: считает неудачные входы по имени пользователя
+// и по IP-адресу клиента, увеличивает задержку между попытками и временно блокирует вход
+type LoginGuard struct {
+	repo     repositories.LoginAttemptRepository
+	settings models.LoginGuardSettings
+	now      func() time.Time
+}
+
+func NewLoginGuard(repo repositories.LoginAttemptRepository, settings models.LoginGuardSettings) *LoginGuard {
+	return &LoginGuard{repo: repo, settings: settings, now: time.Now}
+}
+
+// Check - проверяет, разрешена ли сейчас попытка входа; при блокировке возвращает *errs.LoginBlockedError
+func (g *LoginGuard) Check(ctx context.Context, username, clientIP string) error {
+	attempts, err := g.repo.GetLoginAttempts(ctx, []string{userAttemptKey(username), ipAttemptKey(clientIP)})
+	if err != nil {
+		return err
+	}
+
+	now := g.now()
+	var retryAfter time.Duration
+	for _, attempt := range attempts {
+		if wait := attempt.BlockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return &errs.LoginBlockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure - учитывает неудачную попытку входа и назначает задержку до следующей
+func (g *LoginGuard) RecordFailure(ctx context.Context, username, clientIP string) error {
+	if err := g.recordFailure(ctx, userAttemptKey(username), g.settings.User); err != nil {
+		return err
+	}
+	return g.recordFailure(ctx, ipAttemptKey(clientIP), g.settings.IP)
+}
+
+// RecordSuccess - сбрасывает счётчик пользователя после успешного входа.
+// Счётчик IP-адреса не сбрасывается, иначе перебор можно было бы чередовать со входом в свой аккаунт.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, username string) error {
+	return g.repo.ResetLoginAttempts(ctx, userAttemptKey(username))
+}
+
+// Unlock - снимает блокировку входа с аккаунта
+func (g *LoginGuard) Unlock(ctx context.Context, username string) error {
+	return g.repo.ResetLoginAttempts(ctx, userAttemptKey(username))
+}
+
+func (g *LoginGuard) recordFailure(ctx context.Context, key string, limit models.LoginLimit) error {
+	now := g.now()
+	failures, err := g.repo.RecordLoginFailure(ctx, key, now, g.settings.Window)
+	if err != nil {
+		return err
+	}
+
+	if delay := g.delay(failures, limit); delay > 0 {
+		return g.repo.BlockLogin(ctx, key, now.Add(delay))
+	}
+	return nil
+}
+
+// delay - задержка после failures неудачных попыток: ноль в пределах бесплатных попыток,
+// затем BaseDelay, удваивающаяся с каждой попыткой до MaxDelay, и LockoutDuration после MaxAttempts
+func (g *LoginGuard) delay(failures int, limit models.LoginLimit) time.Duration {
+	if limit.MaxAttempts > 0 && failures >= limit.MaxAttempts {
+		return g.settings.LockoutDuration
+	}
+	if failures <= limit.FreeAttempts {
+		return 0
+	}
+
+	delay := g.settings.BaseDelay
+	for i := limit.FreeAttempts + 1; i < failures && delay < g.settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.settings.MaxDelay {
+		delay = g.settings.MaxDelay
+	}
+	return delay
+}
+
+func userAttemptKey(username string) string {
+	return "user:" + username
+}
+
+func ipAttemptKey(clientIP string) string {
+	return "ip:" + clientIP
+}
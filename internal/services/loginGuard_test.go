@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"testing"
+	"time"
+)
+
+var testGuardSettings = models.LoginGuardSettings{
+	User:            models.LoginLimit{FreeAttempts: 3, MaxAttempts: 10},
+	IP:              models.LoginLimit{FreeAttempts: 20, MaxAttempts: 100},
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+func TestLoginGuardDelay(t *testing.T) {
+	guard := NewLoginGuard(nil, testGuardSettings)
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantDelay time.Duration
+	}{
+		{name: "в пределах бесплатных попыток", failures: 3, wantDelay: 0},
+		{name: "первая задержка", failures: 4, wantDelay: time.Second},
+		{name: "задержка удваивается", failures: 6, wantDelay: 4 * time.Second},
+		{name: "задержка ограничена сверху", failures: 9, wantDelay: 32 * time.Second},
+		{name: "блокировка после порога", failures: 10, wantDelay: 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantDelay, guard.delay(tt.failures, testGuardSettings.User))
+		})
+	}
+
+	capped := testGuardSettings
+	capped.User.MaxAttempts = 0
+	guard = NewLoginGuard(nil, capped)
+	assert.Equal(t, time.Minute, guard.delay(30, capped.User))
+}
+
+func TestLoginGuardCheck(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		attempts       []models.LoginAttempt
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:     "попыток не было",
+			attempts: nil,
+		},
+		{
+			name:     "блокировка истекла",
+			attempts: []models.LoginAttempt{{Key: "user:Andrey", Failures: 5, BlockedUntil: now.Add(-time.Second)}},
+		},
+		{
+			name: "берётся самая долгая блокировка",
+			attempts: []models.LoginAttempt{
+				{Key: "user:Andrey", Failures: 5, BlockedUntil: now.Add(4 * time.Second)},
+				{Key: "ip:10.0.0.1", Failures: 100, BlockedUntil: now.Add(time.Minute)},
+			},
+			wantRetryAfter: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewLoginAttemptRepository(t)
+			mockRepo.On("GetLoginAttempts", mock.Anything, []string{"user:Andrey", "ip:10.0.0.1"}).Return(tt.attempts, nil)
+
+			guard := NewLoginGuard(mockRepo, testGuardSettings)
+			guard.now = func() time.Time { return now }
+
+			err := guard.Check(context.Background(), "Andrey", "10.0.0.1")
+
+			if tt.wantRetryAfter == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var blocked *errs.LoginBlockedError
+			assert.True(t, errors.As(err, &blocked))
+			assert.ErrorIs(t, err, errs.ErrTooManyLoginAttempts)
+			assert.Equal(t, tt.wantRetryAfter, blocked.RetryAfter)
+		})
+	}
+}
+
+func TestLoginGuardRecordFailure(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mockRepo := mocks.NewLoginAttemptRepository(t)
+	mockRepo.On("RecordLoginFailure", mock.Anything, "user:Andrey", now, testGuardSettings.Window).Return(4, nil)
+	mockRepo.On("BlockLogin", mock.Anything, "user:Andrey", now.Add(time.Second)).Return(nil)
+	mockRepo.On("RecordLoginFailure", mock.Anything, "ip:10.0.0.1", now, testGuardSettings.Window).Return(4, nil)
+
+	guard := NewLoginGuard(mockRepo, testGuardSettings)
+	guard.now = func() time.Time { return now }
+
+	assert.NoError(t, guard.RecordFailure(context.Background(), "Andrey", "10.0.0.1"))
+}
+
+func TestMemoryLoginAttemptRepo(t *testing.T) {
+	var repo repositories.LoginAttemptRepository = repositories.NewMemoryLoginAttemptRepo()
+	guard := NewLoginGuard(repo, testGuardSettings)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+
+	for i := 0; i < testGuardSettings.User.MaxAttempts; i++ {
+		assert.NoError(t, guard.RecordFailure(context.Background(), "Andrey", "10.0.0.1"))
+	}
+
+	var blocked *errs.LoginBlockedError
+	assert.True(t, errors.As(guard.Check(context.Background(), "Andrey", "10.0.0.2"), &blocked))
+	assert.Equal(t, testGuardSettings.LockoutDuration, blocked.RetryAfter)
+
+	// Другой пользователь с того же адреса не заблокирован
+	assert.NoError(t, guard.Check(context.Background(), "Boris", "10.0.0.1"))
+
+	assert.NoError(t, guard.Unlock(context.Background(), "Andrey"))
+	assert.NoError(t, guard.Check(context.Background(), "Andrey", "10.0.0.1"))
+
+	// Счётчик начинается заново после окна без неудачных попыток
+	assert.NoError(t, guard.RecordFailure(context.Background(), "Andrey", "10.0.0.1"))
+	now = now.Add(testGuardSettings.Window + time.Second)
+	failures, err := repo.RecordLoginFailure(context.Background(), "user:Andrey", now, testGuardSettings.Window)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+}
@@ -1,11 +1,20 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"gorm.io/gorm"
 	"merch-shop/internal/errs"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repositories"
+	"strings"
+)
+
+// Ограничения пагинации каталога
+const (
+	defaultMerchPageSize = 20
+	maxMerchPageSize     = 100
+	maxMerchNameLength   = 255
 )
 
 // MerchService - сервис для работы с пользователями
@@ -17,8 +26,8 @@ func NewMerchService(repo repositories.MerchRepository) *MerchService {
 	return &MerchService{merchRepo: repo}
 }
 
-func (s *MerchService) GetMerchByName(name string) (*models.Merch, error) {
-	merch, err := s.merchRepo.GetMerchByName(name)
+func (s *MerchService) GetMerchByName(ctx context.Context, name string) (*models.Merch, error) {
+	merch, err := s.merchRepo.GetMerchByName(ctx, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errs.ErrMerchNotFound
@@ -27,3 +36,151 @@ func (s *MerchService) GetMerchByName(name string) (*models.Merch, error) {
 	}
 	return merch, nil
 }
+
+// ListMerch - возвращает страницу каталога
+func (s *MerchService) ListMerch(ctx context.Context, query models.MerchListQuery) (*models.MerchListResponse, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultMerchPageSize
+	}
+	if query.Sort == "" {
+		query.Sort = models.MerchSortName
+	}
+	if query.Limit < 0 || query.Limit > maxMerchPageSize || query.Offset < 0 {
+		return nil, errs.ErrInvalidPagination
+	}
+	switch query.Sort {
+	case models.MerchSortName, models.MerchSortPriceAsc, models.MerchSortPriceDesc:
+	default:
+		return nil, errs.ErrInvalidPagination
+	}
+
+	merches, total, err := s.merchRepo.ListMerch(ctx, query.Limit, query.Offset, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]models.MerchItem, 0, len(merches))
+	for i := range merches {
+		items = append(items, merches[i].ToItem())
+	}
+
+	return &models.MerchListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// CreateMerch - добавляет товар в каталог
+func (s *MerchService) CreateMerch(ctx context.Context, req models.CreateMerchRequest) (*models.Merch, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := validateMerch(name, req.Price); err != nil {
+		return nil, err
+	}
+
+	if req.Stock != nil && *req.Stock < 0 {
+		return nil, errs.ErrInvalidStock
+	}
+	if req.PerUserLimit != nil && *req.PerUserLimit <= 0 {
+		return nil, errs.ErrInvalidStock
+	}
+
+	merch := &models.Merch{Name: name, Price: req.Price, Stock: req.Stock, PerUserLimit: req.PerUserLimit}
+	if err := s.merchRepo.CreateMerch(ctx, merch); err != nil {
+		return nil, err
+	}
+	return merch, nil
+}
+
+// UpdateMerch - меняет название, цену и/или лимит покупок на пользователя
+func (s *MerchService) UpdateMerch(ctx context.Context, name string, req models.UpdateMerchRequest) (*models.Merch, error) {
+	merch, err := s.GetMerchByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		merch.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Price != nil {
+		merch.Price = *req.Price
+	}
+	if req.PerUserLimit != nil {
+		switch {
+		case *req.PerUserLimit < 0:
+			return nil, errs.ErrInvalidStock
+		case *req.PerUserLimit == 0:
+			merch.PerUserLimit = nil
+		default:
+			merch.PerUserLimit = req.PerUserLimit
+		}
+	}
+	if err = validateMerch(merch.Name, merch.Price); err != nil {
+		return nil, err
+	}
+
+	if err = s.merchRepo.UpdateMerch(ctx, merch); err != nil {
+		return nil, err
+	}
+	return merch, nil
+}
+
+// ChangeStock - пополняет склад, устанавливает остаток или снимает ограничение остатка
+func (s *MerchService) ChangeStock(ctx context.Context, name string, req models.StockRequest) (*models.Merch, error) {
+	changes := 0
+	for _, set := range []bool{req.Add != nil, req.Set != nil, req.Unlimited} {
+		if set {
+			changes++
+		}
+	}
+	if changes != 1 {
+		return nil, errs.ErrInvalidStock
+	}
+
+	merch, err := s.GetMerchByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Add != nil:
+		// Пополнить можно только товар с ограниченным остатком
+		if *req.Add <= 0 || merch.Stock == nil {
+			return nil, errs.ErrInvalidStock
+		}
+		err = s.merchRepo.RestockMerch(ctx, merch, *req.Add)
+	case req.Set != nil:
+		if *req.Set < 0 {
+			return nil, errs.ErrInvalidStock
+		}
+		err = s.merchRepo.SetMerchStock(ctx, merch, req.Set)
+	default:
+		err = s.merchRepo.SetMerchStock(ctx, merch, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return merch, nil
+}
+
+// RetireMerch - снимает товар с продажи; он остаётся в инвентаре купивших его пользователей
+func (s *MerchService) RetireMerch(ctx context.Context, name string) error {
+	merch, err := s.GetMerchByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.merchRepo.DeleteMerch(ctx, merch)
+}
+
+// validateMerch - проверяет название и цену товара
+func validateMerch(name string, price int) error {
+	// Название используется в пути запроса /api/buy/{item}
+	if name == "" || len(name) > maxMerchNameLength || strings.Contains(name, "/") {
+		return errs.ErrInvalidMerchName
+	}
+	if price <= 0 {
+		return errs.ErrInvalidPrice
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 	"merch-shop/internal/errs"
 	"merch-shop/internal/mocks"
@@ -20,7 +22,7 @@ func TestGetMerchByName(t *testing.T) {
 			name: "успешное получение товара",
 			mockSetup: func(mockRepo *mocks.MerchRepository) string {
 				merch := &models.Merch{Name: "t-shirt", Price: 80}
-				mockRepo.On("GetMerchByName", "t-shirt").Return(merch, nil)
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(merch, nil)
 				return "t-shirt"
 			},
 			wantMerch: &models.Merch{Name: "t-shirt", Price: 80},
@@ -29,7 +31,7 @@ func TestGetMerchByName(t *testing.T) {
 		{
 			name: "товар не найден",
 			mockSetup: func(mockRepo *mocks.MerchRepository) string {
-				mockRepo.On("GetMerchByName", "nonexistent").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetMerchByName", mock.Anything, "nonexistent").Return(nil, gorm.ErrRecordNotFound)
 				return "nonexistent"
 			},
 			wantMerch: nil,
@@ -38,7 +40,7 @@ func TestGetMerchByName(t *testing.T) {
 		{
 			name: "ошибка в репозитории",
 			mockSetup: func(mockRepo *mocks.MerchRepository) string {
-				mockRepo.On("GetMerchByName", "t-shirt").Return(nil, errs.ErrInternalServer)
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(nil, errs.ErrInternalServer)
 				return "t-shirt"
 			},
 			wantMerch: nil,
@@ -54,7 +56,7 @@ func TestGetMerchByName(t *testing.T) {
 			service := MerchService{merchRepo: mockRepo}
 
 			name := tt.mockSetup(mockRepo)
-			merch, err := service.GetMerchByName(name)
+			merch, err := service.GetMerchByName(context.Background(), name)
 
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
@@ -67,3 +69,329 @@ func TestGetMerchByName(t *testing.T) {
 		})
 	}
 }
+
+func TestListMerch(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     models.MerchListQuery
+		mockSetup func(mockRepo *mocks.MerchRepository)
+		wantResp  *models.MerchListResponse
+		wantErr   error
+	}{
+		{
+			name:  "страница по умолчанию",
+			query: models.MerchListQuery{},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merches := []models.Merch{{Name: "cup", Price: 20}, {Name: "t-shirt", Price: 80}}
+				mockRepo.On("ListMerch", mock.Anything, 20, 0, models.MerchSortName).Return(merches, int64(2), nil)
+			},
+			wantResp: &models.MerchListResponse{
+				Items:  []models.MerchItem{{Name: "cup", Price: 20}, {Name: "t-shirt", Price: 80}},
+				Total:  2,
+				Limit:  20,
+				Offset: 0,
+			},
+		},
+		{
+			name:  "сортировка по убыванию цены",
+			query: models.MerchListQuery{Limit: 1, Offset: 1, Sort: models.MerchSortPriceDesc},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merches := []models.Merch{{Name: "cup", Price: 20}}
+				mockRepo.On("ListMerch", mock.Anything, 1, 1, models.MerchSortPriceDesc).Return(merches, int64(2), nil)
+			},
+			wantResp: &models.MerchListResponse{
+				Items:  []models.MerchItem{{Name: "cup", Price: 20}},
+				Total:  2,
+				Limit:  1,
+				Offset: 1,
+			},
+		},
+		{
+			name:      "слишком большая страница",
+			query:     models.MerchListQuery{Limit: 1000},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {},
+			wantErr:   errs.ErrInvalidPagination,
+		},
+		{
+			name:      "неизвестная сортировка",
+			query:     models.MerchListQuery{Sort: "color"},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {},
+			wantErr:   errs.ErrInvalidPagination,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewMerchRepository(t)
+			service := MerchService{merchRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+			resp, err := service.ListMerch(context.Background(), tt.query)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantResp, resp)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateMerch(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       models.CreateMerchRequest
+		mockSetup func(mockRepo *mocks.MerchRepository)
+		wantErr   error
+	}{
+		{
+			name: "успешное создание товара",
+			req:  models.CreateMerchRequest{Name: " sticker ", Price: 5},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("CreateMerch", mock.Anything, &models.Merch{Name: "sticker", Price: 5}).Return(nil)
+			},
+		},
+		{
+			name:      "неположительная цена",
+			req:       models.CreateMerchRequest{Name: "sticker", Price: 0},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {},
+			wantErr:   errs.ErrInvalidPrice,
+		},
+		{
+			name:      "пустое название",
+			req:       models.CreateMerchRequest{Name: "  ", Price: 5},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {},
+			wantErr:   errs.ErrInvalidMerchName,
+		},
+		{
+			name: "товар уже существует",
+			req:  models.CreateMerchRequest{Name: "cup", Price: 5},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("CreateMerch", mock.Anything, &models.Merch{Name: "cup", Price: 5}).Return(errs.ErrMerchAlreadyExists)
+			},
+			wantErr: errs.ErrMerchAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewMerchRepository(t)
+			service := MerchService{merchRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+			merch, err := service.CreateMerch(context.Background(), tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "sticker", merch.Name)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateMerch(t *testing.T) {
+	newPrice := 90
+	badPrice := -1
+
+	tests := []struct {
+		name      string
+		req       models.UpdateMerchRequest
+		mockSetup func(mockRepo *mocks.MerchRepository)
+		wantMerch *models.Merch
+		wantErr   error
+	}{
+		{
+			name: "изменение цены",
+			req:  models.UpdateMerchRequest{Price: &newPrice},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(&models.Merch{Name: "t-shirt", Price: 80}, nil)
+				mockRepo.On("UpdateMerch", mock.Anything, &models.Merch{Name: "t-shirt", Price: 90}).Return(nil)
+			},
+			wantMerch: &models.Merch{Name: "t-shirt", Price: 90},
+		},
+		{
+			name: "некорректная цена",
+			req:  models.UpdateMerchRequest{Price: &badPrice},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(&models.Merch{Name: "t-shirt", Price: 80}, nil)
+			},
+			wantErr: errs.ErrInvalidPrice,
+		},
+		{
+			name: "товар не найден",
+			req:  models.UpdateMerchRequest{Price: &newPrice},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrMerchNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewMerchRepository(t)
+			service := MerchService{merchRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+			merch, err := service.UpdateMerch(context.Background(), "t-shirt", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantMerch, merch)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRetireMerch(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockSetup func(mockRepo *mocks.MerchRepository)
+		wantErr   error
+	}{
+		{
+			name: "успешное снятие с продажи",
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merch := &models.Merch{Name: "t-shirt", Price: 80}
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(merch, nil)
+				mockRepo.On("DeleteMerch", mock.Anything, merch).Return(nil)
+			},
+		},
+		{
+			name: "товар не найден",
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "t-shirt").Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrMerchNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewMerchRepository(t)
+			service := MerchService{merchRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+			err := service.RetireMerch(context.Background(), "t-shirt")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangeStock(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name      string
+		req       models.StockRequest
+		mockSetup func(mockRepo *mocks.MerchRepository)
+		wantStock *int
+		wantErr   error
+	}{
+		{
+			name: "пополнение склада",
+			req:  models.StockRequest{Add: intPtr(5)},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merch := &models.Merch{Name: "pink-hoody", Price: 300, Stock: intPtr(1)}
+				mockRepo.On("GetMerchByName", mock.Anything, "pink-hoody").Return(merch, nil)
+				mockRepo.On("RestockMerch", mock.Anything, merch, 5).Run(func(args mock.Arguments) {
+					args.Get(1).(*models.Merch).Stock = intPtr(6)
+				}).Return(nil)
+			},
+			wantStock: intPtr(6),
+		},
+		{
+			name: "установка остатка",
+			req:  models.StockRequest{Set: intPtr(10)},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merch := &models.Merch{Name: "pink-hoody", Price: 300}
+				mockRepo.On("GetMerchByName", mock.Anything, "pink-hoody").Return(merch, nil)
+				mockRepo.On("SetMerchStock", mock.Anything, merch, intPtr(10)).Run(func(args mock.Arguments) {
+					args.Get(1).(*models.Merch).Stock = intPtr(10)
+				}).Return(nil)
+			},
+			wantStock: intPtr(10),
+		},
+		{
+			name: "снятие ограничения",
+			req:  models.StockRequest{Unlimited: true},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				merch := &models.Merch{Name: "pink-hoody", Price: 300, Stock: intPtr(3)}
+				mockRepo.On("GetMerchByName", mock.Anything, "pink-hoody").Return(merch, nil)
+				mockRepo.On("SetMerchStock", mock.Anything, merch, (*int)(nil)).Run(func(args mock.Arguments) {
+					args.Get(1).(*models.Merch).Stock = nil
+				}).Return(nil)
+			},
+			wantStock: nil,
+		},
+		{
+			name: "пополнение товара без ограничения остатка",
+			req:  models.StockRequest{Add: intPtr(5)},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "pink-hoody").Return(&models.Merch{Name: "pink-hoody", Price: 300}, nil)
+			},
+			wantErr: errs.ErrInvalidStock,
+		},
+		{
+			name:      "несколько изменений сразу",
+			req:       models.StockRequest{Add: intPtr(5), Unlimited: true},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {},
+			wantErr:   errs.ErrInvalidStock,
+		},
+		{
+			name: "отрицательный остаток",
+			req:  models.StockRequest{Set: intPtr(-1)},
+			mockSetup: func(mockRepo *mocks.MerchRepository) {
+				mockRepo.On("GetMerchByName", mock.Anything, "pink-hoody").Return(&models.Merch{Name: "pink-hoody", Price: 300}, nil)
+			},
+			wantErr: errs.ErrInvalidStock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewMerchRepository(t)
+			service := MerchService{merchRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+			merch, err := service.ChangeStock(context.Background(), "pink-hoody", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantStock, merch.Stock)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
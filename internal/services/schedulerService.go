@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"log"
+	"merch-shop/internal/cron"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repositories"
+	"net/http"
+	"time"
+)
+
+// maxScheduledRuns - сколько последних запусков возвращается в истории запланированного перевода
+const maxScheduledRuns = 50
+
+// SchedulerService - запланированные переводы: разовые на будущую дату и повторяющиеся по расписанию cron.
+// Наступившие переводы выполняются фоновым обработчиком через UserService.SendScheduledCoin,
+// то есть с теми же проверками и лимитами, что и обычный перевод.
+type SchedulerService struct {
+	repo        repositories.ScheduledTransferRepository
+	userRepo    repositories.UserRepository
+	userService *UserService
+	settings    models.SchedulerSettings
+	now         func() time.Time
+}
+
+func NewSchedulerService(repo repositories.ScheduledTransferRepository, userRepo repositories.UserRepository, userService *UserService, settings models.SchedulerSettings) *SchedulerService {
+	return &SchedulerService{repo: repo, userRepo: userRepo, userService: userService, settings: settings, now: time.Now}
+}
+
+// Create - планирует перевод от имени пользователя username
+func (s *SchedulerService) Create(ctx context.Context, username string, req models.ScheduleTransferRequest) (*models.ScheduledTransferResponse, error) {
+	sender, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := s.getUser(ctx, req.ToUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Amount <= 0 {
+		return nil, errs.ErrNegativeCoins
+	}
+	if sender.ID == receiver.ID {
+		return nil, errs.ErrSendCoinsToYourself
+	}
+	message, err := sanitizeTransferMessage(req.Message)
+	if err != nil {
+		return nil, err
+	}
+	if req.Category != "" && !models.TransferCategories[req.Category] {
+		return nil, errs.ErrInvalidCategory
+	}
+
+	nextRunAt, err := s.firstRun(req)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.repo.CountActiveScheduledTransfers(ctx, sender.ID)
+	if err != nil {
+		return nil, errs.ErrInternalServer
+	}
+	if s.settings.MaxActivePerUser > 0 && active >= int64(s.settings.MaxActivePerUser) {
+		return nil, errs.WithDetails(errs.ErrTooManyScheduledTransfers, map[string]interface{}{"max": s.settings.MaxActivePerUser})
+	}
+
+	transfer := &models.ScheduledTransfer{
+		SenderID:     sender.ID,
+		ReceiverID:   receiver.ID,
+		Amount:       req.Amount,
+		Message:      message,
+		Category:     req.Category,
+		RunAt:        req.RunAt,
+		Cron:         req.Cron,
+		Status:       models.ScheduleStatusActive,
+		NextRunAt:    &nextRunAt,
+		ReceiverName: receiver.Username,
+	}
+	if err = s.repo.CreateScheduledTransfer(ctx, transfer); err != nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	response := transfer.ToResponse()
+	return &response, nil
+}
+
+// firstRun - время первого запуска: runAt разового перевода или ближайшее срабатывание расписания
+func (s *SchedulerService) firstRun(req models.ScheduleTransferRequest) (time.Time, error) {
+	if (req.RunAt == nil) == (req.Cron == "") {
+		return time.Time{}, errs.ErrInvalidSchedule
+	}
+
+	now := s.now().UTC()
+	if req.RunAt != nil {
+		if !req.RunAt.After(now) {
+			return time.Time{}, errs.WithDetails(errs.ErrInvalidSchedule, map[string]interface{}{"runAt": "must be in the future"})
+		}
+		return req.RunAt.UTC(), nil
+	}
+
+	schedule, err := cron.Parse(req.Cron)
+	if err != nil {
+		return time.Time{}, errs.WithDetails(errs.ErrInvalidSchedule, map[string]interface{}{"cron": err.Error()})
+	}
+	next, err := schedule.Next(now)
+	if err != nil {
+		return time.Time{}, errs.WithDetails(errs.ErrInvalidSchedule, map[string]interface{}{"cron": err.Error()})
+	}
+	return next, nil
+}
+
+// List - запланированные переводы пользователя
+func (s *SchedulerService) List(ctx context.Context, username string) ([]models.ScheduledTransferResponse, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers, err := s.repo.ListScheduledTransfers(ctx, user.ID)
+	if err != nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	response := make([]models.ScheduledTransferResponse, len(transfers))
+	for i := range transfers {
+		response[i] = transfers[i].ToResponse()
+	}
+	return response, nil
+}
+
+// Runs - последние запуски запланированного перевода, включая причины неудач
+func (s *SchedulerService) Runs(ctx context.Context, username string, id uint) ([]models.ScheduledRunResponse, error) {
+	transfer, err := s.getOwnTransfer(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := s.repo.ListScheduledRuns(ctx, transfer.ID, maxScheduledRuns)
+	if err != nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	response := make([]models.ScheduledRunResponse, len(runs))
+	for i := range runs {
+		response[i] = runs[i].ToResponse()
+	}
+	return response, nil
+}
+
+// Cancel - отменяет действующий запланированный перевод
+func (s *SchedulerService) Cancel(ctx context.Context, username string, id uint) error {
+	transfer, err := s.getOwnTransfer(ctx, username, id)
+	if err != nil {
+		return err
+	}
+	if transfer.Status != models.ScheduleStatusActive {
+		return errs.ErrScheduleNotActive
+	}
+
+	if err = s.repo.CancelScheduledTransfer(ctx, transfer); err != nil {
+		return errs.ErrInternalServer
+	}
+	return nil
+}
+
+// getOwnTransfer - запланированный перевод пользователя; чужие переводы не раскрываются
+func (s *SchedulerService) getOwnTransfer(ctx context.Context, username string, id uint) (*models.ScheduledTransfer, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := s.repo.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrScheduleNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+	if transfer.SenderID != user.ID {
+		return nil, errs.ErrScheduleNotFound
+	}
+	return transfer, nil
+}
+
+func (s *SchedulerService) getUser(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+	if user == nil {
+		return nil, errs.ErrInternalServer
+	}
+	return user, nil
+}
+
+// Start - проверяет наступившие переводы каждые Interval, пока не отменён ctx
+func (s *SchedulerService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunDue(ctx); err != nil {
+			log.Println("failed to run scheduled transfers: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue - выполняет брошенные и наступившие запуски и возвращает их число.
+// Запуски берутся в работу с блокировкой строк, поэтому экземпляры сервера не выполняют один запуск одновременно.
+func (s *SchedulerService) RunDue(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+
+	stale, err := s.repo.ReclaimStaleRuns(ctx, now.Add(-s.settings.Lease), now, s.settings.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	due, err := s.repo.ClaimDueTransfers(ctx, now, s.settings.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for _, run := range append(stale, due...) {
+		// Сервер останавливается: оставшиеся запуски выполнит следующий проход
+		if err = ctx.Err(); err != nil {
+			return executed, err
+		}
+		if err = s.execute(ctx, run); err != nil {
+			log.Printf("scheduled run %d: %v", run.RunID, err)
+			continue
+		}
+		executed++
+	}
+	return executed, nil
+}
+
+// execute - выполняет один запуск и записывает его итог. Внутренняя ошибка оставляет запуск незавершённым:
+// он будет выполнен повторно по истечении Lease.
+func (s *SchedulerService) execute(ctx context.Context, run models.DueTransfer) error {
+	// Брошенный запуск мог успеть перевести монеты до остановки сервера
+	done, err := s.repo.RunHasTransaction(ctx, run.RunID)
+	if err != nil {
+		return err
+	}
+
+	if !done {
+		if err = s.userService.SendScheduledCoin(ctx, run); err != nil {
+			apiErr, _ := errs.Resolve(err)
+			if apiErr.Status >= http.StatusInternalServerError {
+				return err
+			}
+			return s.repo.FinishScheduledRun(ctx, run, models.RunStatusFailed, apiErr.Code, apiErr.Message, s.now().UTC())
+		}
+	}
+	return s.repo.FinishScheduledRun(ctx, run, models.RunStatusSucceeded, "", "", s.now().UTC())
+}
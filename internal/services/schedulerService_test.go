@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+	"merch-shop/internal/errs"
+	"merch-shop/internal/mocks"
+	"merch-shop/internal/models"
+	"testing"
+	"time"
+)
+
+// testSchedulerNow - текущее время в тестах планировщика
+var testSchedulerNow = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestScheduler(t *testing.T) (*SchedulerService, *mocks.ScheduledTransferRepository, *mocks.UserRepository) {
+	repo := mocks.NewScheduledTransferRepository(t)
+	userRepo := mocks.NewUserRepository(t)
+	userService := &UserService{userRepo: userRepo, economy: newTestEconomy(models.DefaultEconomySettings())}
+
+	settings := models.SchedulerSettings{Interval: time.Minute, BatchSize: 10, Lease: 5 * time.Minute, MaxActivePerUser: 2}
+	service := NewSchedulerService(repo, userRepo, userService, settings)
+	service.now = func() time.Time { return testSchedulerNow }
+	return service, repo, userRepo
+}
+
+func TestCreateScheduledTransfer(t *testing.T) {
+	sender := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey", Coins: 100}
+	receiver := &models.User{Model: gorm.Model{ID: 2}, Username: "Ivan", Coins: 50}
+	future, past := testSchedulerNow.Add(time.Hour), testSchedulerNow.Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		req           models.ScheduleTransferRequest
+		active        int64
+		wantErr       error
+		wantNextRunAt time.Time
+	}{
+		{
+			name:          "разовый перевод",
+			req:           models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, RunAt: &future},
+			wantNextRunAt: future,
+		},
+		{
+			name:          "ежемесячный перевод",
+			req:           models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, Cron: "0 9 1 * *", Category: models.TransferCategoryThanks},
+			wantNextRunAt: time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "время разового перевода в прошлом",
+			req:     models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, RunAt: &past},
+			wantErr: errs.ErrInvalidSchedule,
+		},
+		{
+			name:    "указаны и время, и расписание",
+			req:     models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, RunAt: &future, Cron: "@daily"},
+			wantErr: errs.ErrInvalidSchedule,
+		},
+		{
+			name:    "некорректное расписание",
+			req:     models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, Cron: "61 * * * *"},
+			wantErr: errs.ErrInvalidSchedule,
+		},
+		{
+			name:    "перевод самому себе",
+			req:     models.ScheduleTransferRequest{ToUser: "Andrey", Amount: 10, Cron: "@daily"},
+			wantErr: errs.ErrSendCoinsToYourself,
+		},
+		{
+			name:    "неположительная сумма",
+			req:     models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 0, Cron: "@daily"},
+			wantErr: errs.ErrNegativeCoins,
+		},
+		{
+			name:    "превышено число переводов",
+			req:     models.ScheduleTransferRequest{ToUser: "Ivan", Amount: 10, Cron: "@daily"},
+			active:  2,
+			wantErr: errs.ErrTooManyScheduledTransfers,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service, repo, userRepo := newTestScheduler(t)
+			userRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(sender, nil)
+			userRepo.On("GetUserByUsername", mock.Anything, "Ivan").Return(receiver, nil).Maybe()
+			repo.On("CountActiveScheduledTransfers", mock.Anything, sender.ID).Return(tt.active, nil).Maybe()
+			repo.On("CreateScheduledTransfer", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			transfer, err := service.Create(context.Background(), "Andrey", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				repo.AssertNotCalled(t, "CreateScheduledTransfer", mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.ScheduleStatusActive, transfer.Status)
+			assert.Equal(t, "Ivan", transfer.ToUser)
+			assert.Equal(t, tt.wantNextRunAt, *transfer.NextRunAt)
+		})
+	}
+}
+
+func TestRunDueScheduledTransfers(t *testing.T) {
+	due := models.DueTransfer{RunID: 7, ScheduleID: 3, SenderUsername: "Andrey", ReceiverUsername: "Ivan", Amount: 50}
+	sender := &models.User{Username: "Andrey", Coins: 100}
+	receiver := &models.User{Username: "Ivan", Coins: 50}
+
+	tests := []struct {
+		name         string
+		mockSetup    func(repo *mocks.ScheduledTransferRepository, userRepo *mocks.UserRepository)
+		wantExecuted int
+	}{
+		{
+			name: "перевод выполнен",
+			mockSetup: func(repo *mocks.ScheduledTransferRepository, userRepo *mocks.UserRepository) {
+				repo.On("RunHasTransaction", mock.Anything, due.RunID).Return(false, nil)
+				userRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(sender, nil)
+				userRepo.On("GetUserByUsername", mock.Anything, "Ivan").Return(receiver, nil)
+				runID := due.RunID
+				userRepo.On("SendCoin", mock.Anything, sender, receiver, &models.Transaction{Amount: 50, ScheduledRunID: &runID}, models.TransferLimits{}).Return(nil)
+				repo.On("FinishScheduledRun", mock.Anything, due, models.RunStatusSucceeded, "", "", testSchedulerNow).Return(nil)
+			},
+			wantExecuted: 1,
+		},
+		{
+			name: "недостаточно монет записывается как неудачный запуск",
+			mockSetup: func(repo *mocks.ScheduledTransferRepository, userRepo *mocks.UserRepository) {
+				repo.On("RunHasTransaction", mock.Anything, due.RunID).Return(false, nil)
+				userRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey", Coins: 10}, nil)
+				userRepo.On("GetUserByUsername", mock.Anything, "Ivan").Return(receiver, nil)
+				repo.On("FinishScheduledRun", mock.Anything, due, models.RunStatusFailed, errs.ErrNotEnoughCoins.Code, errs.ErrNotEnoughCoins.Message, testSchedulerNow).Return(nil)
+			},
+			wantExecuted: 1,
+		},
+		{
+			name: "брошенный запуск уже перевёл монеты",
+			mockSetup: func(repo *mocks.ScheduledTransferRepository, userRepo *mocks.UserRepository) {
+				repo.On("RunHasTransaction", mock.Anything, due.RunID).Return(true, nil)
+				repo.On("FinishScheduledRun", mock.Anything, due, models.RunStatusSucceeded, "", "", testSchedulerNow).Return(nil)
+			},
+			wantExecuted: 1,
+		},
+		{
+			name: "внутренняя ошибка оставляет запуск для повтора",
+			mockSetup: func(repo *mocks.ScheduledTransferRepository, userRepo *mocks.UserRepository) {
+				repo.On("RunHasTransaction", mock.Anything, due.RunID).Return(false, nil)
+				userRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(nil, errors.New("connection reset"))
+			},
+			wantExecuted: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service, repo, userRepo := newTestScheduler(t)
+			repo.On("ReclaimStaleRuns", mock.Anything, testSchedulerNow.Add(-5*time.Minute), testSchedulerNow, 10).Return(nil, nil)
+			repo.On("ClaimDueTransfers", mock.Anything, testSchedulerNow, 10).Return([]models.DueTransfer{due}, nil)
+			tt.mockSetup(repo, userRepo)
+
+			executed, err := service.RunDue(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantExecuted, executed)
+		})
+	}
+}
+
+func TestRunDueStopsWhenCancelled(t *testing.T) {
+	service, repo, _ := newTestScheduler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Контекст доходит до репозитория; сервер останавливается, пока запуски берутся в работу
+	repo.On("ReclaimStaleRuns", ctx, testSchedulerNow.Add(-5*time.Minute), testSchedulerNow, 10).Return(nil, nil)
+	repo.On("ClaimDueTransfers", ctx, testSchedulerNow, 10).
+		Run(func(mock.Arguments) { cancel() }).
+		Return([]models.DueTransfer{{RunID: 7}, {RunID: 8}}, nil)
+
+	executed, err := service.RunDue(ctx)
+
+	// Ни один запуск не начат: они останутся для следующего прохода
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, executed)
+	repo.AssertNotCalled(t, "RunHasTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCancelScheduledTransfer(t *testing.T) {
+	owner := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey"}
+
+	tests := []struct {
+		name     string
+		transfer *models.ScheduledTransfer
+		wantErr  error
+	}{
+		{
+			name:     "отмена действующего перевода",
+			transfer: &models.ScheduledTransfer{Model: gorm.Model{ID: 5}, SenderID: 1, Status: models.ScheduleStatusActive},
+		},
+		{
+			name:     "чужой перевод",
+			transfer: &models.ScheduledTransfer{Model: gorm.Model{ID: 5}, SenderID: 2, Status: models.ScheduleStatusActive},
+			wantErr:  errs.ErrScheduleNotFound,
+		},
+		{
+			name:     "перевод уже выполнен",
+			transfer: &models.ScheduledTransfer{Model: gorm.Model{ID: 5}, SenderID: 1, Status: models.ScheduleStatusCompleted},
+			wantErr:  errs.ErrScheduleNotActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service, repo, userRepo := newTestScheduler(t)
+			userRepo.On("GetUserByUsername", mock.Anything, owner.Username).Return(owner, nil)
+			repo.On("GetScheduledTransfer", mock.Anything, uint(5)).Return(tt.transfer, nil)
+			repo.On("CancelScheduledTransfer", mock.Anything, tt.transfer).Return(nil).Maybe()
+
+			err := service.Cancel(context.Background(), owner.Username, 5)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				repo.AssertNotCalled(t, "CancelScheduledTransfer", tt.transfer)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
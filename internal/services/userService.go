@@ -1,63 +1,272 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"log"
 	"merch-shop/internal/errs"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repositories"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-var jwtSecret = []byte("key-1848237283829139213")
+const (
+	recentHistoryLimit     = 10   // Сколько последних переводов каждого направления возвращает /api/info
+	defaultHistoryPageSize = 20   // Размер страницы истории по умолчанию
+	maxHistoryPageSize     = 100  // Максимальный размер страницы истории
+	maxOrderLines          = 100  // Максимальное число различных товаров в заказе
+	maxOrderQuantity       = 1000 // Максимальное количество одного товара в заказе
+	maxGrantLines          = 1000 // Максимальное число получателей в массовом зачислении
+	maxTransferMessage     = 200  // Максимальная длина сообщения к переводу в символах
+	maxCoinRequests        = 100  // Максимальное число запросов монет в списке
+	holdExpiryBatch        = 100  // Сколько истёкших удержаний возвращается за один проход
+)
 
 // UserService - сервис для работы с пользователями
 type UserService struct {
-	userRepo repositories.UserRepository
+	userRepo          repositories.UserRepository
+	tokenRepo         repositories.TokenRepository
+	jwtKeys           *JWTKeys
+	authSettings      models.AuthSettings
+	refundGracePeriod time.Duration // Сколько времени после покупки пользователь может сам её вернуть
+	economy           *EconomyService
+	coinRequestTTL    time.Duration // Сколько времени запрос монет ждёт ответа плательщика
+	holdSettings      models.HoldSettings
 }
 
-func NewUserService(repo repositories.UserRepository) *UserService {
-	return &UserService{userRepo: repo}
+func NewUserService(repo repositories.UserRepository, tokenRepo repositories.TokenRepository, jwtKeys *JWTKeys, authSettings models.AuthSettings, refundGracePeriod time.Duration, economy *EconomyService, coinRequestTTL time.Duration, holdSettings models.HoldSettings) *UserService {
+	return &UserService{userRepo: repo, tokenRepo: tokenRepo, jwtKeys: jwtKeys, authSettings: authSettings, refundGracePeriod: refundGracePeriod, economy: economy, coinRequestTTL: coinRequestTTL, holdSettings: holdSettings}
 }
 
-// Authenticate - метод для аутентификации и создания пользователя
-func (s *UserService) Authenticate(req *models.AuthRequest) (*models.AuthResponse, error) {
+// Authenticate - метод для аутентификации пользователя.
+// Если разрешена автоматическая регистрация, для неизвестного имени создаётся новый аккаунт.
+// Неизвестный пользователь (ErrUnknownUser) и неверный пароль (ErrInvalidPassword) различаются
+// только для журнала: клиенту оба случая отдаются одинаково.
+func (s *UserService) Authenticate(ctx context.Context, req *models.AuthRequest) (*models.AuthResponse, error) {
 	// Проверяем, есть ли пользователь в базе
-	user, err := s.userRepo.GetUserByUsername(req.Username)
+	user, err := s.userRepo.GetUserByUsername(ctx, req.Username)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Имя и пароль нового пользователя проверяются по тем же правилам, что и при явной регистрации.
+		// Отказ выглядит так же, как для неизвестного пользователя: ответ не должен выдавать, занято ли имя.
+		if !s.authSettings.AutoRegister || validateCredentials(req.Username, req.Password) != nil {
+			// Сравниваем с фиктивным хэшем, чтобы время ответа не выдавало отсутствие пользователя
+			compareDummyPassword(req.Password)
+			return nil, errs.ErrUnknownUser
+		}
 		// Если пользователя нет в базе, создаём нового
-		hashedPassword, _ := GetHashPassword(req.Password)
-		user = &models.User{
-			Username: req.Username,
-			Password: hashedPassword,
-			Coins:    1000, // Начальные монеты
+		created, err := s.createUser(ctx, req.Username, req.Password)
+		if err == nil {
+			return s.issueTokens(ctx, created, "")
 		}
-		if err = s.userRepo.CreateUser(user); err != nil {
-			return nil, errs.ErrCreateUser
+		if !errors.Is(err, errs.ErrUserAlreadyExists) {
+			return nil, err
 		}
-	} else if err == nil && user != nil {
-		// Если пользователь найден, проверяем пароль
-		if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-			return nil, errs.ErrInvalidPassword
+		// Параллельный запрос успел создать пользователя с тем же именем: проверяем пароль, как при входе
+		if user, err = s.userRepo.GetUserByUsername(ctx, req.Username); err != nil {
+			return nil, errs.ErrCreateUser
 		}
-	} else {
+	} else if err != nil || user == nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	// Пользователь найден, проверяем пароль
+	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, errs.ErrInvalidPassword
+	}
+
+	// Выдаём пару токенов для нового семейства
+	return s.issueTokens(ctx, user, "")
+}
+
+// Register - явная регистрация пользователя с проверкой имени и стойкости пароля
+func (s *UserService) Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error) {
+	if err := validateCredentials(req.Username, req.Password); err != nil {
+		return nil, err
+	}
+
+	_, err := s.userRepo.GetUserByUsername(ctx, req.Username)
+	if err == nil {
+		return nil, errs.ErrUserAlreadyExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, errs.ErrInternalServer
 	}
 
+	user, err := s.createUser(ctx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, "")
+}
+
+// createUser - создаёт пользователя со стартовым балансом из настроек экономики
+func (s *UserService) createUser(ctx context.Context, username, password string) (*models.User, error) {
+	hashedPassword, err := GetHashPassword(password)
+	if err != nil {
+		return nil, errs.ErrCreateUser
+	}
+	user := &models.User{
+		Username: username,
+		Password: hashedPassword,
+		Coins:    s.economy.Settings(ctx).WelcomeBonus, // Начальные монеты
+	}
+	if err = s.userRepo.CreateUser(ctx, user); err != nil {
+		// Параллельная регистрация того же имени упирается в уникальный индекс
+		if errors.Is(err, errs.ErrUserAlreadyExists) {
+			return nil, err
+		}
+		return nil, errs.ErrCreateUser
+	}
+	return user, nil
+}
+
+// Refresh - выдаёт новую пару токенов по refresh-токену, отзывая предъявленный.
+// Повторное предъявление уже заменённого токена считается утечкой: отзывается всё семейство
+// и все выпущенные пользователю access-токены.
+func (s *UserService) Refresh(ctx context.Context, req *models.RefreshRequest) (*models.AuthResponse, error) {
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errs.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errs.ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errs.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Отзываем предъявленный токен; если его успел использовать параллельный запрос, это тоже повтор
+	revoked, err := s.tokenRepo.RevokeRefreshToken(ctx, stored.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout - завершает сессию: отзывает refresh-токен (или все токены пользователя)
+// и делает недействительными выпущенные access-токены
+func (s *UserService) Logout(ctx context.Context, username string, req *models.LogoutRequest) error {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrUserNotFound
+		}
+		return errs.ErrInternalServer
+	}
+
+	if req.AllSessions {
+		if err = s.tokenRepo.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+			return err
+		}
+	} else if req.RefreshToken != "" {
+		stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashRefreshToken(req.RefreshToken))
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && stored.UserID != user.ID) {
+			return errs.ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if err = s.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	return s.userRepo.IncrementTokenVersion(ctx, user.ID)
+}
+
+// revokeReusedFamily - реакция на повторное использование refresh-токена
+func (s *UserService) revokeReusedFamily(ctx context.Context, stored *models.RefreshToken) error {
+	if err := s.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	if err := s.userRepo.IncrementTokenVersion(ctx, stored.UserID); err != nil {
+		return err
+	}
+	return errs.ErrRefreshTokenReused
+}
+
+// issueTokens - выпускает access-токен и refresh-токен; пустой familyID начинает новое семейство
+func (s *UserService) issueTokens(ctx context.Context, user *models.User, familyID string) (*models.AuthResponse, error) {
+	now := time.Now()
+
 	// Генерируем JWT токен
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	tokenString, err := s.jwtKeys.Sign(jwt.MapClaims{
 		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
+		"role":     userRole(user),
+		"ver":      user.TokenVersion,
+		"exp":      now.Add(s.authSettings.AccessTTL).Unix(),
 	})
-
-	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
 		return nil, errors.New("could not create JWT token")
 	}
 
-	return &models.AuthResponse{Token: tokenString}, nil
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(16); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.tokenRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.authSettings.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.authSettings.AccessTTL.Seconds()),
+	}, nil
+}
+
+// randomToken - случайная строка из n байт в base64url
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken - хэш refresh-токена для хранения в базе
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func GetHashPassword(pass string) (string, error) {
@@ -66,48 +275,94 @@ func GetHashPassword(pass string) (string, error) {
 }
 
 // ExtractUsernameFromToken разбирает токен, проверяет его валидность и возвращает username
-func (s *UserService) ExtractUsernameFromToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
+func (s *UserService) ExtractUsernameFromToken(ctx context.Context, tokenString string) (string, error) {
+	claims, err := s.ExtractClaimsFromToken(ctx, tokenString)
+	if err != nil {
+		return "", err
+	}
+	return claims.Username, nil
+}
 
+// ExtractClaimsFromToken разбирает токен, проверяет его валидность и возвращает username и роль.
+// Токен, выпущенный до смены роли пользователя, считается недействительным.
+func (s *UserService) ExtractClaimsFromToken(ctx context.Context, tokenString string) (*models.TokenClaims, error) {
+	token, err := s.jwtKeys.Parse(tokenString)
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
 
 	username, ok := claims["username"].(string)
 	if !ok {
-		return "", errors.New("username not found in token")
+		return nil, errors.New("username not found in token")
 	}
 
 	exp, ok := claims["exp"].(float64)
 	if !ok {
-		return "", errors.New("invalid expiration time")
+		return nil, errors.New("invalid expiration time")
 	}
 
 	if time.Now().Unix() > int64(exp) {
-		return "", errors.New("token expired")
+		return nil, errors.New("token expired")
 	}
 
-	user, err := s.userRepo.GetUserByUsername(username)
+	// Токены, выпущенные до появления ролей, относятся к обычным пользователям
+	role, ok := claims["role"].(string)
+	if !ok {
+		role = models.RoleUser
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil || user == nil {
-		return "", errs.ErrUserNotFound
+		return nil, errs.ErrUserNotFound
+	}
+
+	if role != userRole(user) {
+		return nil, errors.New("role in token does not match user role")
+	}
+
+	// Токен отозван выходом пользователя или обнаруженной утечкой refresh-токена
+	version, _ := claims["ver"].(float64)
+	if int(version) != user.TokenVersion {
+		return nil, errors.New("token revoked")
 	}
 
-	return username, nil
+	return &models.TokenClaims{Username: username, Role: role}, nil
+}
+
+// JWKS - открытые ключи проверки токенов для публикации
+func (s *UserService) JWKS() models.JWKS {
+	return s.jwtKeys.JWKS()
+}
+
+// SetRole - назначает пользователю роль
+func (s *UserService) SetRole(ctx context.Context, username, role string) error {
+	if !models.IsValidRole(role) {
+		return errs.ErrInvalidRole
+	}
+
+	err := s.userRepo.UpdateUserRole(ctx, username, role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.ErrUserNotFound
+	}
+	return err
+}
+
+// userRole - роль пользователя; пустая роль соответствует обычному пользователю
+func userRole(user *models.User) string {
+	if user.Role == "" {
+		return models.RoleUser
+	}
+	return user.Role
 }
 
 // BuyMerch - обработка покупки предмета
-func (s *UserService) BuyMerch(username string, merch *models.Merch) error {
-	user, err := s.userRepo.GetUserByUsername(username)
+func (s *UserService) BuyMerch(ctx context.Context, username string, merch *models.Merch) error {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errs.ErrUserNotFound
@@ -118,17 +373,189 @@ func (s *UserService) BuyMerch(username string, merch *models.Merch) error {
 		return errs.ErrInternalServer
 	}
 
-	// Проверяем, хватает ли монет
+	// Проверяем, хватает ли монет и остатка (окончательная проверка выполняется в транзакции репозитория)
 	if user.Coins < merch.Price {
 		return errs.ErrNotEnoughCoins
 	}
+	if !merch.InStock(1) {
+		return errs.ErrOutOfStock
+	}
 	// Покупаем предмет
-	return s.userRepo.BuyMerch(user, merch)
+	return s.userRepo.BuyMerch(ctx, user, merch)
+}
+
+// CreateOrder - оформление заказа из нескольких товаров; повторяющиеся товары объединяются в одну строку
+func (s *UserService) CreateOrder(ctx context.Context, username string, req models.CreateOrderRequest) (*models.OrderResponse, error) {
+	items, err := normalizeOrderItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	order, err := s.userRepo.CreateOrder(ctx, user, items)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := order.ToResponse()
+	return &resp, nil
+}
+
+// normalizeOrderItems - проверяет строки заказа и объединяет повторяющиеся товары
+func normalizeOrderItems(items []models.OrderItemRequest) ([]models.OrderItemRequest, error) {
+	if len(items) == 0 {
+		return nil, errs.ErrInvalidOrder
+	}
+
+	merged := make([]models.OrderItemRequest, 0, len(items))
+	index := make(map[string]int, len(items))
+	for _, item := range items {
+		if item.Item == "" || item.Quantity <= 0 {
+			return nil, errs.ErrInvalidOrder
+		}
+		if i, ok := index[item.Item]; ok {
+			merged[i].Quantity += item.Quantity
+		} else {
+			index[item.Item] = len(merged)
+			merged = append(merged, item)
+		}
+	}
+
+	if len(merged) > maxOrderLines {
+		return nil, errs.ErrInvalidOrder
+	}
+	for _, item := range merged {
+		if item.Quantity > maxOrderQuantity {
+			return nil, errs.ErrInvalidOrder
+		}
+	}
+	return merged, nil
+}
+
+// RefundPurchase - возврат покупки. Администратор может вернуть любую покупку,
+// пользователь — только свою и только в течение refundGracePeriod после покупки.
+func (s *UserService) RefundPurchase(ctx context.Context, username, role string, purchaseID uint) (*models.RefundResponse, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	purchase, err := s.userRepo.GetPurchase(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrPurchaseNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	if role != models.RoleAdmin {
+		// Чужие покупки не раскрываем
+		if purchase.UserID != user.ID {
+			return nil, errs.ErrPurchaseNotFound
+		}
+		if purchase.Refunded() {
+			return nil, errs.ErrAlreadyRefunded
+		}
+		if time.Since(purchase.CreatedAt) > s.refundGracePeriod {
+			return nil, errs.ErrRefundPeriodExpired
+		}
+	}
+
+	// Повторный возврат окончательно проверяется в транзакции репозитория
+	return s.userRepo.RefundPurchase(ctx, purchase.ID)
+}
+
+// AdjustBalance - начисление или списание монет пользователю администратором
+func (s *UserService) AdjustBalance(ctx context.Context, adminUsername, username string, req models.AdjustBalanceRequest) (*models.GrantResult, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errs.ErrGrantReasonRequired
+	}
+	if req.Amount == 0 {
+		return nil, errs.ErrInvalidGrant
+	}
+
+	admin, err := s.userRepo.GetUserByUsername(ctx, adminUsername)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUnauthorized
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.WithDetails(errs.ErrUserNotFound, map[string]interface{}{"username": username})
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	// Начисление, как и перевод, не поднимает баланс выше максимального из настроек экономики
+	return s.userRepo.AdjustBalance(ctx, admin.ID, user, req.Amount, reason, s.economy.Settings(ctx).MaxBalance)
+}
+
+// GrantCoins - массовое зачисление монет; при dryRun изменения не сохраняются
+func (s *UserService) GrantCoins(ctx context.Context, adminUsername string, req models.GrantRequest, dryRun bool) (*models.GrantBatchResponse, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errs.ErrGrantReasonRequired
+	}
+	if err := validateGrantLines(req.Grants); err != nil {
+		return nil, err
+	}
+
+	admin, err := s.userRepo.GetUserByUsername(ctx, adminUsername)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUnauthorized
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	return s.userRepo.ApplyGrants(ctx, admin.ID, reason, req.Grants, dryRun, s.economy.Settings(ctx).MaxBalance)
+}
+
+// validateGrantLines - строки массового зачисления: непустой список различных пользователей с ненулевыми суммами
+func validateGrantLines(lines []models.GrantLine) error {
+	if len(lines) == 0 || len(lines) > maxGrantLines {
+		return errs.ErrInvalidGrant
+	}
+
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.Username == "" || line.Amount == 0 || seen[line.Username] {
+			return errs.WithDetails(errs.ErrInvalidGrant, map[string]interface{}{"username": line.Username})
+		}
+		seen[line.Username] = true
+	}
+	return nil
 }
 
 // SendCoin - обработка отправки монет другому пользователю
-func (s *UserService) SendCoin(username string, req models.SendCoinRequest) error {
-	fromUser, err := s.userRepo.GetUserByUsername(username)
+func (s *UserService) SendCoin(ctx context.Context, username string, req models.SendCoinRequest) error {
+	return s.sendCoin(ctx, username, req, nil)
+}
+
+// SendScheduledCoin - выполняет запуск запланированного перевода с теми же проверками, что и обычный перевод.
+// Перевод связывается с запуском, поэтому один запуск не может списать монеты дважды.
+func (s *UserService) SendScheduledCoin(ctx context.Context, due models.DueTransfer) error {
+	req := models.SendCoinRequest{ToUser: due.ReceiverUsername, Amount: due.Amount, Message: due.Message, Category: due.Category}
+	return s.sendCoin(ctx, due.SenderUsername, req, &due.RunID)
+}
+
+func (s *UserService) sendCoin(ctx context.Context, username string, req models.SendCoinRequest, scheduledRunID *uint) error {
+	fromUser, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errs.ErrUserNotFound
@@ -139,7 +566,7 @@ func (s *UserService) SendCoin(username string, req models.SendCoinRequest) erro
 		return errs.ErrInternalServer
 	}
 
-	toUser, err := s.userRepo.GetUserByUsername(req.ToUser)
+	toUser, err := s.userRepo.GetUserByUsername(ctx, req.ToUser)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errs.ErrUserNotFound
@@ -150,24 +577,377 @@ func (s *UserService) SendCoin(username string, req models.SendCoinRequest) erro
 		return errs.ErrInternalServer
 	}
 
-	// проверяем что количество монет положительное
-	if req.Amount <= 0 {
-		return errs.ErrNegativeCoins
+	if err = s.checkTransferAmount(ctx, req.Amount); err != nil {
+		return err
 	}
-	// Проверяем, хватает ли монет у отправителя
+	// Проверяем, хватает ли монет у отправителя (окончательная проверка выполняется в транзакции репозитория)
 	if fromUser.Coins < req.Amount {
 		return errs.ErrNotEnoughCoins
 	}
 	if fromUser.Username == toUser.Username {
 		return errs.ErrSendCoinsToYourself
 	}
-	// оправляем монеты
-	return s.userRepo.SendCoin(fromUser, toUser, req.Amount)
+	message, err := sanitizeTransferMessage(req.Message)
+	if err != nil {
+		return err
+	}
+	if req.Category != "" && !models.TransferCategories[req.Category] {
+		return errs.ErrInvalidCategory
+	}
+	// оправляем монеты; суточный лимит и максимальный баланс проверяются в транзакции репозитория
+	transaction := &models.Transaction{Amount: req.Amount, Message: message, Category: req.Category, ScheduledRunID: scheduledRunID}
+	economy := s.economy.Settings(ctx)
+	return s.userRepo.SendCoin(ctx, fromUser, toUser, transaction, economy.TransferLimits())
+}
+
+// checkTransferAmount - проверяет, что сумма перевода положительна и укладывается в настройки экономики
+func (s *UserService) checkTransferAmount(ctx context.Context, amount int) error {
+	if amount <= 0 {
+		return errs.ErrNegativeCoins
+	}
+	economy := s.economy.Settings(ctx)
+	if amount < economy.MinTransfer {
+		return errs.WithDetails(errs.ErrTransferTooSmall, map[string]interface{}{"min": economy.MinTransfer})
+	}
+	if economy.MaxTransfer != nil && amount > *economy.MaxTransfer {
+		return errs.WithDetails(errs.ErrTransferTooLarge, map[string]interface{}{"max": *economy.MaxTransfer})
+	}
+	return nil
+}
+
+// sanitizeTransferMessage - приводит сообщение к переводу к одной строке: убирает управляющие и невидимые символы,
+// схлопывает пробелы и проверяет длину
+func sanitizeTransferMessage(message string) (string, error) {
+	message = strings.ToValidUTF8(message, "")
+	message = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, message)
+	message = strings.Join(strings.Fields(message), " ")
+
+	if utf8.RuneCountInString(message) > maxTransferMessage {
+		return "", errs.WithDetails(errs.ErrMessageTooLong, map[string]interface{}{"maxLength": maxTransferMessage})
+	}
+	return message, nil
+}
+
+// CreateCoinRequest - запрос монет у пользователя req.FromUser; плательщик может принять или отклонить его до истечения срока
+func (s *UserService) CreateCoinRequest(ctx context.Context, username string, req models.CreateCoinRequestRequest) (*models.CoinRequestResponse, error) {
+	requester, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+	payer, err := s.userRepo.GetUserByUsername(ctx, req.FromUser)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	if requester.ID == payer.ID {
+		return nil, errs.ErrSendCoinsToYourself
+	}
+	if err = s.checkTransferAmount(ctx, req.Amount); err != nil {
+		return nil, err
+	}
+	note, err := sanitizeTransferMessage(req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request := &models.CoinRequest{
+		RequesterID:   requester.ID,
+		PayerID:       payer.ID,
+		Amount:        req.Amount,
+		Note:          note,
+		Status:        models.CoinRequestPending,
+		ExpiresAt:     now.Add(s.coinRequestTTL),
+		RequesterName: requester.Username,
+		PayerName:     payer.Username,
+	}
+	if err = s.userRepo.CreateCoinRequest(ctx, request); err != nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	response := request.ToResponse(now)
+	return &response, nil
+}
+
+// ListCoinRequests - входящие (по умолчанию) или исходящие запросы монет пользователя
+func (s *UserService) ListCoinRequests(ctx context.Context, username string, query models.CoinRequestQuery) ([]models.CoinRequestResponse, error) {
+	switch query.Direction {
+	case "":
+		query.Direction = models.CoinRequestsIncoming
+	case models.CoinRequestsIncoming, models.CoinRequestsOutgoing:
+	default:
+		return nil, errs.ErrInvalidCoinRequestFilter
+	}
+	switch query.Status {
+	case "", models.CoinRequestPending, models.CoinRequestAccepted, models.CoinRequestDeclined, models.CoinRequestExpired:
+	default:
+		return nil, errs.ErrInvalidCoinRequestFilter
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	now := time.Now()
+	requests, err := s.userRepo.ListCoinRequests(ctx, user.ID, query, now, maxCoinRequests)
+	if err != nil {
+		return nil, errs.ErrInternalServer
+	}
+
+	response := make([]models.CoinRequestResponse, len(requests))
+	for i := range requests {
+		response[i] = requests[i].ToResponse(now)
+	}
+	return response, nil
+}
+
+// AcceptCoinRequest - плательщик оплачивает запрос монет. Перевод проходит те же проверки и лимиты, что и SendCoin.
+func (s *UserService) AcceptCoinRequest(ctx context.Context, username string, id uint) (*models.CoinRequestResponse, error) {
+	request, err := s.getIncomingCoinRequest(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkTransferAmount(ctx, request.Amount); err != nil {
+		return nil, err
+	}
+
+	// Окончательно баланс, лимиты и состояние запроса проверяются в транзакции репозитория
+	transaction := &models.Transaction{Amount: request.Amount, Message: request.Note}
+	economy := s.economy.Settings(ctx)
+	now := time.Now()
+	if err = s.userRepo.AcceptCoinRequest(ctx, request, transaction, economy.TransferLimits(), now); err != nil {
+		return nil, err
+	}
+
+	response := request.ToResponse(now)
+	return &response, nil
+}
+
+// DeclineCoinRequest - плательщик отклоняет запрос монет
+func (s *UserService) DeclineCoinRequest(ctx context.Context, username string, id uint) (*models.CoinRequestResponse, error) {
+	request, err := s.getIncomingCoinRequest(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err = s.userRepo.DeclineCoinRequest(ctx, request, now); err != nil {
+		return nil, err
+	}
+
+	response := request.ToResponse(now)
+	return &response, nil
+}
+
+// getIncomingCoinRequest - запрос монет, адресованный пользователю и ещё ждущий ответа.
+// Запросы, адресованные другим, не раскрываются.
+func (s *UserService) getIncomingCoinRequest(ctx context.Context, username string, id uint) (*models.CoinRequest, error) {
+	payer, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	request, err := s.userRepo.GetCoinRequest(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrCoinRequestNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+	if request.PayerID != payer.ID {
+		return nil, errs.ErrCoinRequestNotFound
+	}
+
+	switch request.EffectiveStatus(time.Now()) {
+	case models.CoinRequestPending:
+		return request, nil
+	case models.CoinRequestExpired:
+		return nil, errs.ErrCoinRequestExpired
+	default:
+		return nil, errs.ErrCoinRequestNotPending
+	}
+}
+
+// CreateHold - удерживает монеты пользователя до передачи получателю или возврата.
+// Удержанные монеты не входят в свободный баланс, поэтому недоступны для покупок и переводов.
+func (s *UserService) CreateHold(ctx context.Context, username string, req models.CreateHoldRequest) (*models.HoldResponse, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	if req.Amount <= 0 {
+		return nil, errs.ErrNegativeCoins
+	}
+	// Проверяем свободный баланс (окончательная проверка выполняется в транзакции репозитория)
+	if user.Coins < req.Amount {
+		return nil, errs.ErrNotEnoughCoins
+	}
+	note, err := sanitizeTransferMessage(req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.holdSettings.DefaultTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) || req.ExpiresAt.After(now.Add(s.holdSettings.MaxTTL)) {
+			return nil, errs.WithDetails(errs.ErrInvalidHoldExpiry, map[string]interface{}{"maxExpiresAt": now.Add(s.holdSettings.MaxTTL)})
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	hold := &models.Hold{Amount: req.Amount, Note: note, ExpiresAt: expiresAt}
+	if err = s.userRepo.CreateHold(ctx, user, hold); err != nil {
+		return nil, err
+	}
+
+	response := hold.ToResponse()
+	return &response, nil
+}
+
+// ReleaseHold - передаёт удержанные монеты получателю. Передача записывается как перевод владельца
+// и проходит проверки лимитов экономики.
+func (s *UserService) ReleaseHold(ctx context.Context, username string, id uint, req models.ReleaseHoldRequest) (*models.HoldResponse, error) {
+	hold, owner, err := s.getOwnHold(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+	// Монеты истёкшего удержания возвращаются владельцу, даже если фоновый возврат ещё не прошёл
+	if !time.Now().Before(hold.ExpiresAt) {
+		return nil, errs.WithDetails(errs.ErrHoldNotActive, map[string]interface{}{"expiredAt": hold.ExpiresAt})
+	}
+
+	recipient, err := s.userRepo.GetUserByUsername(ctx, req.ToUser)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+	if recipient.ID == owner.ID {
+		return nil, errs.ErrSendCoinsToYourself
+	}
+
+	transaction := &models.Transaction{Message: hold.Note}
+	economy := s.economy.Settings(ctx)
+	if err = s.userRepo.ReleaseHold(ctx, hold, recipient, transaction, economy.TransferLimits(), time.Now()); err != nil {
+		return nil, err
+	}
+
+	response := hold.ToResponse()
+	return &response, nil
+}
+
+// ReturnHold - возвращает удержанные монеты владельцу
+func (s *UserService) ReturnHold(ctx context.Context, username string, id uint) (*models.HoldResponse, error) {
+	hold, _, err := s.getOwnHold(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.userRepo.ReturnHold(ctx, hold, models.HoldReturned, time.Now()); err != nil {
+		return nil, err
+	}
+
+	response := hold.ToResponse()
+	return &response, nil
+}
+
+// ExpireHolds - возвращает владельцам монеты удержаний с истёкшим сроком и возвращает их число
+func (s *UserService) ExpireHolds(ctx context.Context) (int, error) {
+	holds, err := s.userRepo.ListExpiredHolds(ctx, time.Now(), holdExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range holds {
+		err = s.userRepo.ReturnHold(ctx, &holds[i], models.HoldExpired, time.Now())
+		if errors.Is(err, errs.ErrHoldNotActive) {
+			// Удержание успели передать или вернуть, возможно, на другом экземпляре сервера
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// StartHoldExpiry - возвращает монеты истёкших удержаний каждые ExpiryInterval, пока не отменён ctx
+func (s *UserService) StartHoldExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.holdSettings.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ExpireHolds(ctx); err != nil {
+			log.Println("failed to expire holds: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getOwnHold - действующее удержание пользователя и сам пользователь; чужие удержания не раскрываются
+func (s *UserService) getOwnHold(ctx context.Context, username string, id uint) (*models.Hold, *models.User, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errs.ErrUserNotFound
+		}
+		return nil, nil, errs.ErrInternalServer
+	}
+
+	hold, err := s.userRepo.GetHold(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errs.ErrHoldNotFound
+		}
+		return nil, nil, errs.ErrInternalServer
+	}
+	if hold.UserID != user.ID {
+		return nil, nil, errs.ErrHoldNotFound
+	}
+	if hold.Status != models.HoldActive {
+		return nil, nil, errs.ErrHoldNotActive
+	}
+	return hold, user, nil
 }
 
 // GetUserInfo - получает информацию о пользователе (баланс, инвентарь, историю транзакций)
-func (s *UserService) GetUserInfo(username string) (*models.InfoResponse, error) {
-	user, err := s.userRepo.GetUserByUsername(username)
+func (s *UserService) GetUserInfo(ctx context.Context, username string) (*models.InfoResponse, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errs.ErrUserNotFound
@@ -179,22 +959,123 @@ func (s *UserService) GetUserInfo(username string) (*models.InfoResponse, error)
 	}
 
 	// Получаем инвентарь пользователя
-	inventory, err := s.userRepo.GetUserInventory(user.ID)
+	inventory, err := s.userRepo.GetUserInventory(ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Получаем историю транзакций
-	coinHistory, err := s.userRepo.GetCoinHistory(user.ID)
+	// Получаем последние переводы; полная история доступна постранично через GetHistory
+	coinHistory, err := s.userRepo.GetCoinHistory(ctx, user.ID, recentHistoryLimit)
 	if err != nil {
 		return nil, err
 	}
 
+	// Действующие удержания показываются, только если они есть
+	var holds []models.HoldResponse
+	if user.HeldCoins > 0 {
+		active, err := s.userRepo.ListActiveHolds(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		holds = make([]models.HoldResponse, len(active))
+		for i := range active {
+			holds[i] = active[i].ToResponse()
+		}
+	}
+
 	info := &models.InfoResponse{
 		Coins:       user.Coins,
+		HeldCoins:   user.HeldCoins,
+		Holds:       holds,
 		Inventory:   inventory,
 		CoinHistory: coinHistory,
 	}
 
 	return info, nil
 }
+
+// GetHistory - страница истории переводов и покупок пользователя от новых к старым
+func (s *UserService) GetHistory(ctx context.Context, username string, query models.HistoryQuery) (*models.HistoryResponse, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultHistoryPageSize
+	}
+	if query.Limit < 0 || query.Limit > maxHistoryPageSize {
+		return nil, errs.ErrInvalidPagination
+	}
+	if err := validateHistoryFilter(query); err != nil {
+		return nil, err
+	}
+
+	var after *models.HistoryCursor
+	if query.Cursor != "" {
+		cursor, err := decodeHistoryCursor(query.Cursor)
+		if err != nil {
+			return nil, errs.ErrInvalidPagination
+		}
+		after = cursor
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, errs.ErrInternalServer
+	}
+
+	entries, err := s.userRepo.GetHistory(ctx, user.ID, query, after)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.HistoryResponse{Entries: entries}
+	if len(entries) > query.Limit {
+		resp.Entries = entries[:query.Limit]
+		last := resp.Entries[query.Limit-1]
+		resp.NextCursor = encodeHistoryCursor(models.HistoryCursor{CreatedAt: last.CreatedAt, Type: last.Type, ID: last.ID})
+	}
+	if resp.Entries == nil {
+		resp.Entries = []models.HistoryEntry{}
+	}
+	return resp, nil
+}
+
+// validateHistoryFilter - проверяет фильтры истории на согласованность
+func validateHistoryFilter(query models.HistoryQuery) error {
+	if _, ok := models.HistoryDirections[query.Direction]; query.Direction != "" && !ok {
+		return errs.ErrInvalidHistoryFilter
+	}
+	if query.Category != "" && !models.TransferCategories[query.Category] {
+		return errs.ErrInvalidHistoryFilter
+	}
+	if query.MinAmount != nil && query.MaxAmount != nil && *query.MinAmount > *query.MaxAmount {
+		return errs.ErrInvalidHistoryFilter
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return errs.ErrInvalidHistoryFilter
+	}
+	return nil
+}
+
+// encodeHistoryCursor - непрозрачное представление курсора для клиента
+func encodeHistoryCursor(cursor models.HistoryCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeHistoryCursor - разбирает курсор, полученный от клиента
+func decodeHistoryCursor(value string) (*models.HistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor models.HistoryCursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	// Курсор может указывать на запись любого вида, которым заканчивается страница
+	if !models.HistoryTypes[cursor.Type] {
+		return nil, errs.ErrInvalidPagination
+	}
+	return &cursor, nil
+}
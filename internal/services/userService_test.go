@@ -1,19 +1,40 @@
 package services
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 	"merch-shop/internal/errs"
 	"merch-shop/internal/mocks"
 	"merch-shop/internal/models"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// testAuthSettings - параметры входа в тестах
+var testAuthSettings = models.AuthSettings{AccessTTL: 15 * time.Minute, RefreshTTL: time.Hour, AutoRegister: true}
+
+// newTestEconomy - настройки экономики без обращения к базе
+func newTestEconomy(settings models.EconomySettings) *EconomyService {
+	economy := NewEconomyService(nil, 0)
+	economy.settings = settings
+	return economy
+}
+
 func TestSendCoin(t *testing.T) {
+	maxTransfer, dailyCap, maxBalance := 60, 100, 500
+	limited := models.DefaultEconomySettings()
+	limited.MinTransfer = 5
+	limited.MaxTransfer = &maxTransfer
+	limited.DailyTransferCap = &dailyCap
+	limited.MaxBalance = &maxBalance
+
 	tests := []struct {
 		name      string
+		economy   *models.EconomySettings
 		mockSetup func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest)
 		wantErr   error
 	}{
@@ -23,9 +44,9 @@ func TestSendCoin(t *testing.T) {
 				fromUser := &models.User{Username: "Andrey", Coins: 100}
 				toUser := &models.User{Username: "Ivan", Coins: 50}
 
-				mockRepo.On("GetUserByUsername", fromUser.Username).Return(fromUser, nil)
-				mockRepo.On("GetUserByUsername", toUser.Username).Return(toUser, nil)
-				mockRepo.On("SendCoin", fromUser, toUser, 50).Return(nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+				mockRepo.On("SendCoin", mock.Anything, fromUser, toUser, &models.Transaction{Amount: 50}, models.TransferLimits{}).Return(nil)
 
 				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 50}
 			},
@@ -34,7 +55,7 @@ func TestSendCoin(t *testing.T) {
 		{
 			name: "пользователь не найден",
 			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
-				mockRepo.On("GetUserByUsername", "Unknown").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Unknown").Return(nil, gorm.ErrRecordNotFound)
 
 				return "Unknown", models.SendCoinRequest{ToUser: "Ivan", Amount: 50}
 			},
@@ -45,7 +66,7 @@ func TestSendCoin(t *testing.T) {
 			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
 				user := &models.User{Username: "Andrey", Coins: 100}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
 
 				return user.Username, models.SendCoinRequest{ToUser: user.Username, Amount: 1}
 			},
@@ -57,8 +78,8 @@ func TestSendCoin(t *testing.T) {
 				fromUser := &models.User{Username: "Andrey", Coins: 100}
 				toUser := &models.User{Username: "Ivan", Coins: 50}
 
-				mockRepo.On("GetUserByUsername", fromUser.Username).Return(fromUser, nil)
-				mockRepo.On("GetUserByUsername", toUser.Username).Return(toUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
 
 				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 2000}
 			},
@@ -70,8 +91,8 @@ func TestSendCoin(t *testing.T) {
 				fromUser := &models.User{Username: "Andrey", Coins: 100}
 				toUser := &models.User{Username: "Ivan", Coins: 50}
 
-				mockRepo.On("GetUserByUsername", fromUser.Username).Return(fromUser, nil)
-				mockRepo.On("GetUserByUsername", toUser.Username).Return(toUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
 
 				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: -10}
 			},
@@ -83,26 +104,121 @@ func TestSendCoin(t *testing.T) {
 				fromUser := &models.User{Username: "Andrey", Coins: 100}
 				toUser := &models.User{Username: "Ivan", Coins: 50}
 
-				mockRepo.On("GetUserByUsername", fromUser.Username).Return(fromUser, nil)
-				mockRepo.On("GetUserByUsername", toUser.Username).Return(toUser, nil)
-				mockRepo.On("SendCoin", fromUser, toUser, 50).Return(errs.ErrInternalServer)
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+				mockRepo.On("SendCoin", mock.Anything, fromUser, toUser, &models.Transaction{Amount: 50}, models.TransferLimits{}).Return(errs.ErrInternalServer)
 
 				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 50}
 			},
 			wantErr: errs.ErrInternalServer,
 		},
+		{
+			name: "сообщение очищается и сохраняется с категорией",
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+				mockRepo.On("SendCoin", mock.Anything, fromUser, toUser,
+					&models.Transaction{Amount: 50, Message: "С днём рождения!", Category: models.TransferCategoryBirthday},
+					models.TransferLimits{}).Return(nil)
+
+				return fromUser.Username, models.SendCoinRequest{
+					ToUser:   toUser.Username,
+					Amount:   50,
+					Message:  "  С днём\n\tрождения!\u200b\x00 ",
+					Category: models.TransferCategoryBirthday,
+				}
+			},
+		},
+		{
+			name: "слишком длинное сообщение",
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+
+				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 50, Message: strings.Repeat("я", maxTransferMessage+1)}
+			},
+			wantErr: errs.ErrMessageTooLong,
+		},
+		{
+			name: "неизвестная категория",
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+
+				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 50, Category: "bribe"}
+			},
+			wantErr: errs.ErrInvalidCategory,
+		},
+		{
+			name:    "сумма меньше минимальной",
+			economy: &limited,
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+
+				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 4}
+			},
+			wantErr: errs.ErrTransferTooSmall,
+		},
+		{
+			name:    "сумма больше максимальной",
+			economy: &limited,
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+
+				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 61}
+			},
+			wantErr: errs.ErrTransferTooLarge,
+		},
+		{
+			name:    "суточный лимит проверяется в транзакции",
+			economy: &limited,
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, models.SendCoinRequest) {
+				fromUser := &models.User{Username: "Andrey", Coins: 100}
+				toUser := &models.User{Username: "Ivan", Coins: 50}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, fromUser.Username).Return(fromUser, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, toUser.Username).Return(toUser, nil)
+				mockRepo.On("SendCoin", mock.Anything, fromUser, toUser, &models.Transaction{Amount: 50}, models.TransferLimits{DailyCap: &dailyCap, MaxBalance: &maxBalance}).
+					Return(errs.WithDetails(errs.ErrDailyTransferLimit, map[string]interface{}{"limit": dailyCap, "remaining": 20}))
+
+				return fromUser.Username, models.SendCoinRequest{ToUser: toUser.Username, Amount: 50}
+			},
+			wantErr: errs.ErrDailyTransferLimit,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
+			economy := models.DefaultEconomySettings()
+			if tt.economy != nil {
+				economy = *tt.economy
+			}
+
 			mockRepo := mocks.NewUserRepository(t)
-			service := UserService{userRepo: mockRepo}
+			service := UserService{userRepo: mockRepo, jwtKeys: newTestJWTKeys(t), economy: newTestEconomy(economy)}
 
 			username, request := tt.mockSetup(mockRepo)
 
-			err := service.SendCoin(username, request)
+			err := service.SendCoin(context.Background(), username, request)
 
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
@@ -127,8 +243,8 @@ func TestBuyMerch(t *testing.T) {
 				user := &models.User{Username: "Andrey", Coins: 100}
 				merch := &models.Merch{Name: "t-shirt", Price: 80}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
-				mockRepo.On("BuyMerch", user, merch).Return(nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+				mockRepo.On("BuyMerch", mock.Anything, user, merch).Return(nil)
 
 				return user.Username, merch
 			},
@@ -139,7 +255,7 @@ func TestBuyMerch(t *testing.T) {
 			mockSetup: func(mockRepo *mocks.UserRepository) (string, *models.Merch) {
 				merch := &models.Merch{Name: "t-shirt", Price: 80}
 
-				mockRepo.On("GetUserByUsername", "Unknown").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Unknown").Return(nil, gorm.ErrRecordNotFound)
 
 				return "Unknown", merch
 			},
@@ -151,20 +267,33 @@ func TestBuyMerch(t *testing.T) {
 				user := &models.User{Username: "BrokeGuy", Coins: 10}
 				merch := &models.Merch{Name: "t-shirt", Price: 80}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
 
 				return user.Username, merch
 			},
 			wantErr: errs.ErrNotEnoughCoins,
 		},
+		{
+			name: "товар закончился",
+			mockSetup: func(mockRepo *mocks.UserRepository) (string, *models.Merch) {
+				user := &models.User{Username: "Andrey", Coins: 100}
+				stock := 0
+				merch := &models.Merch{Name: "pink-hoody", Price: 80, Stock: &stock}
+
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+				return user.Username, merch
+			},
+			wantErr: errs.ErrOutOfStock,
+		},
 		{
 			name: "ошибка при покупке",
 			mockSetup: func(mockRepo *mocks.UserRepository) (string, *models.Merch) {
 				user := &models.User{Username: "Andrey", Coins: 100}
 				merch := &models.Merch{Name: "t-shirt", Price: 80}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
-				mockRepo.On("BuyMerch", user, merch).Return(errs.ErrInternalServer)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+				mockRepo.On("BuyMerch", mock.Anything, user, merch).Return(errs.ErrInternalServer)
 
 				return user.Username, merch
 			},
@@ -177,11 +306,11 @@ func TestBuyMerch(t *testing.T) {
 			t.Parallel()
 
 			mockRepo := mocks.NewUserRepository(t)
-			service := UserService{userRepo: mockRepo}
+			service := UserService{userRepo: mockRepo, jwtKeys: newTestJWTKeys(t)}
 
 			username, merch := tt.mockSetup(mockRepo)
 
-			err := service.BuyMerch(username, merch)
+			err := service.BuyMerch(context.Background(), username, merch)
 
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
@@ -213,9 +342,9 @@ func TestGetUserInfo(t *testing.T) {
 					Sent:     []models.CoinTransaction{{ToUser: "Alex", Amount: 20}},
 				}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
-				mockRepo.On("GetUserInventory", user.ID).Return(inventory, nil)
-				mockRepo.On("GetCoinHistory", user.ID).Return(coinHistory, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+				mockRepo.On("GetUserInventory", mock.Anything, user.ID).Return(inventory, nil)
+				mockRepo.On("GetCoinHistory", mock.Anything, user.ID, recentHistoryLimit).Return(coinHistory, nil)
 			},
 			expectedError: nil,
 			expectedInfo: &models.InfoResponse{
@@ -231,7 +360,7 @@ func TestGetUserInfo(t *testing.T) {
 			name:     "пользователь не найден",
 			username: "Unknown",
 			setupMocks: func(mockRepo *mocks.UserRepository) {
-				mockRepo.On("GetUserByUsername", "Unknown").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Unknown").Return(nil, gorm.ErrRecordNotFound)
 			},
 			expectedError: errs.ErrUserNotFound,
 			expectedInfo:  nil,
@@ -241,8 +370,8 @@ func TestGetUserInfo(t *testing.T) {
 			username: "Andrey",
 			setupMocks: func(mockRepo *mocks.UserRepository) {
 				user := &models.User{Username: "Andrey", Coins: 100}
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
-				mockRepo.On("GetUserInventory", user.ID).Return(nil, errs.ErrInternalServer)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+				mockRepo.On("GetUserInventory", mock.Anything, user.ID).Return(nil, errs.ErrInternalServer)
 			},
 			expectedError: errs.ErrInternalServer,
 			expectedInfo:  nil,
@@ -254,9 +383,9 @@ func TestGetUserInfo(t *testing.T) {
 				user := &models.User{Username: "Andrey", Coins: 100}
 				inventory := []models.Item{{Type: "t-shirt", Quantity: 1}}
 
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
-				mockRepo.On("GetUserInventory", user.ID).Return(inventory, nil)
-				mockRepo.On("GetCoinHistory", user.ID).Return(models.CoinHistory{}, errs.ErrInternalServer)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+				mockRepo.On("GetUserInventory", mock.Anything, user.ID).Return(inventory, nil)
+				mockRepo.On("GetCoinHistory", mock.Anything, user.ID, recentHistoryLimit).Return(models.CoinHistory{}, errs.ErrInternalServer)
 			},
 			expectedError: errs.ErrInternalServer,
 			expectedInfo:  nil,
@@ -268,11 +397,11 @@ func TestGetUserInfo(t *testing.T) {
 			t.Parallel()
 
 			mockRepo := mocks.NewUserRepository(t)
-			service := UserService{userRepo: mockRepo}
+			service := UserService{userRepo: mockRepo, jwtKeys: newTestJWTKeys(t)}
 
 			tt.setupMocks(mockRepo)
 
-			info, err := service.GetUserInfo(tt.username)
+			info, err := service.GetUserInfo(context.Background(), tt.username)
 			assert.ErrorIs(t, err, tt.expectedError)
 			assert.Equal(t, tt.expectedInfo, info)
 
@@ -283,10 +412,11 @@ func TestGetUserInfo(t *testing.T) {
 
 func TestAuthenticate(t *testing.T) {
 	tests := []struct {
-		name      string
-		mockSetup func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error)
-		wantResp  *models.AuthResponse
-		wantErr   error
+		name           string
+		noAutoRegister bool
+		mockSetup      func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error)
+		wantResp       *models.AuthResponse
+		wantErr        error
 	}{
 		{
 			name: "успешная аутентификация (пользователь существует)",
@@ -297,7 +427,7 @@ func TestAuthenticate(t *testing.T) {
 					Username: "Andrey",
 					Password: hashPassword,
 				}
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
 
 				return &models.AuthRequest{Username: "Andrey", Password: password}, nil
 			},
@@ -307,10 +437,10 @@ func TestAuthenticate(t *testing.T) {
 		{
 			name: "пользователь не найден, создаем нового",
 			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
-				mockRepo.On("GetUserByUsername", "NewUser").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound)
 
 				// Мокируем создание нового пользователя
-				mockRepo.On("CreateUser", mock.Anything).Return(nil)
+				mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(nil)
 
 				return &models.AuthRequest{Username: "NewUser", Password: "newPassword123"}, nil
 			},
@@ -321,7 +451,7 @@ func TestAuthenticate(t *testing.T) {
 			name: "неправильный пароль",
 			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
 				user := &models.User{Username: "Andrey", Password: "$2a$10$Qh71lZRj8ix5brUBUoKlfe1sq5.nTkVffV6fwSTv.Hk1vwZZwP6Pi"}
-				mockRepo.On("GetUserByUsername", user.Username).Return(user, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
 
 				// Здесь пароль неправильный
 				return &models.AuthRequest{Username: "Andrey", Password: "wrongPassword"}, nil
@@ -332,16 +462,73 @@ func TestAuthenticate(t *testing.T) {
 		{
 			name: "ошибка при создании пользователя",
 			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
-				mockRepo.On("GetUserByUsername", "NewUser").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound)
 
 				// Мокируем ошибку при создании пользователя
-				mockRepo.On("CreateUser", mock.Anything).Return(errs.ErrCreateUser)
+				mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(errs.ErrCreateUser)
 
 				return &models.AuthRequest{Username: "NewUser", Password: "newPassword123"}, nil
 			},
 			wantResp: nil,
 			wantErr:  errs.ErrCreateUser,
 		},
+		{
+			name:           "пользователь не найден, автоматическая регистрация выключена",
+			noAutoRegister: true,
+			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound)
+
+				return &models.AuthRequest{Username: "NewUser", Password: "newPassword123"}, nil
+			},
+			wantResp: nil,
+			wantErr:  errs.ErrUnknownUser,
+		},
+		{
+			name: "автоматическая регистрация с недопустимым именем",
+			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "new user").Return(nil, gorm.ErrRecordNotFound)
+
+				return &models.AuthRequest{Username: "new user", Password: "newPassword123"}, nil
+			},
+			wantResp: nil,
+			wantErr:  errs.ErrUnknownUser,
+		},
+		{
+			name: "автоматическая регистрация со слабым паролем",
+			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound)
+
+				return &models.AuthRequest{Username: "NewUser", Password: "password"}, nil
+			},
+			wantResp: nil,
+			wantErr:  errs.ErrUnknownUser,
+		},
+		{
+			name: "пользователь создан параллельным запросом",
+			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
+				hashPassword, _ := GetHashPassword("newPassword123")
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound).Once()
+				mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(errs.ErrUserAlreadyExists)
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(&models.User{Username: "NewUser", Password: hashPassword}, nil).Once()
+
+				return &models.AuthRequest{Username: "NewUser", Password: "newPassword123"}, nil
+			},
+			wantResp: &models.AuthResponse{Token: "some-jwt-token"},
+			wantErr:  nil,
+		},
+		{
+			name: "пользователь создан параллельным запросом с другим паролем",
+			mockSetup: func(mockRepo *mocks.UserRepository) (*models.AuthRequest, error) {
+				hashPassword, _ := GetHashPassword("otherPassword123")
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(nil, gorm.ErrRecordNotFound).Once()
+				mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(errs.ErrUserAlreadyExists)
+				mockRepo.On("GetUserByUsername", mock.Anything, "NewUser").Return(&models.User{Username: "NewUser", Password: hashPassword}, nil).Once()
+
+				return &models.AuthRequest{Username: "NewUser", Password: "newPassword123"}, nil
+			},
+			wantResp: nil,
+			wantErr:  errs.ErrInvalidPassword,
+		},
 	}
 
 	for _, tt := range tests {
@@ -349,23 +536,1253 @@ func TestAuthenticate(t *testing.T) {
 			t.Parallel()
 
 			mockRepo := mocks.NewUserRepository(t)
-			service := UserService{userRepo: mockRepo}
+			mockTokenRepo := mocks.NewTokenRepository(t)
+			mockTokenRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Maybe()
+			service := UserService{userRepo: mockRepo, tokenRepo: mockTokenRepo, jwtKeys: newTestJWTKeys(t), authSettings: testAuthSettings, economy: newTestEconomy(models.DefaultEconomySettings())}
+			service.authSettings.AutoRegister = !tt.noAutoRegister
 
 			req, err := tt.mockSetup(mockRepo)
 			if err != nil {
 				t.Fatal("mock setup error:", err)
 			}
 
-			resp, err := service.Authenticate(req)
+			resp, err := service.Authenticate(context.Background(), req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Token)
+				assert.NotEmpty(t, resp.RefreshToken)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      string
+		mockSetup func(mockRepo *mocks.UserRepository)
+		wantErr   error
+	}{
+		{
+			name: "успешное назначение роли",
+			role: models.RoleAdmin,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("UpdateUserRole", mock.Anything, "Andrey", models.RoleAdmin).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:      "неизвестная роль",
+			role:      "superuser",
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidRole,
+		},
+		{
+			name: "пользователь не найден",
+			role: models.RoleAuditor,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("UpdateUserRole", mock.Anything, "Andrey", models.RoleAuditor).Return(gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, jwtKeys: newTestJWTKeys(t)}
+
+			tt.mockSetup(mockRepo)
+
+			err := service.SetRole(context.Background(), "Andrey", tt.role)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExtractClaimsFromToken(t *testing.T) {
+	password := "password123"
+	hashPassword, _ := GetHashPassword(password)
+
+	tests := []struct {
+		name        string
+		issuedRole  string
+		currentRole string
+		wantClaims  *models.TokenClaims
+		wantErr     bool
+	}{
+		{
+			name:        "роль администратора в токене",
+			issuedRole:  models.RoleAdmin,
+			currentRole: models.RoleAdmin,
+			wantClaims:  &models.TokenClaims{Username: "Andrey", Role: models.RoleAdmin},
+		},
+		{
+			name:        "пользователь без роли считается обычным",
+			issuedRole:  "",
+			currentRole: "",
+			wantClaims:  &models.TokenClaims{Username: "Andrey", Role: models.RoleUser},
+		},
+		{
+			name:        "роль изменилась после выпуска токена",
+			issuedRole:  models.RoleAdmin,
+			currentRole: models.RoleUser,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			mockTokenRepo := mocks.NewTokenRepository(t)
+			mockTokenRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+			service := UserService{userRepo: mockRepo, tokenRepo: mockTokenRepo, jwtKeys: newTestJWTKeys(t), authSettings: testAuthSettings}
+
+			issued := &models.User{Username: "Andrey", Password: hashPassword, Role: tt.issuedRole}
+			current := &models.User{Username: "Andrey", Password: hashPassword, Role: tt.currentRole}
+			mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(issued, nil).Once()
+			mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(current, nil).Once()
+
+			resp, err := service.Authenticate(context.Background(), &models.AuthRequest{Username: "Andrey", Password: password})
+			assert.NoError(t, err)
+
+			claims, err := service.ExtractClaimsFromToken(context.Background(), resp.Token)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantClaims, claims)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	const refreshToken = "refresh-token"
+	revokedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		mockSetup func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository)
+		wantErr   error
+	}{
+		{
+			name: "успешная ротация",
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				stored := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).Return(stored, nil)
+				mockRepo.On("GetUserByID", mock.Anything, uint(7)).Return(&models.User{Username: "Andrey"}, nil)
+				mockTokenRepo.On("RevokeRefreshToken", mock.Anything, uint(1), (*uint)(nil)).Return(true, nil)
+				mockTokenRepo.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *models.RefreshToken) bool {
+					return token.FamilyID == "family" && token.TokenHash != hashRefreshToken(refreshToken)
+				})).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "неизвестный токен",
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrInvalidRefreshToken,
+		},
+		{
+			name: "истёкший токен",
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				stored := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family", ExpiresAt: time.Now().Add(-time.Hour)}
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).Return(stored, nil)
+			},
+			wantErr: errs.ErrInvalidRefreshToken,
+		},
+		{
+			name: "повторное использование отзывает семейство",
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				stored := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).Return(stored, nil)
+				mockTokenRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil)
+				mockRepo.On("IncrementTokenVersion", mock.Anything, uint(7)).Return(nil)
+			},
+			wantErr: errs.ErrRefreshTokenReused,
+		},
+		{
+			name: "параллельная ротация того же токена",
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				stored := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).Return(stored, nil)
+				mockRepo.On("GetUserByID", mock.Anything, uint(7)).Return(&models.User{Username: "Andrey"}, nil)
+				mockTokenRepo.On("RevokeRefreshToken", mock.Anything, uint(1), (*uint)(nil)).Return(false, nil)
+				mockTokenRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil)
+				mockRepo.On("IncrementTokenVersion", mock.Anything, uint(7)).Return(nil)
+			},
+			wantErr: errs.ErrRefreshTokenReused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			mockTokenRepo := mocks.NewTokenRepository(t)
+			service := UserService{userRepo: mockRepo, tokenRepo: mockTokenRepo, jwtKeys: newTestJWTKeys(t), authSettings: testAuthSettings}
+
+			tt.mockSetup(mockRepo, mockTokenRepo)
+
+			resp, err := service.Refresh(context.Background(), &models.RefreshRequest{RefreshToken: refreshToken})
 
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotEmpty(t, resp.Token)
+				assert.NotEqual(t, refreshToken, resp.RefreshToken)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	const refreshToken = "refresh-token"
+
+	tests := []struct {
+		name      string
+		req       *models.LogoutRequest
+		mockSetup func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository)
+		wantErr   error
+	}{
+		{
+			name: "выход из текущей сессии",
+			req:  &models.LogoutRequest{RefreshToken: refreshToken},
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				user := &models.User{Username: "Andrey"}
+				user.ID = 7
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).
+					Return(&models.RefreshToken{UserID: 7, FamilyID: "family"}, nil)
+				mockTokenRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil)
+				mockRepo.On("IncrementTokenVersion", mock.Anything, uint(7)).Return(nil)
+			},
+		},
+		{
+			name: "выход из всех сессий",
+			req:  &models.LogoutRequest{AllSessions: true},
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				user := &models.User{Username: "Andrey"}
+				user.ID = 7
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockTokenRepo.On("RevokeUserRefreshTokens", mock.Anything, uint(7)).Return(nil)
+				mockRepo.On("IncrementTokenVersion", mock.Anything, uint(7)).Return(nil)
+			},
+		},
+		{
+			name: "чужой refresh-токен",
+			req:  &models.LogoutRequest{RefreshToken: refreshToken},
+			mockSetup: func(mockRepo *mocks.UserRepository, mockTokenRepo *mocks.TokenRepository) {
+				user := &models.User{Username: "Andrey"}
+				user.ID = 7
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockTokenRepo.On("GetRefreshTokenByHash", mock.Anything, hashRefreshToken(refreshToken)).
+					Return(&models.RefreshToken{UserID: 8, FamilyID: "family"}, nil)
+			},
+			wantErr: errs.ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			mockTokenRepo := mocks.NewTokenRepository(t)
+			service := UserService{userRepo: mockRepo, tokenRepo: mockTokenRepo}
+
+			tt.mockSetup(mockRepo, mockTokenRepo)
+
+			err := service.Logout(context.Background(), "Andrey", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
 			}
 
 			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
 		})
 	}
 }
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       *models.RegisterRequest
+		mockSetup func(mockRepo *mocks.UserRepository)
+		wantErr   error
+	}{
+		{
+			name: "успешная регистрация",
+			req:  &models.RegisterRequest{Username: "new.user", Password: "password123"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "new.user").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(user *models.User) bool {
+					return user.Username == "new.user" && user.Coins == 1000 && user.Password != "password123"
+				})).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "пользователь уже существует",
+			req:  &models.RegisterRequest{Username: "Andrey", Password: "password123"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey"}, nil)
+			},
+			wantErr: errs.ErrUserAlreadyExists,
+		},
+		{
+			name:      "слишком короткое имя",
+			req:       &models.RegisterRequest{Username: "ab", Password: "password123"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidUsername,
+		},
+		{
+			name:      "недопустимые символы в имени",
+			req:       &models.RegisterRequest{Username: "Андрей", Password: "password123"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidUsername,
+		},
+		{
+			name:      "короткий пароль",
+			req:       &models.RegisterRequest{Username: "Andrey", Password: "pass1"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrWeakPassword,
+		},
+		{
+			name:      "пароль без цифр",
+			req:       &models.RegisterRequest{Username: "Andrey", Password: "password"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrWeakPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			mockTokenRepo := mocks.NewTokenRepository(t)
+			mockTokenRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Maybe()
+			service := UserService{userRepo: mockRepo, tokenRepo: mockTokenRepo, jwtKeys: newTestJWTKeys(t), authSettings: testAuthSettings, economy: newTestEconomy(models.DefaultEconomySettings())}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.Register(context.Background(), tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Token)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	createdAt := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	entries := []models.HistoryEntry{
+		{ID: 3, Type: models.HistoryTypeSent, Counterparty: "Ivan", Amount: 30, CreatedAt: createdAt},
+		{ID: 2, Type: models.HistoryTypePurchase, Item: "cup", Amount: 20, CreatedAt: createdAt.Add(-time.Minute)},
+		{ID: 1, Type: models.HistoryTypeReceived, Counterparty: "Alex", Amount: 10, CreatedAt: createdAt.Add(-time.Hour)},
+	}
+	cursor := &models.HistoryCursor{CreatedAt: entries[1].CreatedAt, Type: entries[1].Type, ID: entries[1].ID}
+	minAmount, maxAmount := 50, 10
+
+	tests := []struct {
+		name           string
+		query          models.HistoryQuery
+		mockSetup      func(mockRepo *mocks.UserRepository)
+		wantEntries    []models.HistoryEntry
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:  "есть следующая страница",
+			query: models.HistoryQuery{Limit: 2},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey"}, nil)
+				mockRepo.On("GetHistory", mock.Anything, uint(0), models.HistoryQuery{Limit: 2}, (*models.HistoryCursor)(nil)).Return(entries, nil)
+			},
+			wantEntries:    entries[:2],
+			wantNextCursor: encodeHistoryCursor(*cursor),
+		},
+		{
+			name:  "последняя страница по курсору",
+			query: models.HistoryQuery{Limit: 2, Cursor: encodeHistoryCursor(*cursor), Direction: "received"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey"}, nil)
+				mockRepo.On("GetHistory", mock.Anything, uint(0), mock.Anything, cursor).Return(entries[2:], nil)
+			},
+			wantEntries: entries[2:],
+		},
+		{
+			name:  "пустая история",
+			query: models.HistoryQuery{},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey"}, nil)
+				mockRepo.On("GetHistory", mock.Anything, uint(0), models.HistoryQuery{Limit: defaultHistoryPageSize}, (*models.HistoryCursor)(nil)).Return(nil, nil)
+			},
+			wantEntries: []models.HistoryEntry{},
+		},
+		{
+			name:      "слишком большая страница",
+			query:     models.HistoryQuery{Limit: maxHistoryPageSize + 1},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidPagination,
+		},
+		{
+			name:      "повреждённый курсор",
+			query:     models.HistoryQuery{Cursor: "not-a-cursor"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidPagination,
+		},
+		{
+			name:      "курсор с неизвестным видом записи",
+			query:     models.HistoryQuery{Cursor: encodeHistoryCursor(models.HistoryCursor{CreatedAt: createdAt, Type: "deposit", ID: 1})},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidPagination,
+		},
+		{
+			name:      "неизвестное направление",
+			query:     models.HistoryQuery{Direction: "deposits"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidHistoryFilter,
+		},
+		{
+			name:      "неизвестная категория",
+			query:     models.HistoryQuery{Category: "bribe"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidHistoryFilter,
+		},
+		{
+			name:      "минимальная сумма больше максимальной",
+			query:     models.HistoryQuery{MinAmount: &minAmount, MaxAmount: &maxAmount},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidHistoryFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.GetHistory(context.Background(), "Andrey", tt.query)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantEntries, resp.Entries)
+				assert.Equal(t, tt.wantNextCursor, resp.NextCursor)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetHistoryPagesAcrossEntryTypes(t *testing.T) {
+	createdAt := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	older := models.HistoryEntry{ID: 1, Type: models.HistoryTypeReceived, Counterparty: "Alex", Amount: 10, CreatedAt: createdAt.Add(-time.Hour)}
+
+	tests := []struct {
+		name string
+		last models.HistoryEntry // Последняя запись первой страницы
+	}{
+		{
+			name: "страница заканчивается покупкой",
+			last: models.HistoryEntry{ID: 2, Type: models.HistoryTypePurchase, Item: "cup", Amount: 20, CreatedAt: createdAt},
+		},
+		{
+			name: "страница заканчивается возвратом",
+			last: models.HistoryEntry{ID: 2, Type: models.HistoryTypeRefund, Item: "cup", Amount: 20, CreatedAt: createdAt},
+		},
+		{
+			name: "страница заканчивается начислением администратора",
+			last: models.HistoryEntry{ID: 2, Type: models.HistoryTypeGrant, Amount: 50, Reason: "q3", CreatedAt: createdAt},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo}
+			cursor := &models.HistoryCursor{CreatedAt: tt.last.CreatedAt, Type: tt.last.Type, ID: tt.last.ID}
+
+			mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(&models.User{Username: "Andrey"}, nil)
+			mockRepo.On("GetHistory", mock.Anything, uint(0), models.HistoryQuery{Limit: 1}, (*models.HistoryCursor)(nil)).
+				Return([]models.HistoryEntry{tt.last, older}, nil)
+			mockRepo.On("GetHistory", mock.Anything, uint(0), mock.Anything, cursor).Return([]models.HistoryEntry{older}, nil)
+
+			first, err := service.GetHistory(context.Background(), "Andrey", models.HistoryQuery{Limit: 1})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []models.HistoryEntry{tt.last}, first.Entries)
+
+			// Курсор, выданный сервером, принимается на следующем запросе
+			second, err := service.GetHistory(context.Background(), "Andrey", models.HistoryQuery{Limit: 1, Cursor: first.NextCursor})
+			if assert.NoError(t, err) {
+				assert.Equal(t, []models.HistoryEntry{older}, second.Entries)
+				assert.Empty(t, second.NextCursor)
+			}
+		})
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	user := &models.User{Username: "Andrey", Coins: 1000}
+	order := &models.Order{
+		Total: 120,
+		Lines: []models.OrderLine{
+			{Item: "pen", Quantity: 3, UnitPrice: 10, Amount: 30},
+			{Item: "cup", Quantity: 1, UnitPrice: 20, Amount: 20},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		req       models.CreateOrderRequest
+		mockSetup func(mockRepo *mocks.UserRepository)
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name: "повторяющиеся товары объединяются",
+			req: models.CreateOrderRequest{Items: []models.OrderItemRequest{
+				{Item: "pen", Quantity: 1},
+				{Item: "cup", Quantity: 1},
+				{Item: "pen", Quantity: 2},
+			}},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("CreateOrder", mock.Anything, user, []models.OrderItemRequest{
+					{Item: "pen", Quantity: 3},
+					{Item: "cup", Quantity: 1},
+				}).Return(order, nil)
+			},
+			wantTotal: 120,
+		},
+		{
+			name:      "пустой заказ",
+			req:       models.CreateOrderRequest{},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidOrder,
+		},
+		{
+			name:      "неположительное количество",
+			req:       models.CreateOrderRequest{Items: []models.OrderItemRequest{{Item: "pen", Quantity: 0}}},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidOrder,
+		},
+		{
+			name:      "слишком большое количество",
+			req:       models.CreateOrderRequest{Items: []models.OrderItemRequest{{Item: "pen", Quantity: maxOrderQuantity}, {Item: "pen", Quantity: 1}}},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidOrder,
+		},
+		{
+			name: "неизвестный товар отменяет весь заказ",
+			req:  models.CreateOrderRequest{Items: []models.OrderItemRequest{{Item: "pen", Quantity: 1}, {Item: "yacht", Quantity: 1}}},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("CreateOrder", mock.Anything, user, mock.Anything).
+					Return(nil, errs.WithDetails(errs.ErrMerchNotFound, map[string]interface{}{"item": "yacht"}))
+			},
+			wantErr: errs.ErrMerchNotFound,
+		},
+		{
+			name: "не хватает монет на весь заказ",
+			req:  models.CreateOrderRequest{Items: []models.OrderItemRequest{{Item: "pen", Quantity: 500}}},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("CreateOrder", mock.Anything, user, mock.Anything).Return(nil, errs.ErrNotEnoughCoins)
+			},
+			wantErr: errs.ErrNotEnoughCoins,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.CreateOrder(context.Background(), "Andrey", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantTotal, resp.Total)
+				assert.Len(t, resp.Items, 2)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefundPurchase(t *testing.T) {
+	user := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey", Coins: 900}
+	admin := &models.User{Model: gorm.Model{ID: 2}, Username: "admin", Role: models.RoleAdmin}
+	refundedAt := time.Now()
+	recent := &models.Purchase{Model: gorm.Model{ID: 10, CreatedAt: time.Now().Add(-time.Minute)}, UserID: 1, Quantity: 2}
+	old := &models.Purchase{Model: gorm.Model{ID: 11, CreatedAt: time.Now().Add(-time.Hour)}, UserID: 1, Quantity: 1}
+	foreign := &models.Purchase{Model: gorm.Model{ID: 12, CreatedAt: time.Now()}, UserID: 3, Quantity: 1}
+	refunded := &models.Purchase{Model: gorm.Model{ID: 13, CreatedAt: time.Now()}, UserID: 1, Quantity: 1, RefundedAt: &refundedAt}
+	refund := &models.RefundResponse{PurchaseID: 10, Item: "pen", Quantity: 2, Amount: 20, Balance: 920}
+
+	tests := []struct {
+		name       string
+		username   string
+		role       string
+		purchaseID uint
+		mockSetup  func(mockRepo *mocks.UserRepository)
+		wantErr    error
+	}{
+		{
+			name:       "возврат своей покупки в течение срока",
+			username:   "Andrey",
+			role:       models.RoleUser,
+			purchaseID: 10,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(10)).Return(recent, nil)
+				mockRepo.On("RefundPurchase", mock.Anything, uint(10)).Return(refund, nil)
+			},
+		},
+		{
+			name:       "срок возврата истёк",
+			username:   "Andrey",
+			role:       models.RoleUser,
+			purchaseID: 11,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(11)).Return(old, nil)
+			},
+			wantErr: errs.ErrRefundPeriodExpired,
+		},
+		{
+			name:       "администратор возвращает покупку после срока",
+			username:   "admin",
+			role:       models.RoleAdmin,
+			purchaseID: 11,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(11)).Return(old, nil)
+				mockRepo.On("RefundPurchase", mock.Anything, uint(11)).Return(refund, nil)
+			},
+		},
+		{
+			name:       "чужая покупка",
+			username:   "Andrey",
+			role:       models.RoleUser,
+			purchaseID: 12,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(12)).Return(foreign, nil)
+			},
+			wantErr: errs.ErrPurchaseNotFound,
+		},
+		{
+			name:       "покупка уже возвращена",
+			username:   "Andrey",
+			role:       models.RoleUser,
+			purchaseID: 13,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(13)).Return(refunded, nil)
+			},
+			wantErr: errs.ErrAlreadyRefunded,
+		},
+		{
+			name:       "покупка не найдена",
+			username:   "Andrey",
+			role:       models.RoleUser,
+			purchaseID: 99,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("GetPurchase", mock.Anything, uint(99)).Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrPurchaseNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, refundGracePeriod: 15 * time.Minute}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.RefundPurchase(context.Background(), tt.username, tt.role, tt.purchaseID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, refund, resp)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdjustBalance(t *testing.T) {
+	admin := &models.User{Model: gorm.Model{ID: 1}, Username: "admin", Role: models.RoleAdmin}
+	user := &models.User{Model: gorm.Model{ID: 2}, Username: "Andrey", Coins: 100}
+	maxBalance := 1000
+	economy := models.DefaultEconomySettings()
+	economy.MaxBalance = &maxBalance
+
+	tests := []struct {
+		name      string
+		username  string
+		req       models.AdjustBalanceRequest
+		mockSetup func(mockRepo *mocks.UserRepository)
+		wantErr   error
+	}{
+		{
+			name:     "начисление",
+			username: "Andrey",
+			req:      models.AdjustBalanceRequest{Amount: 500, Reason: " хакатон "},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("AdjustBalance", mock.Anything, uint(1), user, 500, "хакатон", &maxBalance).
+					Return(&models.GrantResult{GrantID: 1, Username: "Andrey", Amount: 500, Balance: 600}, nil)
+			},
+		},
+		{
+			name:     "списание больше баланса",
+			username: "Andrey",
+			req:      models.AdjustBalanceRequest{Amount: -500, Reason: "ошибочное начисление"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("AdjustBalance", mock.Anything, uint(1), user, -500, "ошибочное начисление", &maxBalance).Return(nil, errs.ErrNotEnoughCoins)
+			},
+			wantErr: errs.ErrNotEnoughCoins,
+		},
+		{
+			name:     "начисление выше максимального баланса",
+			username: "Andrey",
+			req:      models.AdjustBalanceRequest{Amount: 1000, Reason: "хакатон"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(user, nil)
+				mockRepo.On("AdjustBalance", mock.Anything, uint(1), user, 1000, "хакатон", &maxBalance).
+					Return(nil, errs.WithDetails(errs.ErrBalanceLimit, map[string]interface{}{"username": "Andrey", "maxBalance": maxBalance}))
+			},
+			wantErr: errs.ErrBalanceLimit,
+		},
+		{
+			name:      "без обоснования",
+			username:  "Andrey",
+			req:       models.AdjustBalanceRequest{Amount: 500, Reason: "  "},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrGrantReasonRequired,
+		},
+		{
+			name:      "нулевая сумма",
+			username:  "Andrey",
+			req:       models.AdjustBalanceRequest{Reason: "хакатон"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidGrant,
+		},
+		{
+			name:     "пользователь не найден",
+			username: "Unknown",
+			req:      models.AdjustBalanceRequest{Amount: 500, Reason: "хакатон"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("GetUserByUsername", mock.Anything, "Unknown").Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: errs.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, economy: newTestEconomy(economy)}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.AdjustBalance(context.Background(), "admin", tt.username, tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 600, resp.Balance)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGrantCoins(t *testing.T) {
+	admin := &models.User{Model: gorm.Model{ID: 1}, Username: "admin", Role: models.RoleAdmin}
+	lines := []models.GrantLine{{Username: "Andrey", Amount: 100}, {Username: "Maria", Amount: 200}}
+	maxBalance := 1000
+	economy := models.DefaultEconomySettings()
+	economy.MaxBalance = &maxBalance
+
+	tests := []struct {
+		name      string
+		req       models.GrantRequest
+		dryRun    bool
+		mockSetup func(mockRepo *mocks.UserRepository)
+		wantErr   error
+	}{
+		{
+			name: "зачисление пакета",
+			req:  models.GrantRequest{Reason: "квартальное пополнение", Grants: lines},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("ApplyGrants", mock.Anything, uint(1), "квартальное пополнение", lines, false, &maxBalance).
+					Return(&models.GrantBatchResponse{BatchID: 1, Total: 300}, nil)
+			},
+		},
+		{
+			name:   "пробный запуск",
+			req:    models.GrantRequest{Reason: "квартальное пополнение", Grants: lines},
+			dryRun: true,
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("ApplyGrants", mock.Anything, uint(1), "квартальное пополнение", lines, true, &maxBalance).
+					Return(&models.GrantBatchResponse{DryRun: true, Total: 300}, nil)
+			},
+		},
+		{
+			name: "начисление выше максимального баланса отменяет пакет",
+			req:  models.GrantRequest{Reason: "квартальное пополнение", Grants: lines},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("ApplyGrants", mock.Anything, uint(1), "квартальное пополнение", lines, false, &maxBalance).
+					Return(nil, errs.WithDetails(errs.ErrBalanceLimit, map[string]interface{}{"username": "Maria", "maxBalance": maxBalance}))
+			},
+			wantErr: errs.ErrBalanceLimit,
+		},
+		{
+			name:      "пустой пакет",
+			req:       models.GrantRequest{Reason: "квартальное пополнение"},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidGrant,
+		},
+		{
+			name:      "повторяющийся получатель",
+			req:       models.GrantRequest{Reason: "квартальное пополнение", Grants: append(lines, models.GrantLine{Username: "Andrey", Amount: 1})},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrInvalidGrant,
+		},
+		{
+			name:      "без обоснования",
+			req:       models.GrantRequest{Grants: lines},
+			mockSetup: func(mockRepo *mocks.UserRepository) {},
+			wantErr:   errs.ErrGrantReasonRequired,
+		},
+		{
+			name: "неизвестный получатель отменяет пакет",
+			req:  models.GrantRequest{Reason: "квартальное пополнение", Grants: lines},
+			mockSetup: func(mockRepo *mocks.UserRepository) {
+				mockRepo.On("GetUserByUsername", mock.Anything, "admin").Return(admin, nil)
+				mockRepo.On("ApplyGrants", mock.Anything, uint(1), "квартальное пополнение", lines, false, &maxBalance).
+					Return(nil, errs.WithDetails(errs.ErrUserNotFound, map[string]interface{}{"username": "Maria"}))
+			},
+			wantErr: errs.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, economy: newTestEconomy(economy)}
+
+			tt.mockSetup(mockRepo)
+
+			resp, err := service.GrantCoins(context.Background(), "admin", tt.req, tt.dryRun)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 300, resp.Total)
+				assert.Equal(t, tt.dryRun, resp.DryRun)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateCoinRequest(t *testing.T) {
+	requester := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey"}
+	payer := &models.User{Model: gorm.Model{ID: 2}, Username: "Ivan"}
+
+	tests := []struct {
+		name    string
+		req     models.CreateCoinRequestRequest
+		wantErr error
+	}{
+		{
+			name: "запрос создан",
+			req:  models.CreateCoinRequestRequest{FromUser: "Ivan", Amount: 30, Note: " За  пиццу "},
+		},
+		{
+			name:    "запрос у самого себя",
+			req:     models.CreateCoinRequestRequest{FromUser: "Andrey", Amount: 30},
+			wantErr: errs.ErrSendCoinsToYourself,
+		},
+		{
+			name:    "неположительная сумма",
+			req:     models.CreateCoinRequestRequest{FromUser: "Ivan", Amount: 0},
+			wantErr: errs.ErrNegativeCoins,
+		},
+		{
+			name:    "плательщик не найден",
+			req:     models.CreateCoinRequestRequest{FromUser: "Unknown", Amount: 30},
+			wantErr: errs.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, economy: newTestEconomy(models.DefaultEconomySettings()), coinRequestTTL: time.Hour}
+
+			mockRepo.On("GetUserByUsername", mock.Anything, "Andrey").Return(requester, nil)
+			mockRepo.On("GetUserByUsername", mock.Anything, "Ivan").Return(payer, nil).Maybe()
+			mockRepo.On("GetUserByUsername", mock.Anything, "Unknown").Return(nil, gorm.ErrRecordNotFound).Maybe()
+			mockRepo.On("CreateCoinRequest", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			request, err := service.CreateCoinRequest(context.Background(), "Andrey", tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "CreateCoinRequest", mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.CoinRequestPending, request.Status)
+			assert.Equal(t, "Ivan", request.Payer)
+			assert.Equal(t, "За пиццу", request.Note)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), request.ExpiresAt, time.Minute)
+		})
+	}
+}
+
+func TestAcceptCoinRequest(t *testing.T) {
+	payer := &models.User{Model: gorm.Model{ID: 2}, Username: "Ivan", Coins: 100}
+	maxTransfer := 20
+	limited := models.DefaultEconomySettings()
+	limited.MaxTransfer = &maxTransfer
+
+	pending := func() *models.CoinRequest {
+		return &models.CoinRequest{Model: gorm.Model{ID: 7}, RequesterID: 1, PayerID: 2, Amount: 30, Note: "За пиццу",
+			Status: models.CoinRequestPending, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	tests := []struct {
+		name      string
+		economy   *models.EconomySettings
+		request   func() *models.CoinRequest
+		accept    bool
+		acceptErr error
+		wantErr   error
+	}{
+		{
+			name:    "запрос оплачен",
+			request: pending,
+			accept:  true,
+		},
+		{
+			name:      "у плательщика недостаточно монет",
+			request:   pending,
+			accept:    true,
+			acceptErr: errs.ErrNotEnoughCoins,
+			wantErr:   errs.ErrNotEnoughCoins,
+		},
+		{
+			name: "запрос адресован другому пользователю",
+			request: func() *models.CoinRequest {
+				request := pending()
+				request.PayerID = 3
+				return request
+			},
+			wantErr: errs.ErrCoinRequestNotFound,
+		},
+		{
+			name: "срок запроса истёк",
+			request: func() *models.CoinRequest {
+				request := pending()
+				request.ExpiresAt = time.Now().Add(-time.Minute)
+				return request
+			},
+			wantErr: errs.ErrCoinRequestExpired,
+		},
+		{
+			name: "запрос уже отклонён",
+			request: func() *models.CoinRequest {
+				request := pending()
+				request.Status = models.CoinRequestDeclined
+				return request
+			},
+			wantErr: errs.ErrCoinRequestNotPending,
+		},
+		{
+			name:    "сумма превышает максимальный перевод",
+			economy: &limited,
+			request: pending,
+			wantErr: errs.ErrTransferTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			economy := models.DefaultEconomySettings()
+			if tt.economy != nil {
+				economy = *tt.economy
+			}
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, economy: newTestEconomy(economy)}
+
+			request := tt.request()
+			mockRepo.On("GetUserByUsername", mock.Anything, payer.Username).Return(payer, nil)
+			mockRepo.On("GetCoinRequest", mock.Anything, uint(7)).Return(request, nil)
+			if tt.accept {
+				transaction := &models.Transaction{Amount: 30, Message: "За пиццу"}
+				mockRepo.On("AcceptCoinRequest", mock.Anything, request, transaction, models.TransferLimits{}, mock.Anything).Return(tt.acceptErr)
+			}
+
+			response, err := service.AcceptCoinRequest(context.Background(), payer.Username, 7)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 30, response.Amount)
+		})
+	}
+}
+
+func TestCreateHold(t *testing.T) {
+	testHoldSettings := models.HoldSettings{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour}
+	tooLate := time.Now().Add(48 * time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		req     models.CreateHoldRequest
+		wantErr error
+	}{
+		{
+			name: "монеты удержаны",
+			req:  models.CreateHoldRequest{Amount: 40, Note: "Баунти: починить сборку"},
+		},
+		{
+			name:    "удержание больше свободного баланса",
+			req:     models.CreateHoldRequest{Amount: 150},
+			wantErr: errs.ErrNotEnoughCoins,
+		},
+		{
+			name:    "неположительная сумма",
+			req:     models.CreateHoldRequest{Amount: 0},
+			wantErr: errs.ErrNegativeCoins,
+		},
+		{
+			name:    "срок больше максимального",
+			req:     models.CreateHoldRequest{Amount: 40, ExpiresAt: &tooLate},
+			wantErr: errs.ErrInvalidHoldExpiry,
+		},
+		{
+			name:    "срок в прошлом",
+			req:     models.CreateHoldRequest{Amount: 40, ExpiresAt: &past},
+			wantErr: errs.ErrInvalidHoldExpiry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			user := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey", Coins: 100}
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, holdSettings: testHoldSettings}
+
+			mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+			mockRepo.On("CreateHold", mock.Anything, user, mock.Anything).Return(nil).Maybe()
+
+			hold, err := service.CreateHold(context.Background(), user.Username, tt.req)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "CreateHold", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 40, hold.Amount)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), hold.ExpiresAt, time.Minute)
+		})
+	}
+}
+
+func TestReleaseHold(t *testing.T) {
+	owner := &models.User{Model: gorm.Model{ID: 1}, Username: "Andrey"}
+	recipient := &models.User{Model: gorm.Model{ID: 2}, Username: "Ivan"}
+
+	active := func() *models.Hold {
+		return &models.Hold{Model: gorm.Model{ID: 9}, UserID: owner.ID, Amount: 40, Note: "Баунти", Status: models.HoldActive, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	tests := []struct {
+		name    string
+		hold    func() *models.Hold
+		toUser  string
+		release bool
+		wantErr error
+	}{
+		{
+			name:    "монеты переданы",
+			hold:    active,
+			toUser:  recipient.Username,
+			release: true,
+		},
+		{
+			name: "чужое удержание",
+			hold: func() *models.Hold {
+				hold := active()
+				hold.UserID = 3
+				return hold
+			},
+			toUser:  recipient.Username,
+			wantErr: errs.ErrHoldNotFound,
+		},
+		{
+			name: "монеты уже возвращены",
+			hold: func() *models.Hold {
+				hold := active()
+				hold.Status = models.HoldReturned
+				return hold
+			},
+			toUser:  recipient.Username,
+			wantErr: errs.ErrHoldNotActive,
+		},
+		{
+			name: "срок удержания истёк",
+			hold: func() *models.Hold {
+				hold := active()
+				hold.ExpiresAt = time.Now().Add(-time.Minute)
+				return hold
+			},
+			toUser:  recipient.Username,
+			wantErr: errs.ErrHoldNotActive,
+		},
+		{
+			name:    "передача самому себе",
+			hold:    active,
+			toUser:  owner.Username,
+			wantErr: errs.ErrSendCoinsToYourself,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := mocks.NewUserRepository(t)
+			service := UserService{userRepo: mockRepo, economy: newTestEconomy(models.DefaultEconomySettings())}
+
+			hold := tt.hold()
+			mockRepo.On("GetUserByUsername", mock.Anything, owner.Username).Return(owner, nil)
+			mockRepo.On("GetUserByUsername", mock.Anything, recipient.Username).Return(recipient, nil).Maybe()
+			mockRepo.On("GetHold", mock.Anything, uint(9)).Return(hold, nil)
+			if tt.release {
+				mockRepo.On("ReleaseHold", mock.Anything, hold, recipient, &models.Transaction{Message: "Баунти"}, models.TransferLimits{}, mock.Anything).Return(nil)
+			}
+
+			_, err := service.ReleaseHold(context.Background(), owner.Username, 9, models.ReleaseHoldRequest{ToUser: tt.toUser})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	mockRepo := mocks.NewUserRepository(t)
+	service := UserService{userRepo: mockRepo}
+
+	holds := []models.Hold{{Model: gorm.Model{ID: 1}, Status: models.HoldActive}, {Model: gorm.Model{ID: 2}, Status: models.HoldActive}}
+	mockRepo.On("ListExpiredHolds", mock.Anything, mock.Anything, holdExpiryBatch).Return(holds, nil)
+	mockRepo.On("ReturnHold", mock.Anything, &holds[0], models.HoldExpired, mock.Anything).Return(nil)
+	// Второе удержание успели вернуть на другом экземпляре
+	mockRepo.On("ReturnHold", mock.Anything, &holds[1], models.HoldExpired, mock.Anything).Return(errs.ErrHoldNotActive)
+
+	expired, err := service.ExpireHolds(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+}
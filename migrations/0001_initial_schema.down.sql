@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS holds, coin_requests, scheduled_runs, scheduled_transfers, economy_settings, grants, grant_batches,
+    login_attempts, refresh_tokens, ledger_entries, idempotency_keys, transactions, purchases, orders, merches, users;
@@ -0,0 +1,233 @@
+-- Исходная схема. Таблицы создаются с IF NOT EXISTS, поэтому база, созданная раньше через AutoMigrate,
+-- принимается как уже находящаяся на этой версии.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username text NOT NULL CONSTRAINT uni_users_username UNIQUE,
+    password text NOT NULL,
+    coins bigint,
+    role text NOT NULL DEFAULT 'user',
+    held_coins bigint NOT NULL DEFAULT 0,
+    token_version bigint NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS merches (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL CONSTRAINT uni_merches_name UNIQUE,
+    price bigint,
+    stock bigint,
+    per_user_limit bigint
+);
+CREATE INDEX IF NOT EXISTS idx_merches_deleted_at ON merches (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    total bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+
+CREATE TABLE IF NOT EXISTS purchases (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    merch_id bigint NOT NULL,
+    order_id bigint,
+    quantity bigint NOT NULL DEFAULT 1,
+    unit_price bigint NOT NULL DEFAULT 0,
+    refunded_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_purchases_deleted_at ON purchases (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_purchases_order_id ON purchases (order_id);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    sender_id bigint NOT NULL,
+    receiver_id bigint NOT NULL,
+    amount bigint NOT NULL,
+    message text NOT NULL DEFAULT '',
+    category text NOT NULL DEFAULT '',
+    scheduled_run_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_deleted_at ON transactions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions (category);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_scheduled_run_id ON transactions (scheduled_run_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz,
+    username text NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    locked_until timestamptz,
+    status_code bigint,
+    content_type text,
+    response bytea
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_username_key ON idempotency_keys (username, key);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    account text NOT NULL,
+    user_id bigint,
+    delta bigint NOT NULL,
+    reason text NOT NULL,
+    transaction_id bigint,
+    purchase_id bigint,
+    grant_id bigint,
+    hold_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries (account);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user_id ON ledger_entries (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL,
+    family_id text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    replaced_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key text PRIMARY KEY,
+    failures bigint NOT NULL DEFAULT 0,
+    blocked_until timestamptz,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS grant_batches (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    admin_id bigint NOT NULL,
+    reason text NOT NULL,
+    total bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_grant_batches_deleted_at ON grant_batches (deleted_at);
+
+CREATE TABLE IF NOT EXISTS grants (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    admin_id bigint NOT NULL,
+    batch_id bigint,
+    amount bigint NOT NULL,
+    reason text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_grants_deleted_at ON grants (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_grants_user_id ON grants (user_id);
+CREATE INDEX IF NOT EXISTS idx_grants_batch_id ON grants (batch_id);
+
+CREATE TABLE IF NOT EXISTS economy_settings (
+    id bigserial PRIMARY KEY,
+    welcome_bonus bigint NOT NULL,
+    min_transfer bigint NOT NULL,
+    max_transfer bigint,
+    daily_transfer_cap bigint,
+    max_balance bigint,
+    updated_at timestamptz,
+    updated_by text
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    sender_id bigint NOT NULL,
+    receiver_id bigint NOT NULL,
+    amount bigint NOT NULL,
+    message text NOT NULL DEFAULT '',
+    category text NOT NULL DEFAULT '',
+    run_at timestamptz,
+    cron text NOT NULL DEFAULT '',
+    status text NOT NULL,
+    next_run_at timestamptz,
+    last_run_at timestamptz,
+    last_status text NOT NULL DEFAULT '',
+    last_error text NOT NULL DEFAULT '',
+    failure_count bigint NOT NULL DEFAULT 0,
+    run_count bigint NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_deleted_at ON scheduled_transfers (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_sender_id ON scheduled_transfers (sender_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_status ON scheduled_transfers (status);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_next_run_at ON scheduled_transfers (next_run_at);
+
+CREATE TABLE IF NOT EXISTS scheduled_runs (
+    id bigserial PRIMARY KEY,
+    schedule_id bigint NOT NULL,
+    scheduled_for timestamptz NOT NULL,
+    claimed_at timestamptz NOT NULL,
+    finished_at timestamptz,
+    status text NOT NULL,
+    error_code text NOT NULL DEFAULT '',
+    error_message text NOT NULL DEFAULT ''
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduled_run ON scheduled_runs (schedule_id, scheduled_for);
+CREATE INDEX IF NOT EXISTS idx_scheduled_runs_status ON scheduled_runs (status);
+
+CREATE TABLE IF NOT EXISTS coin_requests (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    requester_id bigint NOT NULL,
+    payer_id bigint NOT NULL,
+    amount bigint NOT NULL,
+    note text NOT NULL DEFAULT '',
+    status text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    responded_at timestamptz,
+    transaction_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_deleted_at ON coin_requests (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_requester_id ON coin_requests (requester_id);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_payer_id ON coin_requests (payer_id);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_status ON coin_requests (status);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    amount bigint NOT NULL,
+    note text NOT NULL DEFAULT '',
+    status text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    resolved_at timestamptz,
+    recipient_id bigint,
+    transaction_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_holds_deleted_at ON holds (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_holds_user_id ON holds (user_id);
+CREATE INDEX IF NOT EXISTS idx_holds_status ON holds (status);
+CREATE INDEX IF NOT EXISTS idx_holds_expires_at ON holds (expires_at);
@@ -0,0 +1,4 @@
+-- Удаляются только товары начального каталога, которые никто не покупал
+DELETE FROM merches m
+WHERE m.name IN ('t-shirt', 'cup', 'book', 'pen', 'powerbank', 'hoody', 'umbrella', 'socks', 'wallet', 'pink-hoody')
+  AND NOT EXISTS (SELECT 1 FROM purchases p WHERE p.merch_id = m.id);
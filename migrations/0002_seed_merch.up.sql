@@ -0,0 +1,13 @@
+-- Начальный каталог мерча
+INSERT INTO merches (created_at, updated_at, name, price) VALUES
+    (now(), now(), 't-shirt', 80),
+    (now(), now(), 'cup', 20),
+    (now(), now(), 'book', 50),
+    (now(), now(), 'pen', 10),
+    (now(), now(), 'powerbank', 200),
+    (now(), now(), 'hoody', 300),
+    (now(), now(), 'umbrella', 200),
+    (now(), now(), 'socks', 10),
+    (now(), now(), 'wallet', 50),
+    (now(), now(), 'pink-hoody', 500)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,69 @@
+DROP INDEX IF EXISTS idx_transactions_sender_id, idx_transactions_receiver_id, idx_purchases_user_id, idx_purchases_merch_id;
+
+ALTER TABLE coin_requests
+    DROP CONSTRAINT IF EXISTS fk_coin_requests_requester,
+    DROP CONSTRAINT IF EXISTS fk_coin_requests_payer,
+    DROP CONSTRAINT IF EXISTS fk_coin_requests_transaction,
+    DROP CONSTRAINT IF EXISTS chk_coin_requests_amount_positive,
+    DROP CONSTRAINT IF EXISTS chk_coin_requests_distinct_users;
+
+ALTER TABLE scheduled_runs
+    DROP CONSTRAINT IF EXISTS fk_scheduled_runs_schedule;
+
+ALTER TABLE scheduled_transfers
+    DROP CONSTRAINT IF EXISTS fk_scheduled_transfers_sender,
+    DROP CONSTRAINT IF EXISTS fk_scheduled_transfers_receiver,
+    DROP CONSTRAINT IF EXISTS chk_scheduled_transfers_amount_positive,
+    DROP CONSTRAINT IF EXISTS chk_scheduled_transfers_distinct_users;
+
+ALTER TABLE refresh_tokens
+    DROP CONSTRAINT IF EXISTS fk_refresh_tokens_user;
+
+ALTER TABLE ledger_entries
+    DROP CONSTRAINT IF EXISTS fk_ledger_entries_user,
+    DROP CONSTRAINT IF EXISTS fk_ledger_entries_transaction,
+    DROP CONSTRAINT IF EXISTS fk_ledger_entries_purchase,
+    DROP CONSTRAINT IF EXISTS fk_ledger_entries_grant,
+    DROP CONSTRAINT IF EXISTS fk_ledger_entries_hold;
+
+ALTER TABLE holds
+    DROP CONSTRAINT IF EXISTS fk_holds_user,
+    DROP CONSTRAINT IF EXISTS fk_holds_recipient,
+    DROP CONSTRAINT IF EXISTS fk_holds_transaction,
+    DROP CONSTRAINT IF EXISTS chk_holds_amount_positive;
+
+ALTER TABLE grants
+    DROP CONSTRAINT IF EXISTS fk_grants_user,
+    DROP CONSTRAINT IF EXISTS fk_grants_admin,
+    DROP CONSTRAINT IF EXISTS fk_grants_batch,
+    DROP CONSTRAINT IF EXISTS chk_grants_amount_non_zero;
+
+ALTER TABLE grant_batches
+    DROP CONSTRAINT IF EXISTS fk_grant_batches_admin;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT IF EXISTS fk_transactions_sender,
+    DROP CONSTRAINT IF EXISTS fk_transactions_receiver,
+    DROP CONSTRAINT IF EXISTS fk_transactions_scheduled_run,
+    DROP CONSTRAINT IF EXISTS chk_transactions_amount_positive,
+    DROP CONSTRAINT IF EXISTS chk_transactions_distinct_users;
+
+ALTER TABLE purchases
+    DROP CONSTRAINT IF EXISTS fk_purchases_user,
+    DROP CONSTRAINT IF EXISTS fk_purchases_merch,
+    DROP CONSTRAINT IF EXISTS fk_purchases_order,
+    DROP CONSTRAINT IF EXISTS chk_purchases_quantity_positive,
+    DROP CONSTRAINT IF EXISTS chk_purchases_unit_price_non_negative;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS fk_orders_user,
+    DROP CONSTRAINT IF EXISTS chk_orders_total_non_negative;
+
+ALTER TABLE merches
+    DROP CONSTRAINT IF EXISTS chk_merches_price_positive,
+    DROP CONSTRAINT IF EXISTS chk_merches_stock_non_negative,
+    DROP CONSTRAINT IF EXISTS chk_merches_per_user_limit_positive;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS chk_users_coins_non_negative,
+    DROP CONSTRAINT IF EXISTS chk_users_held_coins_non_negative;
@@ -0,0 +1,97 @@
+-- Инварианты экономики на уровне схемы. Имена ограничений используются в repositories.ConstraintErrors,
+-- чтобы нарушение возвращалось клиенту соответствующей ошибкой, а не INTERNAL_ERROR.
+--
+-- Поведение при удалении: пользователи и товары удаляются мягко, поэтому жёсткое удаление строки,
+-- на которую ссылается денежная история (переводы, покупки, заказы, журнал, начисления, удержания), запрещено (RESTRICT).
+-- Производные записи (refresh-токены, запланированные переводы, запросы монет) удаляются вместе с владельцем (CASCADE),
+-- необязательные обратные ссылки обнуляются (SET NULL).
+
+-- Балансы и суммы
+ALTER TABLE users
+    ADD CONSTRAINT chk_users_coins_non_negative CHECK (coins >= 0),
+    ADD CONSTRAINT chk_users_held_coins_non_negative CHECK (held_coins >= 0);
+
+ALTER TABLE merches
+    ADD CONSTRAINT chk_merches_price_positive CHECK (price > 0),
+    ADD CONSTRAINT chk_merches_stock_non_negative CHECK (stock >= 0),
+    ADD CONSTRAINT chk_merches_per_user_limit_positive CHECK (per_user_limit > 0);
+
+ALTER TABLE orders
+    ADD CONSTRAINT chk_orders_total_non_negative CHECK (total >= 0);
+
+ALTER TABLE purchases
+    ADD CONSTRAINT chk_purchases_quantity_positive CHECK (quantity > 0),
+    ADD CONSTRAINT chk_purchases_unit_price_non_negative CHECK (unit_price >= 0);
+
+ALTER TABLE transactions
+    ADD CONSTRAINT chk_transactions_amount_positive CHECK (amount > 0),
+    ADD CONSTRAINT chk_transactions_distinct_users CHECK (sender_id <> receiver_id);
+
+ALTER TABLE scheduled_transfers
+    ADD CONSTRAINT chk_scheduled_transfers_amount_positive CHECK (amount > 0),
+    ADD CONSTRAINT chk_scheduled_transfers_distinct_users CHECK (sender_id <> receiver_id);
+
+ALTER TABLE coin_requests
+    ADD CONSTRAINT chk_coin_requests_amount_positive CHECK (amount > 0),
+    ADD CONSTRAINT chk_coin_requests_distinct_users CHECK (requester_id <> payer_id);
+
+ALTER TABLE holds
+    ADD CONSTRAINT chk_holds_amount_positive CHECK (amount > 0);
+
+ALTER TABLE grants
+    ADD CONSTRAINT chk_grants_amount_non_zero CHECK (amount <> 0);
+
+-- Внешние ключи
+ALTER TABLE orders
+    ADD CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT;
+
+ALTER TABLE purchases
+    ADD CONSTRAINT fk_purchases_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_purchases_merch FOREIGN KEY (merch_id) REFERENCES merches (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_purchases_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE RESTRICT;
+
+ALTER TABLE transactions
+    ADD CONSTRAINT fk_transactions_sender FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_transactions_receiver FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_transactions_scheduled_run FOREIGN KEY (scheduled_run_id) REFERENCES scheduled_runs (id) ON DELETE SET NULL;
+
+ALTER TABLE grant_batches
+    ADD CONSTRAINT fk_grant_batches_admin FOREIGN KEY (admin_id) REFERENCES users (id) ON DELETE RESTRICT;
+
+ALTER TABLE grants
+    ADD CONSTRAINT fk_grants_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_grants_admin FOREIGN KEY (admin_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_grants_batch FOREIGN KEY (batch_id) REFERENCES grant_batches (id) ON DELETE RESTRICT;
+
+ALTER TABLE holds
+    ADD CONSTRAINT fk_holds_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_holds_recipient FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_holds_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE SET NULL;
+
+ALTER TABLE ledger_entries
+    ADD CONSTRAINT fk_ledger_entries_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_ledger_entries_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_ledger_entries_purchase FOREIGN KEY (purchase_id) REFERENCES purchases (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_ledger_entries_grant FOREIGN KEY (grant_id) REFERENCES grants (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_ledger_entries_hold FOREIGN KEY (hold_id) REFERENCES holds (id) ON DELETE RESTRICT;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE scheduled_transfers
+    ADD CONSTRAINT fk_scheduled_transfers_sender FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_scheduled_transfers_receiver FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE scheduled_runs
+    ADD CONSTRAINT fk_scheduled_runs_schedule FOREIGN KEY (schedule_id) REFERENCES scheduled_transfers (id) ON DELETE CASCADE;
+
+ALTER TABLE coin_requests
+    ADD CONSTRAINT fk_coin_requests_requester FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_coin_requests_payer FOREIGN KEY (payer_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_coin_requests_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE SET NULL;
+
+-- Индексы для выборок истории по участнику
+CREATE INDEX IF NOT EXISTS idx_transactions_sender_id ON transactions (sender_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_receiver_id ON transactions (receiver_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_user_id ON purchases (user_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_merch_id ON purchases (merch_id);
@@ -0,0 +1,9 @@
+// Package migrations - SQL-миграции схемы базы данных, встроенные в бинарный файл сервера
+package migrations
+
+import "embed"
+
+// FS - файлы миграций NNNN_name.up.sql и NNNN_name.down.sql
+//
+//go:embed *.sql
+var FS embed.FS